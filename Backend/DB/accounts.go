@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DeletedUsername is the author shown for content of deleted accounts. The
+// migrations create an account with this name to hold it.
+const DeletedUsername = "[deleted user]"
+
+// GetDeletedUserID returns the ID of the account that holds the content of
+// deleted accounts
+func GetDeletedUserID(conn *sql.DB) (int, error) {
+	var id int
+	err := conn.QueryRow(`SELECT id FROM users WHERE username = ?`, DeletedUsername).Scan(&id)
+	return id, err
+}
+
+// DeleteAccount removes a user. Their posts, comments and revisions move to
+// the [deleted user] account, their likes are taken back, and everything
+// else belonging to them is deleted. Moderation records they appear in keep
+// pointing at the removed ID; the audit log can't be changed.
+func DeleteAccount(conn *sql.DB, userID int) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedID int
+	if err := tx.QueryRow(`SELECT id FROM users WHERE username = ?`, DeletedUsername).Scan(&deletedID); err != nil {
+		return err
+	}
+
+	// Nobody else saw a shadow-banned user's content, so it is deleted
+	// rather than handed over where everyone would see it
+	now := time.Now().UTC()
+	shadowBanned := `EXISTS (SELECT 1 FROM users WHERE id = ? AND shadow_banned = 1)`
+	for _, q := range []string{
+		`UPDATE posts SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL AND ` + shadowBanned,
+		`UPDATE comments SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL AND ` + shadowBanned,
+	} {
+		if _, err := tx.Exec(q, now, userID, userID); err != nil {
+			return err
+		}
+	}
+
+	// One open report per reporter and target: if [deleted user] already has
+	// one, the user's duplicate goes
+	if _, err := tx.Exec(`
+		DELETE FROM reports WHERE reporter_id = ? AND status = 'open' AND EXISTS (
+			SELECT 1 FROM reports o WHERE o.reporter_id = ? AND o.status = 'open'
+			AND o.target_type = reports.target_type AND o.target_id = reports.target_id)
+	`, userID, deletedID); err != nil {
+		return err
+	}
+
+	handOver := []string{
+		`UPDATE posts SET user_id = ? WHERE user_id = ?`,
+		`UPDATE comments SET user_id = ? WHERE user_id = ?`,
+		`UPDATE post_revisions SET editor_id = ? WHERE editor_id = ?`,
+		`UPDATE reports SET reporter_id = ? WHERE reporter_id = ?`,
+		`UPDATE reports SET resolved_by = ? WHERE resolved_by = ?`,
+		`UPDATE user_bans SET created_by = ? WHERE created_by = ?`,
+		`UPDATE user_bans SET lifted_by = ? WHERE lifted_by = ?`,
+		`UPDATE blocked_words SET created_by = ? WHERE created_by = ?`,
+	}
+	for _, q := range handOver {
+		if _, err := tx.Exec(q, deletedID, userID); err != nil {
+			return err
+		}
+	}
+
+	remove := []string{
+		`DELETE FROM likes WHERE user_id = ?`,
+		`DELETE FROM sessions WHERE user_id = ?`,
+		`DELETE FROM api_tokens WHERE user_id = ?`,
+		`DELETE FROM category_moderators WHERE user_id = ?`,
+		`DELETE FROM user_bans WHERE user_id = ?`,
+		`DELETE FROM login_attempts WHERE user_id = ?`,
+		`DELETE FROM password_resets WHERE user_id = ?`,
+		`DELETE FROM user_totp WHERE user_id = ?`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		`DELETE FROM login_challenges WHERE user_id = ?`,
+		`DELETE FROM user_identities WHERE user_id = ?`,
+		`DELETE FROM oauth_states WHERE link_user_id = ?`,
+		`DELETE FROM username_history WHERE user_id = ?`,
+		`DELETE FROM data_exports WHERE user_id = ?`,
+		`DELETE FROM user_profiles WHERE user_id = ?`,
+		`DELETE FROM user_avatars WHERE user_id = ?`,
+		`DELETE FROM confirmation_codes WHERE user_id = ?`,
+		`DELETE FROM users WHERE id = ?`,
+	}
+	for _, q := range remove {
+		if _, err := tx.Exec(q, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
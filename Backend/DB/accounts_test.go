@@ -0,0 +1,83 @@
+package db_test
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"testing"
+	"time"
+)
+
+func TestDeleteAccountRemovesDependents(t *testing.T) {
+	conn := dbtest.Open(t)
+	aliceID := dbtest.CreateUser(t, conn, "alice")
+	bobID := dbtest.CreateUser(t, conn, "bob")
+	deletedID, err := db.GetDeletedUserID(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+
+	// Something in every table that belongs to alice, and a session for bob
+	setup := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO posts (id, user_id, title, content) VALUES (1, ?, 'Alice post', 'Body')`, []interface{}{aliceID}},
+		{`INSERT INTO posts (id, user_id, title, content) VALUES (2, ?, 'Bob post', 'Body')`, []interface{}{bobID}},
+		{`INSERT INTO comments (id, post_id, user_id, content) VALUES (1, 2, ?, 'Alice comment')`, []interface{}{aliceID}},
+		{`INSERT INTO likes (user_id, post_id, is_like) VALUES (?, 2, 1)`, []interface{}{aliceID}},
+		{`INSERT INTO post_revisions (post_id, editor_id, title, content, categories, created_at) VALUES (1, ?, 'Old', 'Old', '', ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO sessions (token, user_id, expires_at) VALUES ('alice-session', ?, ?)`, []interface{}{aliceID, now.Add(time.Hour)}},
+		{`INSERT INTO sessions (token, user_id, expires_at) VALUES ('bob-session', ?, ?)`, []interface{}{bobID, now.Add(time.Hour)}},
+		{`INSERT INTO api_tokens (user_id, name, token_hash, scopes) VALUES (?, 'script', 'token-hash', 'read')`, []interface{}{aliceID}},
+		{`INSERT INTO category_moderators (user_id, category_id) SELECT ?, id FROM categories WHERE name = 'minecraft'`, []interface{}{aliceID}},
+		{`INSERT INTO reports (reporter_id, target_type, target_id, post_id, reason) VALUES (?, 'post', 2, 2, 'spam')`, []interface{}{aliceID}},
+		{`INSERT INTO user_bans (user_id, kind, reason, created_by) VALUES (?, 'suspension', 'spam', ?)`, []interface{}{bobID, aliceID}},
+		{`INSERT INTO user_bans (user_id, kind, reason, created_by) VALUES (?, 'shadow', 'spam', ?)`, []interface{}{aliceID, bobID}},
+		{`INSERT INTO blocked_words (word, mode, created_by) VALUES ('spam', 'reject', ?)`, []interface{}{aliceID}},
+		{`INSERT INTO login_attempts (user_id, identifier, ip, created_at) VALUES (?, 'alice', '127.0.0.1', ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO password_resets (user_id, token_hash, expires_at, created_at) VALUES (?, 'reset-hash', ?, ?)`, []interface{}{aliceID, now, now}},
+		{`INSERT INTO user_totp (user_id, secret, created_at) VALUES (?, 'SECRET', ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, 'code-hash', ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO login_challenges (token_hash, user_id, identifier, expires_at, created_at) VALUES ('challenge-hash', ?, 'alice', ?, ?)`, []interface{}{aliceID, now, now}},
+		{`INSERT INTO user_identities (user_id, provider, subject, created_at) VALUES (?, 'mock', 'alice-subject', ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO oauth_states (state_hash, provider, nonce, verifier, link_user_id, expires_at, created_at) VALUES ('state-hash', 'mock', 'n', 'v', ?, ?, ?)`, []interface{}{aliceID, now, now}},
+		{`INSERT INTO username_history (user_id, old_username, changed_at) VALUES (?, 'alice_old', ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO data_exports (user_id, created_at) VALUES (?, ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO user_profiles (user_id, bio, updated_at) VALUES (?, 'Hi', ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO user_avatars (user_id, image, updated_at) VALUES (?, x'89504e47', ?)`, []interface{}{aliceID, now}},
+		{`INSERT INTO confirmation_codes (user_id, code_hash, expires_at, created_at) VALUES (?, 'code-hash', ?, ?)`, []interface{}{aliceID, now, now}},
+		{`INSERT INTO moderation_actions (actor_id, action, target_type, target_id) VALUES (?, 'user.suspension', 'user', ?)`, []interface{}{aliceID, bobID}},
+	}
+	for _, s := range setup {
+		if _, err := conn.Exec(s.query, s.args...); err != nil {
+			t.Fatalf("%s: %v", s.query, err)
+		}
+	}
+
+	if err := db.DeleteAccount(conn, aliceID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the append-only moderation log may still name alice
+	for _, ref := range references(t, conn, "users", aliceID) {
+		if ref != "moderation_actions.actor_id" {
+			t.Errorf("%s still references the deleted account", ref)
+		}
+	}
+
+	var owner int
+	if err := conn.QueryRow(`SELECT user_id FROM posts WHERE id = 1`).Scan(&owner); err != nil {
+		t.Fatal(err)
+	}
+	if owner != deletedID {
+		t.Errorf("post owner = %d, want [deleted user] %d", owner, deletedID)
+	}
+	var sessions int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM sessions WHERE user_id = ?`, bobID).Scan(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 1 {
+		t.Errorf("bob has %d sessions, want 1", sessions)
+	}
+}
@@ -0,0 +1,300 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditEntry describes one privileged action for the moderation log
+type AuditEntry struct {
+	ActorID    int    // 0 = the command line
+	Action     string // "<target type>.<verb>", e.g. "post.delete" or "user.role"
+	TargetType string // "post", "comment", "user" or "filter" (a blocked word)
+	TargetID   int
+	Reason     string
+}
+
+// ModerationAction is one entry of the moderation log
+type ModerationAction struct {
+	ID         int
+	ActorID    int
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetID   int
+	Reason     string
+	Before     string // JSON snapshot of the target, empty if not taken
+	After      string
+	CreatedAt  time.Time
+}
+
+// LogModerationAction appends an entry to the moderation log. The table is
+// append-only: triggers reject updates and deletes.
+func LogModerationAction(conn Querier, e AuditEntry, before, after string) error {
+	_, err := conn.Exec(`
+		INSERT INTO moderation_actions (actor_id, action, target_type, target_id, reason, before_state, after_state, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.ActorID, e.Action, e.TargetType, e.TargetID, e.Reason, before, after, time.Now().UTC())
+	return err
+}
+
+// Audited runs change and logs it together with snapshots of the target
+// taken before and after. Everything happens in one transaction, which change
+// must use: if any step fails, nothing is changed and nothing is logged.
+func Audited(conn *sql.DB, e AuditEntry, change func(q Querier) error) error {
+	return inTx(conn, func(tx *sql.Tx) error {
+		before, err := Snapshot(tx, e.TargetType, e.TargetID)
+		if err != nil {
+			return err
+		}
+		if err := change(tx); err != nil {
+			return err
+		}
+		after, err := Snapshot(tx, e.TargetType, e.TargetID)
+		if err != nil {
+			return err
+		}
+		return LogModerationAction(tx, e, before, after)
+	})
+}
+
+// AuditedUpsert is Audited for a change that may create its target. find
+// returns the target's ID, 0 if it doesn't exist yet, and change returns the
+// ID afterwards; a new target is logged with an empty before snapshot.
+func AuditedUpsert(conn *sql.DB, e AuditEntry, find, change func(q Querier) (int, error)) (int, error) {
+	err := inTx(conn, func(tx *sql.Tx) error {
+		id, err := find(tx)
+		if err != nil {
+			return err
+		}
+		before := ""
+		if id != 0 {
+			if before, err = Snapshot(tx, e.TargetType, id); err != nil {
+				return err
+			}
+		}
+		if e.TargetID, err = change(tx); err != nil {
+			return err
+		}
+		after, err := Snapshot(tx, e.TargetType, e.TargetID)
+		if err != nil {
+			return err
+		}
+		return LogModerationAction(tx, e, before, after)
+	})
+	return e.TargetID, err
+}
+
+type postSnapshot struct {
+	AuthorID   int      `json:"author_id"`
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Categories []string `json:"categories"`
+	Hidden     bool     `json:"hidden"`
+	Deleted    bool     `json:"deleted"`
+}
+
+type commentSnapshot struct {
+	AuthorID int    `json:"author_id"`
+	PostID   int    `json:"post_id"`
+	Content  string `json:"content"`
+	Hidden   bool   `json:"hidden"`
+	Deleted  bool   `json:"deleted"`
+}
+
+type userSnapshot struct {
+	Username     string           `json:"username"`
+	Role         string           `json:"role"`
+	Moderates    []string         `json:"moderates"`
+	ShadowBanned bool             `json:"shadow_banned"`
+	Restrictions []banSnapshotRow `json:"restrictions"`
+}
+
+type banSnapshotRow struct {
+	ID        int        `json:"id"`
+	Kind      string     `json:"kind"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Snapshot captures the moderation-relevant state of a target as JSON.
+// Content is stored unmasked so the log survives deletion.
+func Snapshot(conn Querier, targetType string, targetID int) (string, error) {
+	var v interface{}
+	var err error
+	switch targetType {
+	case "post":
+		v, err = snapshotPost(conn, targetID)
+	case "comment":
+		v, err = snapshotComment(conn, targetID)
+	case "user":
+		v, err = snapshotUser(conn, targetID)
+	case "filter":
+		v, err = snapshotBlockedWord(conn, targetID)
+	default:
+		return "", fmt.Errorf("unknown audit target type %q", targetType)
+	}
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func snapshotPost(conn Querier, postID int) (*postSnapshot, error) {
+	var s postSnapshot
+	err := conn.QueryRow(`
+		SELECT user_id, title, content, hidden_at IS NOT NULL, deleted_at IS NOT NULL
+		FROM posts WHERE id = ?
+	`, postID).Scan(&s.AuthorID, &s.Title, &s.Content, &s.Hidden, &s.Deleted)
+	if err != nil {
+		return nil, err
+	}
+	s.Categories, err = GetPostCategories(conn, postID)
+	return &s, err
+}
+
+func snapshotComment(conn Querier, commentID int) (*commentSnapshot, error) {
+	var s commentSnapshot
+	err := conn.QueryRow(`
+		SELECT user_id, post_id, content, hidden_at IS NOT NULL, deleted_at IS NOT NULL
+		FROM comments WHERE id = ?
+	`, commentID).Scan(&s.AuthorID, &s.PostID, &s.Content, &s.Hidden, &s.Deleted)
+	return &s, err
+}
+
+func snapshotUser(conn Querier, userID int) (*userSnapshot, error) {
+	var s userSnapshot
+	err := conn.QueryRow(`SELECT username, role, shadow_banned FROM users WHERE id = ?`, userID).
+		Scan(&s.Username, &s.Role, &s.ShadowBanned)
+	if err != nil {
+		return nil, err
+	}
+	if s.Moderates, err = GetModeratedCategories(conn, userID); err != nil {
+		return nil, err
+	}
+	bans, err := GetActiveBans(conn)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bans {
+		if b.UserID == userID {
+			s.Restrictions = append(s.Restrictions, banSnapshotRow{ID: b.ID, Kind: b.Kind, Reason: b.Reason, ExpiresAt: b.ExpiresAt})
+		}
+	}
+	return &s, nil
+}
+
+// snapshotBlockedWord returns nil (JSON null) once the word is removed
+func snapshotBlockedWord(conn Querier, id int) (map[string]string, error) {
+	var word, mode string
+	err := conn.QueryRow(`SELECT word, mode FROM blocked_words WHERE id = ?`, id).Scan(&word, &mode)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"word": word, "mode": mode}, nil
+}
+
+// AuditFilter narrows the moderation log; zero fields match everything
+type AuditFilter struct {
+	Actor      string // username, or "cli" for the command line
+	Action     string
+	TargetType string
+	TargetID   int
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	Limit      int       // 0 = no limit
+	Offset     int
+}
+
+// GetModerationActions returns log entries matching the filter, newest first
+func GetModerationActions(conn *sql.DB, f AuditFilter) ([]ModerationAction, error) {
+	var conds []string
+	var args []interface{}
+	if f.Actor == "cli" {
+		conds = append(conds, "m.actor_id = 0")
+	} else if f.Actor != "" {
+		conds = append(conds, "LOWER(u.username) = LOWER(?)")
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		conds = append(conds, "m.action = ?")
+		args = append(args, f.Action)
+	}
+	if f.TargetType != "" {
+		conds = append(conds, "m.target_type = ?")
+		args = append(args, f.TargetType)
+	}
+	if f.TargetID > 0 {
+		conds = append(conds, "m.target_id = ?")
+		args = append(args, f.TargetID)
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "m.created_at >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "m.created_at < ?")
+		args = append(args, f.To)
+	}
+
+	query := `
+		SELECT m.id, m.actor_id, COALESCE(u.username, ''), m.action, m.target_type, m.target_id,
+			m.reason, m.before_state, m.after_state, m.created_at
+		FROM moderation_actions m
+		LEFT JOIN users u ON u.id = m.actor_id`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY m.created_at DESC, m.id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
+	}
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []ModerationAction
+	for rows.Next() {
+		var a ModerationAction
+		if err := rows.Scan(&a.ID, &a.ActorID, &a.ActorName, &a.Action, &a.TargetType, &a.TargetID,
+			&a.Reason, &a.Before, &a.After, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		// The log is append-only, so it keeps the IDs of deleted accounts
+		if a.ActorID != 0 && a.ActorName == "" {
+			a.ActorName = DeletedUsername
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
+
+// GetModerationActionNames lists the distinct actions in the log, for filters
+func GetModerationActionNames(conn *sql.DB) ([]string, error) {
+	rows, err := conn.Query(`SELECT DISTINCT action FROM moderation_actions ORDER BY action`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Kinds of account restriction
+const (
+	BanPermanent  = "ban"
+	BanSuspension = "suspension"
+	BanShadow     = "shadow"
+)
+
+// shadowFilter hides content by shadow-banned users (joined as u) from
+// everyone but themselves; it takes the viewer's ID, 0 for guests
+const shadowFilter = "(u.shadow_banned = 0 OR u.id = ?)"
+
+// visibleComments is the condition for comments that count towards a post's
+// total: not deleted, hidden or written by a shadow-banned user
+const visibleComments = "deleted_at IS NULL AND hidden_at IS NULL AND user_id NOT IN (SELECT id FROM users WHERE shadow_banned = 1)"
+
+// Ban is a restriction placed on an account
+type Ban struct {
+	ID          int
+	UserID      int
+	Username    string
+	Kind        string
+	Reason      string
+	ExpiresAt   *time.Time // nil = until lifted
+	CreatedBy   int
+	CreatedName string
+	CreatedAt   time.Time
+}
+
+// CreateBan restricts an account. Shadow-bans are mirrored on the user row.
+func CreateBan(conn Querier, userID int, kind, reason string, expiresAt *time.Time, createdBy int) error {
+	return inTx(conn, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO user_bans (user_id, kind, reason, expires_at, created_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, userID, kind, reason, expiresAt, createdBy, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if kind == BanShadow {
+			_, err = tx.Exec(`UPDATE users SET shadow_banned = 1 WHERE id = ?`, userID)
+		}
+		return err
+	})
+}
+
+// GetBan returns an active restriction by ID, or nil if it was lifted or
+// does not exist
+func GetBan(conn *sql.DB, banID int) (*Ban, error) {
+	var b Ban
+	err := conn.QueryRow(`
+		SELECT b.id, b.user_id, u.username, b.kind, b.reason
+		FROM user_bans b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.id = ? AND b.lifted_at IS NULL
+	`, banID).Scan(&b.ID, &b.UserID, &b.Username, &b.Kind, &b.Reason)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &b, err
+}
+
+// LiftBan ends a restriction early. It returns the lifted ban, or nil if it
+// was not active.
+func LiftBan(conn Querier, banID, liftedBy int) (*Ban, error) {
+	var lifted *Ban
+	err := inTx(conn, func(tx *sql.Tx) error {
+		var b Ban
+		err := tx.QueryRow(`SELECT id, user_id, kind, reason FROM user_bans WHERE id = ? AND lifted_at IS NULL`, banID).
+			Scan(&b.ID, &b.UserID, &b.Kind, &b.Reason)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE user_bans SET lifted_by = ?, lifted_at = ? WHERE id = ?`, liftedBy, time.Now().UTC(), banID); err != nil {
+			return err
+		}
+		if b.Kind == BanShadow {
+			_, err = tx.Exec(`
+				UPDATE users SET shadow_banned = EXISTS(
+					SELECT 1 FROM user_bans WHERE user_id = ? AND kind = 'shadow' AND lifted_at IS NULL
+				) WHERE id = ?
+			`, b.UserID, b.UserID)
+			if err != nil {
+				return err
+			}
+		}
+		lifted = &b
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lifted, nil
+}
+
+// activeBans selects restrictions that are neither lifted nor expired
+const activeBans = `
+	SELECT b.id, b.user_id, u.username, b.kind, b.reason, b.expires_at, b.created_by, m.username, b.created_at
+	FROM user_bans b
+	JOIN users u ON u.id = b.user_id
+	JOIN users m ON m.id = b.created_by
+	WHERE b.lifted_at IS NULL AND (b.expires_at IS NULL OR b.expires_at > ?)`
+
+// GetActiveBan returns the ban or suspension currently locking the user out,
+// or nil. Permanent bans win over suspensions, then the longest suspension.
+func GetActiveBan(conn *sql.DB, userID int) (*Ban, error) {
+	rows, err := conn.Query(activeBans+`
+		AND b.user_id = ? AND b.kind != 'shadow'
+		ORDER BY b.kind = 'ban' DESC, b.expires_at DESC
+		LIMIT 1
+	`, time.Now().UTC(), userID)
+	if err != nil {
+		return nil, err
+	}
+	bans, err := scanBans(rows)
+	if err != nil || len(bans) == 0 {
+		return nil, err
+	}
+	return &bans[0], nil
+}
+
+// GetActiveBans lists every active restriction, newest first
+func GetActiveBans(conn Querier) ([]Ban, error) {
+	rows, err := conn.Query(activeBans+` ORDER BY b.created_at DESC`, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanBans(rows)
+}
+
+func scanBans(rows *sql.Rows) ([]Ban, error) {
+	defer rows.Close()
+	var bans []Ban
+	for rows.Next() {
+		var b Ban
+		var expires sql.NullTime
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Username, &b.Kind, &b.Reason, &expires, &b.CreatedBy, &b.CreatedName, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		if expires.Valid {
+			b.ExpiresAt = &expires.Time
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
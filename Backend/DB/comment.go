@@ -3,10 +3,85 @@ package db
 import "database/sql"
 
 // AddComment adds a comment to a post
-func AddComment(conn *sql.DB, postID, userID int, content string) error {
-	_, err := conn.Exec(`
+func AddComment(conn Querier, postID, userID int, content string) (int, error) {
+	res, err := conn.Exec(`
 		INSERT INTO comments (post_id, user_id, content)
 		VALUES (?, ?, ?)
 	`, postID, userID, content)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// AddReply adds a comment answering another comment on the same post
+func AddReply(conn Querier, postID, parentID, userID int, content string) (int, error) {
+	res, err := conn.Exec(`
+		INSERT INTO comments (post_id, parent_id, user_id, content)
+		VALUES (?, ?, ?, ?)
+	`, postID, parentID, userID, content)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetCommentTree fetches the comments of a post nested under their parents.
+// At most maxDepth levels are built; replies that would go deeper are listed
+// in order on the last level, so long chains stay readable without losing any comment.
+func GetCommentTree(conn *sql.DB, postID, maxDepth int) ([]Comment, error) {
+	flat, err := GetCommentsForPost(conn, postID)
+	if err != nil {
+		return nil, err
+	}
+	if maxDepth < 2 {
+		maxDepth = 2
+	}
+
+	children := make(map[int][]int) // parent ID -> indexes into flat
+	byID := make(map[int]bool, len(flat))
+	for _, c := range flat {
+		byID[c.ID] = true
+	}
+	var roots []int
+	for i, c := range flat {
+		// Orphans (parent purged or missing) are shown as top-level comments
+		if c.ParentID == nil || !byID[*c.ParentID] {
+			roots = append(roots, i)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], i)
+	}
+
+	var build func(idx, depth int) Comment
+	build = func(idx, depth int) Comment {
+		c := flat[idx]
+		c.Depth = depth
+		if depth < maxDepth-2 {
+			for _, child := range children[c.ID] {
+				c.Replies = append(c.Replies, build(child, depth+1))
+			}
+			return c
+		}
+		// Replies land on the last level: pull the whole subtree up to it, in order
+		var collect func(id int)
+		collect = func(id int) {
+			for _, child := range children[id] {
+				reply := flat[child]
+				reply.Depth = depth + 1
+				c.Replies = append(c.Replies, reply)
+				collect(reply.ID)
+			}
+		}
+		collect(c.ID)
+		return c
+	}
+
+	tree := make([]Comment, 0, len(roots))
+	for _, idx := range roots {
+		tree = append(tree, build(idx, 0))
+	}
+	return tree, nil
 }
@@ -0,0 +1,75 @@
+package db_test
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shape renders a comment tree as "a(b c) d", checking each comment's depth
+func shape(t *testing.T, comments []db.Comment, depth int) string {
+	t.Helper()
+	parts := make([]string, len(comments))
+	for i, c := range comments {
+		if c.Depth != depth {
+			t.Errorf("comment %q has depth %d, want %d", c.Content, c.Depth, depth)
+		}
+		parts[i] = c.Content
+		if len(c.Replies) > 0 {
+			parts[i] += "(" + shape(t, c.Replies, depth+1) + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestGetCommentTreeCapsDepth(t *testing.T) {
+	conn := dbtest.Open(t)
+	userID := dbtest.CreateUser(t, conn, "alice")
+	if _, err := conn.Exec(`INSERT INTO posts (id, user_id, title, content) VALUES (1, ?, 'Post', 'Body')`, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	// a <- b <- c <- d <- e is a chain of replies, f answers b after c,
+	// g is another comment and h answers a purged comment
+	comments := []struct {
+		id, parent int
+		content    string
+	}{
+		{1, 0, "a"}, {2, 1, "b"}, {3, 2, "c"}, {4, 3, "d"}, {5, 4, "e"}, {6, 2, "f"}, {7, 0, "g"}, {8, 99, "h"},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, c := range comments {
+		var parent interface{}
+		if c.parent != 0 {
+			parent = c.parent
+		}
+		_, err := conn.Exec(`INSERT INTO comments (id, post_id, parent_id, user_id, content, created_at) VALUES (?, 1, ?, ?, ?, ?)`,
+			c.id, parent, userID, c.content, start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		maxDepth int
+		want     string
+	}{
+		{0, "a(b c d e f) g h"},
+		{2, "a(b c d e f) g h"},
+		{3, "a(b(c d e f)) g h"},
+		{4, "a(b(c(d e) f)) g h"},
+		{5, "a(b(c(d(e)) f)) g h"},
+		{10, "a(b(c(d(e)) f)) g h"},
+	}
+	for _, tt := range tests {
+		tree, err := db.GetCommentTree(conn, 1, tt.maxDepth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := shape(t, tree, 0); got != tt.want {
+			t.Errorf("maxDepth %d: tree = %s, want %s", tt.maxDepth, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SetConfirmationCode stores a user's confirmation code by its hash,
+// replacing the one they had
+func SetConfirmationCode(conn *sql.DB, userID int, codeHash string, expiresAt time.Time) error {
+	_, err := conn.Exec(`
+		INSERT OR REPLACE INTO confirmation_codes (user_id, code_hash, attempts, expires_at, created_at)
+		VALUES (?, ?, 0, ?, ?)
+	`, userID, codeHash, expiresAt.UTC(), time.Now().UTC())
+	return err
+}
+
+// UseConfirmationCode checks a user's confirmation code and uses it up.
+// Wrong guesses are counted, and after maxAttempts of them the code stops
+// working.
+func UseConfirmationCode(conn *sql.DB, userID int, codeHash string, maxAttempts int) (bool, error) {
+	res, err := conn.Exec(`
+		DELETE FROM confirmation_codes
+		WHERE user_id = ? AND code_hash = ? AND attempts < ? AND expires_at > ?
+	`, userID, codeHash, maxAttempts, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return n > 0, err
+	}
+	_, err = conn.Exec(`UPDATE confirmation_codes SET attempts = attempts + 1 WHERE user_id = ?`, userID)
+	return false, err
+}
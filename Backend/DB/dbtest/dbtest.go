@@ -0,0 +1,63 @@
+// Package dbtest sets up databases for tests of the other packages
+package dbtest
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/DB/migrations"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Open returns an empty, migrated database that is removed after the test
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := migrations.Up(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// Use opens a database like Open and makes it db.DB, for code that works on
+// the shared connection
+func Use(t testing.TB) *sql.DB {
+	t.Helper()
+	conn := Open(t)
+	db.DB = conn
+	return conn
+}
+
+// CreateUser registers an account with an unusable password and returns
+// its ID. Migrations create accounts too, so IDs are not fixed.
+func CreateUser(t testing.TB, conn *sql.DB, username string) int {
+	t.Helper()
+	res, err := conn.Exec(`INSERT INTO users (username, email, password) VALUES (?, ?, 'not-a-real-hash')`,
+		username, username+"@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// SessionCookie starts a session for the user, as logging in would, and
+// returns the cookie that carries it
+func SessionCookie(t testing.TB, conn *sql.DB, userID int) *http.Cookie {
+	t.Helper()
+	token := "test-session-" + strconv.Itoa(userID) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := db.CreateSession(conn, userID, token, time.Now().UTC().Add(time.Hour), false, "", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: "session_token", Value: token}
+}
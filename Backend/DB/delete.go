@@ -0,0 +1,91 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DeletedPlaceholder replaces the title, content and author of soft-deleted rows
+const DeletedPlaceholder = "[deleted]"
+
+// GetPostAuthorID returns the author of a post that has not been deleted
+func GetPostAuthorID(conn *sql.DB, postID int) (int, error) {
+	var userID int
+	err := conn.QueryRow(`SELECT user_id FROM posts WHERE id=? AND deleted_at IS NULL`, postID).Scan(&userID)
+	return userID, err
+}
+
+// GetCommentAuthor returns the author and post of a comment that has not been deleted
+func GetCommentAuthor(conn *sql.DB, commentID int) (userID, postID int, err error) {
+	err = conn.QueryRow(`SELECT user_id, post_id FROM comments WHERE id=? AND deleted_at IS NULL`, commentID).
+		Scan(&userID, &postID)
+	return userID, postID, err
+}
+
+// GetReplyParent returns the post of a comment that may be replied to: one
+// that is neither deleted nor hidden
+func GetReplyParent(conn *sql.DB, commentID int) (postID int, err error) {
+	err = conn.QueryRow(`SELECT post_id FROM comments WHERE id = ? AND deleted_at IS NULL AND hidden_at IS NULL`, commentID).
+		Scan(&postID)
+	return postID, err
+}
+
+// SoftDeletePost marks a post as deleted, keeping the row and its comments in place
+func SoftDeletePost(conn Querier, postID int, deletedAt time.Time) error {
+	_, err := conn.Exec(`UPDATE posts SET deleted_at=? WHERE id=? AND deleted_at IS NULL`, deletedAt, postID)
+	return err
+}
+
+// SoftDeleteComment marks a comment as deleted, keeping its place in the thread
+func SoftDeleteComment(conn Querier, commentID int, deletedAt time.Time) error {
+	_, err := conn.Exec(`UPDATE comments SET deleted_at=? WHERE id=? AND deleted_at IS NULL`, deletedAt, commentID)
+	return err
+}
+
+// PurgeDeleted hard-deletes posts and comments soft-deleted before the cutoff,
+// together with their likes, comments, category links and revisions
+func PurgeDeleted(conn *sql.DB, cutoff time.Time) (posts int64, comments int64, err error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	purgeable := `SELECT id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+	steps := []string{
+		`DELETE FROM likes WHERE comment_id IN (SELECT id FROM comments WHERE post_id IN (` + purgeable + `))`,
+		`DELETE FROM comments WHERE post_id IN (` + purgeable + `)`,
+		`DELETE FROM likes WHERE post_id IN (` + purgeable + `)`,
+		`DELETE FROM post_categories WHERE post_id IN (` + purgeable + `)`,
+		`DELETE FROM post_revisions WHERE post_id IN (` + purgeable + `)`,
+	}
+	for _, q := range steps {
+		if _, err := tx.Exec(q, cutoff); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	res, err := tx.Exec(`DELETE FROM posts WHERE id IN (`+purgeable+`)`, cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+	posts, _ = res.RowsAffected()
+
+	// Individually deleted comments on posts that are still live. Tombstones that
+	// still have replies are kept so the thread structure stays intact.
+	purgeableComments := `
+		SELECT c.id FROM comments c
+		WHERE c.deleted_at IS NOT NULL AND c.deleted_at < ?
+		AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+	`
+	if _, err := tx.Exec(`DELETE FROM likes WHERE comment_id IN (`+purgeableComments+`)`, cutoff); err != nil {
+		return 0, 0, err
+	}
+	res, err = tx.Exec(`DELETE FROM comments WHERE id IN (`+purgeableComments+`)`, cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+	comments, _ = res.RowsAffected()
+
+	return posts, comments, tx.Commit()
+}
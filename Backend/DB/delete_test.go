@@ -0,0 +1,118 @@
+package db_test
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"testing"
+	"time"
+)
+
+// references lists the foreign key columns, as "table.column", that still
+// hold id as a key of parent. Foreign keys aren't enforced, so deletes must
+// leave none behind.
+func references(t *testing.T, conn *sql.DB, parent string, id int) []string {
+	t.Helper()
+	rows, err := conn.Query(`
+		SELECT m.name, f."from"
+		FROM sqlite_master m, pragma_foreign_key_list(m.name) f
+		WHERE m.type = 'table' AND f."table" = ?
+	`, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var columns [][2]string
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			t.Fatal(err)
+		}
+		columns = append(columns, [2]string{table, column})
+	}
+	rows.Close()
+
+	var found []string
+	for _, c := range columns {
+		var n int
+		if err := conn.QueryRow(`SELECT COUNT(*) FROM "`+c[0]+`" WHERE "`+c[1]+`" = ?`, id).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n > 0 {
+			found = append(found, c[0]+"."+c[1])
+		}
+	}
+	return found
+}
+
+func TestPurgeDeletedRemovesDependents(t *testing.T) {
+	conn := dbtest.Open(t)
+	userID := dbtest.CreateUser(t, conn, "alice")
+	old := time.Now().UTC().AddDate(0, 0, -60)
+	recent := time.Now().UTC()
+	cutoff := time.Now().UTC().AddDate(0, 0, -30)
+
+	setup := []struct {
+		query string
+		args  []interface{}
+	}{
+		// Post 1 was deleted long ago, post 2 recently; post 3 is live
+		{`INSERT INTO posts (id, user_id, title, content, deleted_at) VALUES (1, ?, 'Old', 'Body', ?)`, []interface{}{userID, old}},
+		{`INSERT INTO posts (id, user_id, title, content, deleted_at) VALUES (2, ?, 'Recent', 'Body', ?)`, []interface{}{userID, recent}},
+		{`INSERT INTO posts (id, user_id, title, content) VALUES (3, ?, 'Live', 'Body')`, []interface{}{userID}},
+		{`INSERT INTO post_categories (post_id, category_id) SELECT p.id, MIN(c.id) FROM posts p, categories c GROUP BY p.id`, nil},
+		{`INSERT INTO post_revisions (post_id, editor_id, title, content, categories, created_at) VALUES (1, ?, 'v1', 'v1', '', ?)`, []interface{}{userID, old}},
+		{`INSERT INTO likes (user_id, post_id, is_like) VALUES (?, 1, 1)`, []interface{}{userID}},
+		// A live comment on the purged post, and on the live post one
+		// deleted leaf and one deleted comment that still has a reply
+		{`INSERT INTO comments (id, post_id, user_id, content) VALUES (1, 1, ?, 'On old post')`, []interface{}{userID}},
+		{`INSERT INTO likes (user_id, comment_id, is_like) VALUES (?, 1, 1)`, []interface{}{userID}},
+		{`INSERT INTO comments (id, post_id, user_id, content, deleted_at) VALUES (2, 3, ?, 'Deleted leaf', ?)`, []interface{}{userID, old}},
+		{`INSERT INTO likes (user_id, comment_id, is_like) VALUES (?, 2, 0)`, []interface{}{userID}},
+		{`INSERT INTO comments (id, post_id, user_id, content, deleted_at) VALUES (3, 3, ?, 'Deleted parent', ?)`, []interface{}{userID, old}},
+		{`INSERT INTO comments (id, post_id, parent_id, user_id, content) VALUES (4, 3, 3, ?, 'Reply')`, []interface{}{userID}},
+	}
+	for _, s := range setup {
+		if _, err := conn.Exec(s.query, s.args...); err != nil {
+			t.Fatalf("%s: %v", s.query, err)
+		}
+	}
+
+	posts, comments, err := db.PurgeDeleted(conn, cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posts != 1 || comments != 1 {
+		t.Errorf("purged %d posts and %d comments, want 1 and 1", posts, comments)
+	}
+
+	for _, ref := range references(t, conn, "posts", 1) {
+		t.Errorf("%s still references the purged post", ref)
+	}
+	for _, id := range []int{1, 2} {
+		for _, ref := range references(t, conn, "comments", id) {
+			t.Errorf("%s still references purged comment %d", ref, id)
+		}
+	}
+
+	var left []int
+	rows, err := conn.Query(`SELECT id FROM comments ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		left = append(left, id)
+	}
+	rows.Close()
+	if len(left) != 2 || left[0] != 3 || left[1] != 4 {
+		t.Errorf("comments left = %v, want [3 4]", left)
+	}
+	for _, id := range []int{2, 3} {
+		if refs := references(t, conn, "posts", id); len(refs) == 0 {
+			t.Errorf("post %d lost its categories", id)
+		}
+	}
+}
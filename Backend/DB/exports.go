@@ -0,0 +1,296 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// States of a data export
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is a "download my data" request, without its archive
+type DataExport struct {
+	ID         int
+	UserID     int
+	Status     string
+	Size       int
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+	ExpiresAt  *time.Time
+}
+
+// CreateDataExport queues an export for the user. It returns false if one
+// is already waiting to be built.
+func CreateDataExport(conn *sql.DB, userID int) (bool, error) {
+	res, err := conn.Exec(`
+		INSERT INTO data_exports (user_id, status, created_at)
+		SELECT ?, ?, ? WHERE NOT EXISTS (
+			SELECT 1 FROM data_exports WHERE user_id = ? AND status = ?)
+	`, userID, ExportPending, time.Now().UTC(), userID, ExportPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetDataExports lists the user's exports that haven't expired, newest first
+func GetDataExports(conn *sql.DB, userID int) ([]DataExport, error) {
+	rows, err := conn.Query(`
+		SELECT id, user_id, status, COALESCE(LENGTH(archive), 0), created_at, finished_at, expires_at
+		FROM data_exports
+		WHERE user_id = ? AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC, id DESC
+	`, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []DataExport
+	for rows.Next() {
+		var e DataExport
+		var finished, expires sql.NullTime
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Status, &e.Size, &e.CreatedAt, &finished, &expires); err != nil {
+			return nil, err
+		}
+		if finished.Valid {
+			e.FinishedAt = &finished.Time
+		}
+		if expires.Valid {
+			e.ExpiresAt = &expires.Time
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// GetDataExportArchive returns the ZIP archive of a finished export that
+// belongs to the user and hasn't expired
+func GetDataExportArchive(conn *sql.DB, id, userID int) ([]byte, time.Time, error) {
+	var archive []byte
+	var finished time.Time
+	err := conn.QueryRow(`
+		SELECT archive, finished_at FROM data_exports
+		WHERE id = ? AND user_id = ? AND status = ? AND expires_at > ?
+	`, id, userID, ExportReady, time.Now().UTC()).Scan(&archive, &finished)
+	return archive, finished, err
+}
+
+// GetPendingDataExports returns the exports waiting to be built, oldest first
+func GetPendingDataExports(conn *sql.DB) ([]DataExport, error) {
+	rows, err := conn.Query(`
+		SELECT id, user_id, created_at FROM data_exports WHERE status = ? ORDER BY id
+	`, ExportPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []DataExport
+	for rows.Next() {
+		e := DataExport{Status: ExportPending}
+		if err := rows.Scan(&e.ID, &e.UserID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// FinishDataExport stores the archive of an export, downloadable until expires
+func FinishDataExport(conn *sql.DB, id int, archive []byte, expires time.Time) error {
+	_, err := conn.Exec(`
+		UPDATE data_exports SET status = ?, archive = ?, finished_at = ?, expires_at = ? WHERE id = ?
+	`, ExportReady, archive, time.Now().UTC(), expires.UTC(), id)
+	return err
+}
+
+// FailDataExport marks an export that couldn't be built. It is shown until
+// expires, so the user knows to try again.
+func FailDataExport(conn *sql.DB, id int, expires time.Time) error {
+	_, err := conn.Exec(`
+		UPDATE data_exports SET status = ?, finished_at = ?, expires_at = ? WHERE id = ?
+	`, ExportFailed, time.Now().UTC(), expires.UTC(), id)
+	return err
+}
+
+// DeleteExpiredDataExports removes exports, and their archives, that expired before now
+func DeleteExpiredDataExports(conn *sql.DB, now time.Time) (int64, error) {
+	res, err := conn.Exec(`DELETE FROM data_exports WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// -------------------- Export contents --------------------
+
+// ExportedPost is a post as written to a data export
+type ExportedPost struct {
+	ID         int        `json:"id"`
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	Categories []string   `json:"categories"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ExportedComment is a comment as written to a data export
+type ExportedComment struct {
+	ID        int        `json:"id"`
+	PostID    int        `json:"post_id"`
+	ParentID  *int       `json:"parent_id,omitempty"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ExportedLike is a like or dislike as written to a data export
+type ExportedLike struct {
+	PostID    *int `json:"post_id,omitempty"`
+	CommentID *int `json:"comment_id,omitempty"`
+	Like      bool `json:"like"` // false = dislike
+}
+
+// ExportedSession is a login session as written to a data export. The
+// token is left out: it would log in whoever reads the archive.
+type ExportedSession struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	Remember   bool       `json:"remember_me"`
+}
+
+// GetExportPosts returns every post the user wrote that hasn't been purged,
+// including deleted and hidden ones
+func GetExportPosts(conn *sql.DB, userID int) ([]ExportedPost, error) {
+	rows, err := conn.Query(`
+		SELECT p.id, p.title, p.content, GROUP_CONCAT(c.name, ','), p.created_at, p.updated_at, p.deleted_at
+		FROM posts p
+		LEFT JOIN post_categories pc ON p.id = pc.post_id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE p.user_id = ?
+		GROUP BY p.id
+		ORDER BY p.created_at, p.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []ExportedPost{}
+	for rows.Next() {
+		var p ExportedPost
+		var categories sql.NullString
+		var updated, deleted sql.NullTime
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &categories, &p.CreatedAt, &updated, &deleted); err != nil {
+			return nil, err
+		}
+		p.Categories = []string{}
+		if categories.Valid && categories.String != "" {
+			p.Categories = parseCategories(categories.String)
+		}
+		p.UpdatedAt = nullTimePtr(updated)
+		p.DeletedAt = nullTimePtr(deleted)
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// GetExportComments returns every comment the user wrote that hasn't been purged
+func GetExportComments(conn *sql.DB, userID int) ([]ExportedComment, error) {
+	rows, err := conn.Query(`
+		SELECT id, post_id, parent_id, content, created_at, deleted_at
+		FROM comments WHERE user_id = ? ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []ExportedComment{}
+	for rows.Next() {
+		var c ExportedComment
+		var parent sql.NullInt64
+		var deleted sql.NullTime
+		if err := rows.Scan(&c.ID, &c.PostID, &parent, &c.Content, &c.CreatedAt, &deleted); err != nil {
+			return nil, err
+		}
+		if parent.Valid {
+			id := int(parent.Int64)
+			c.ParentID = &id
+		}
+		c.DeletedAt = nullTimePtr(deleted)
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// GetExportLikes returns the user's likes and dislikes on posts and comments
+func GetExportLikes(conn *sql.DB, userID int) ([]ExportedLike, error) {
+	rows, err := conn.Query(`SELECT post_id, comment_id, is_like FROM likes WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	likes := []ExportedLike{}
+	for rows.Next() {
+		var l ExportedLike
+		var postID, commentID sql.NullInt64
+		if err := rows.Scan(&postID, &commentID, &l.Like); err != nil {
+			return nil, err
+		}
+		if postID.Valid {
+			id := int(postID.Int64)
+			l.PostID = &id
+		}
+		if commentID.Valid {
+			id := int(commentID.Int64)
+			l.CommentID = &id
+		}
+		likes = append(likes, l)
+	}
+	return likes, rows.Err()
+}
+
+// GetExportSessions returns the user's sessions, expired ones included until
+// the sweeper deletes them
+func GetExportSessions(conn *sql.DB, userID int) ([]ExportedSession, error) {
+	rows, err := conn.Query(`
+		SELECT created_at, last_seen_at, expires_at, user_agent, ip, remember
+		FROM sessions WHERE user_id = ? ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []ExportedSession{}
+	for rows.Next() {
+		var s ExportedSession
+		var lastSeen, expires sql.NullTime
+		if err := rows.Scan(&s.CreatedAt, &lastSeen, &expires, &s.UserAgent, &s.IP, &s.Remember); err != nil {
+			return nil, err
+		}
+		s.LastSeenAt = nullTimePtr(lastSeen)
+		s.ExpiresAt = nullTimePtr(expires)
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
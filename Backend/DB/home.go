@@ -7,155 +7,223 @@ import (
 	"time"
 )
 
-// -------------------- Fetch with stats --------------------
+// -------------------- Pagination --------------------
 
-// FetchPostsByCategory fetches posts filtered by a single category
-func FetchPostsByCategory(conn *sql.DB, category string, userID *int) ([]PostShow, error) {
-	var posts []PostShow
-	var rows *sql.Rows
-	var err error
+// PageRequest selects one page of a feed ordered newest first.
+// Before and After are post IDs used as keyset cursors on (created_at, id);
+// at most one of them should be set.
+type PageRequest struct {
+	Before int // posts older than this post
+	After  int // posts newer than this post
+	Limit  int
+}
+
+// PageInfo describes the neighbours of a fetched page
+type PageInfo struct {
+	HasOlder    bool
+	HasNewer    bool
+	OlderCursor int // pass as Before to get the next (older) page
+	NewerCursor int // pass as After to get the previous (newer) page
+}
 
+// DefaultPageSize is used when a PageRequest has no limit
+const DefaultPageSize = 20
+
+// -------------------- Fetch with stats --------------------
+
+// FetchPostsByCategory fetches one page of posts filtered by a single category
+func FetchPostsByCategory(conn *sql.DB, category string, userID *int, page PageRequest) ([]PostShow, PageInfo, error) {
 	if category == "" {
-		rows, err = conn.Query(`
-			SELECT p.id, u.username, p.title, p.content, p.created_at,
+		return fetchPostPage(conn, `
+			SELECT p.id, p.user_id, u.username, u.avatar_version, p.title, p.content, p.created_at,
 				COALESCE(GROUP_CONCAT(DISTINCT c.name), 'general') AS categories
 			FROM posts p
 			JOIN users u ON u.id = p.user_id
 			LEFT JOIN post_categories pc ON p.id = pc.post_id
 			LEFT JOIN categories c ON c.id = pc.category_id
+			WHERE p.deleted_at IS NULL AND p.hidden_at IS NULL %s
 			GROUP BY p.id
-			ORDER BY p.created_at DESC
-		`)
-	} else {
-		rows, err = conn.Query(`
-			SELECT p.id, u.username, p.title, p.content, p.created_at,
-				COALESCE(GROUP_CONCAT(DISTINCT c.name), 'general') AS categories
-			FROM posts p
-			JOIN users u ON u.id = p.user_id
-			JOIN post_categories pc ON p.id = pc.post_id
-			JOIN categories c ON pc.category_id = c.id
-			WHERE LOWER(TRIM(c.name)) = LOWER(TRIM(?))
-			GROUP BY p.id
-			ORDER BY p.created_at DESC
-		`, category)
+			ORDER BY p.created_at %s, p.id %s
+			LIMIT ?
+		`, nil, userID, page)
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("query error: %w", err)
-	}
-	defer rows.Close()
+	return fetchPostPage(conn, `
+		SELECT p.id, p.user_id, u.username, u.avatar_version, p.title, p.content, p.created_at,
+			COALESCE(GROUP_CONCAT(DISTINCT c.name), 'general') AS categories
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		JOIN post_categories pc ON p.id = pc.post_id
+		JOIN categories c ON pc.category_id = c.id
+		WHERE LOWER(TRIM(c.name)) = LOWER(TRIM(?)) AND p.deleted_at IS NULL AND p.hidden_at IS NULL %s
+		GROUP BY p.id
+		ORDER BY p.created_at %s, p.id %s
+		LIMIT ?
+	`, []interface{}{category}, userID, page)
+}
 
-	var postIDs []int
-	for rows.Next() {
-		var p PostShow
-		var categoriesStr string
-		var created time.Time
-		if err := rows.Scan(&p.ID, &p.Username, &p.Title, &p.Content, &created, &categoriesStr); err != nil {
-			return nil, err
-		}
-		p.CreatedAt = created
-		if categoriesStr != "" {
-			parts := strings.Split(categoriesStr, ",")
-			for i := range parts {
-				parts[i] = strings.TrimSpace(parts[i])
-			}
-			p.Categories = parts
-		} else {
-			p.Categories = []string{"general"}
-		}
-		posts = append(posts, p)
-		postIDs = append(postIDs, p.ID)
+// FetchPostsByCategories fetches one page of posts matching multiple categories
+func FetchPostsByCategories(conn *sql.DB, categories []string, userID *int, page PageRequest) ([]PostShow, PageInfo, error) {
+	if len(categories) == 0 {
+		return FetchPostsByCategory(conn, "", userID, page)
 	}
 
-	if len(posts) == 0 {
-		return posts, nil
+	filter, args := categoryFilter(categories)
+	if filter == "" {
+		return FetchPostsByCategory(conn, "", userID, page)
 	}
 
-	if err := populateStats(conn, posts, postIDs, userID); err != nil {
-		return nil, err
-	}
+	query := `
+		SELECT DISTINCT p.id, p.user_id, u.username, u.avatar_version, p.title, p.content, p.created_at,
+		       COALESCE(GROUP_CONCAT(DISTINCT c.name), 'general') AS categories
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		LEFT JOIN post_categories pc ON pc.post_id = p.id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE ` + filter + ` AND p.deleted_at IS NULL AND p.hidden_at IS NULL %s
+		GROUP BY p.id
+		ORDER BY p.created_at %s, p.id %s
+		LIMIT ?
+	`
 
-	return posts, nil
+	return fetchPostPage(conn, query, args, userID, page)
 }
 
-// FetchPostsByCategories fetches posts matching multiple categories
-func FetchPostsByCategories(conn *sql.DB, categories []string, userID *int) ([]PostShow, error) {
-	if len(categories) == 0 {
-		return FetchPostsByCategory(conn, "", userID)
-	}
-
-	var cleanCats []string
+// categoryFilter builds a condition on p.id matching posts in any of the given
+// categories. It returns an empty condition when no usable category is given.
+func categoryFilter(categories []string) (string, []interface{}) {
+	var args []interface{}
 	for _, c := range categories {
 		cleaned := strings.ToLower(strings.TrimSpace(c))
 		if cleaned != "" {
-			cleanCats = append(cleanCats, cleaned)
+			args = append(args, cleaned)
 		}
 	}
-	if len(cleanCats) == 0 {
-		return FetchPostsByCategory(conn, "", userID)
+	if len(args) == 0 {
+		return "", nil
 	}
 
-	placeholders := strings.Trim(strings.Repeat("?,", len(cleanCats)), ",")
-	query := fmt.Sprintf(`
-		SELECT DISTINCT p.id, u.username, p.title, p.content, p.created_at,
-		       COALESCE(GROUP_CONCAT(DISTINCT c.name), 'general') AS categories
-		FROM posts p
-		JOIN users u ON u.id = p.user_id
-		LEFT JOIN post_categories pc ON pc.post_id = p.id
-		LEFT JOIN categories c ON pc.category_id = c.id
-		WHERE p.id IN (
+	placeholders := strings.Trim(strings.Repeat("?,", len(args)), ",")
+	return `p.id IN (
 			SELECT pc2.post_id
 			FROM post_categories pc2
 			JOIN categories c2 ON pc2.category_id = c2.id
-			WHERE LOWER(TRIM(c2.name)) IN (%s)
-		)
-		GROUP BY p.id
-		ORDER BY p.created_at DESC
-	`, placeholders)
+			WHERE LOWER(TRIM(c2.name)) IN (` + placeholders + `)
+		)`, args
+}
+
+// fetchPostPage runs a feed query with keyset pagination and fills in stats.
+// The query must contain three %s verbs: an extra WHERE condition and the
+// created_at / id sort directions, followed by a LIMIT ? placeholder. The
+// post author must be joined as u.
+func fetchPostPage(conn *sql.DB, query string, args []interface{}, userID *int, page PageRequest) ([]PostShow, PageInfo, error) {
+	var info PageInfo
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
 
-	args := make([]interface{}, len(cleanCats))
-	for i, c := range cleanCats {
-		args[i] = c
+	// A cursor post that was purged since has no created_at to compare with,
+	// which would match nothing; start over at the first page instead
+	cursor := page.After
+	if cursor == 0 {
+		cursor = page.Before
+	}
+	if cursor > 0 {
+		var exists bool
+		if err := conn.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE id = ?)`, cursor).Scan(&exists); err != nil {
+			return nil, info, fmt.Errorf("query error: %w", err)
+		}
+		if !exists {
+			page.After, page.Before = 0, 0
+		}
+	}
+
+	// Walking towards newer posts reads in ascending order, then flips the result
+	keyset, dir := "", "DESC"
+	cursorArgs := []interface{}{}
+	switch {
+	case page.After > 0:
+		keyset, dir = "AND (p.created_at, p.id) > ((SELECT created_at FROM posts WHERE id = ?), ?)", "ASC"
+		cursorArgs = append(cursorArgs, page.After, page.After)
+	case page.Before > 0:
+		keyset = "AND (p.created_at, p.id) < ((SELECT created_at FROM posts WHERE id = ?), ?)"
+		cursorArgs = append(cursorArgs, page.Before, page.Before)
 	}
 
-	rows, err := conn.Query(query, args...)
+	// Shadow-banned authors still see their own posts in feeds
+	viewer := 0
+	if userID != nil {
+		viewer = *userID
+	}
+	keyset = "AND " + shadowFilter + " " + keyset
+	cursorArgs = append([]interface{}{viewer}, cursorArgs...)
+
+	fullArgs := append(append(append([]interface{}{}, args...), cursorArgs...), limit+1)
+	rows, err := conn.Query(fmt.Sprintf(query, keyset, dir, dir), fullArgs...)
 	if err != nil {
-		return nil, err
+		return nil, info, fmt.Errorf("query error: %w", err)
 	}
 	defer rows.Close()
 
 	var posts []PostShow
-	var postIDs []int
 	for rows.Next() {
 		var p PostShow
 		var categoriesStr string
 		var created time.Time
-		if err := rows.Scan(&p.ID, &p.Username, &p.Title, &p.Content, &created, &categoriesStr); err != nil {
-			return nil, err
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Username, &p.AvatarVersion, &p.Title, &p.Content, &created, &categoriesStr); err != nil {
+			return nil, info, err
 		}
 		p.CreatedAt = created
 		if categoriesStr != "" {
-			parts := strings.Split(categoriesStr, ",")
-			for i := range parts {
-				parts[i] = strings.TrimSpace(parts[i])
-			}
-			p.Categories = parts
+			p.Categories = parseCategories(categoriesStr)
 		} else {
 			p.Categories = []string{"general"}
 		}
 		posts = append(posts, p)
-		postIDs = append(postIDs, p.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, info, err
 	}
 
+	more := len(posts) > limit
+	if more {
+		posts = posts[:limit]
+	}
+	if page.After > 0 {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+		}
+		info.HasNewer = more
+		info.HasOlder = true
+	} else {
+		info.HasOlder = more
+		info.HasNewer = page.Before > 0
+	}
+
+	// Nothing past the cursor (any more): link back to the posts on its other
+	// side, so the reader isn't stranded on an empty page
 	if len(posts) == 0 {
-		return posts, nil
+		switch {
+		case page.After > 0:
+			info = PageInfo{HasOlder: true, OlderCursor: page.After}
+		case page.Before > 0:
+			info = PageInfo{HasNewer: true, NewerCursor: page.Before}
+		}
+		return posts, info, nil
 	}
+	info.NewerCursor = posts[0].ID
+	info.OlderCursor = posts[len(posts)-1].ID
 
+	postIDs := make([]int, len(posts))
+	for i, p := range posts {
+		postIDs[i] = p.ID
+	}
 	if err := populateStats(conn, posts, postIDs, userID); err != nil {
-		return nil, err
+		return nil, info, err
 	}
 
-	return posts, nil
+	return posts, info, nil
 }
 
 // populateStats fills likes, dislikes, comments, and user-liked info
@@ -196,7 +264,7 @@ func populateStats(conn *sql.DB, posts []PostShow, postIDs []int, userID *int) e
 	if err != nil {
 		return err
 	}
-	commentsMap, err := fetchCounts(fmt.Sprintf("SELECT post_id, COUNT(*) FROM comments WHERE post_id IN (%s) GROUP BY post_id", placeholders))
+	commentsMap, err := fetchCounts(fmt.Sprintf("SELECT post_id, COUNT(*) FROM comments WHERE "+visibleComments+" AND post_id IN (%s) GROUP BY post_id", placeholders))
 	if err != nil {
 		return err
 	}
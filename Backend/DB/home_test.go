@@ -0,0 +1,63 @@
+package db_test
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchPostsPages(t *testing.T) {
+	conn := dbtest.Open(t)
+	userID := dbtest.CreateUser(t, conn, "alice")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id := 1; id <= 5; id++ {
+		_, err := conn.Exec(`INSERT INTO posts (id, user_id, title, content, created_at) VALUES (?, ?, 'Post', 'Body', ?)`,
+			id, userID, start.Add(time.Duration(id)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Post 3 is purged after the reader got a cursor pointing at it
+	purged := func() {
+		if _, err := conn.Exec(`DELETE FROM posts WHERE id = 3`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		setup func()
+		page  db.PageRequest
+		want  []int
+		info  *db.PageInfo // checked when set
+	}{
+		{"first page", nil, db.PageRequest{Limit: 2}, []int{5, 4}, nil},
+		{"older page", nil, db.PageRequest{Before: 4, Limit: 2}, []int{3, 2}, nil},
+		{"newer page", nil, db.PageRequest{After: 2, Limit: 2}, []int{4, 3}, nil},
+		{"nothing newer links back", nil, db.PageRequest{After: 5, Limit: 2}, nil, &db.PageInfo{HasOlder: true, OlderCursor: 5}},
+		{"nothing older links back", nil, db.PageRequest{Before: 1, Limit: 2}, nil, &db.PageInfo{HasNewer: true, NewerCursor: 1}},
+		{"purged cursor starts over", purged, db.PageRequest{Before: 3, Limit: 2}, []int{5, 4}, nil},
+		{"unknown cursor starts over", nil, db.PageRequest{After: 99, Limit: 2}, []int{5, 4}, nil},
+	}
+	for _, tt := range tests {
+		if tt.setup != nil {
+			tt.setup()
+		}
+		posts, info, err := db.FetchPostsByCategory(conn, "", nil, tt.page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, p := range posts {
+			got = append(got, p.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: posts = %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.info != nil && info != *tt.info {
+			t.Errorf("%s: page = %+v, want %+v", tt.name, info, *tt.info)
+		}
+	}
+}
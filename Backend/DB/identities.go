@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UserIdentity is an account at an OAuth provider linked to a forum account
+type UserIdentity struct {
+	ID          int
+	UserID      int
+	Provider    string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt *time.Time
+}
+
+// OAuthState is a sign-in in progress at a provider. LinkUserID is set
+// when a logged-in user is linking the provider rather than logging in.
+type OAuthState struct {
+	Provider   string
+	Nonce      string
+	Verifier   string
+	LinkUserID *int
+}
+
+// GetIdentity looks up a provider account; nil if it isn't linked to anyone
+func GetIdentity(conn *sql.DB, provider, subject string) (*UserIdentity, error) {
+	var i UserIdentity
+	var lastLogin sql.NullTime
+	err := conn.QueryRow(`
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities WHERE provider = ? AND subject = ?
+	`, provider, subject).Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &lastLogin)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastLogin.Valid {
+		i.LastLoginAt = &lastLogin.Time
+	}
+	return &i, nil
+}
+
+// CreateIdentity links a provider account to a user
+func CreateIdentity(conn *sql.DB, userID int, provider, subject, email string) error {
+	_, err := conn.Exec(`
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)
+	`, userID, provider, subject, email, time.Now().UTC())
+	return err
+}
+
+// TouchIdentity records a login through a provider account and the email it has now
+func TouchIdentity(conn *sql.DB, id int, email string) error {
+	_, err := conn.Exec(`
+		UPDATE user_identities SET last_login_at = ?, email = ? WHERE id = ?
+	`, time.Now().UTC(), email, id)
+	return err
+}
+
+// GetUserIdentities lists the provider accounts linked to a user, oldest first
+func GetUserIdentities(conn *sql.DB, userID int) ([]UserIdentity, error) {
+	rows, err := conn.Query(`
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities WHERE user_id = ?
+		ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []UserIdentity
+	for rows.Next() {
+		var i UserIdentity
+		var lastLogin sql.NullTime
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &lastLogin); err != nil {
+			return nil, err
+		}
+		if lastLogin.Valid {
+			i.LastLoginAt = &lastLogin.Time
+		}
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// DeleteUserIdentity unlinks one of the user's provider accounts. False if
+// the user has no such identity.
+func DeleteUserIdentity(conn *sql.DB, id, userID int) (bool, error) {
+	res, err := conn.Exec(`DELETE FROM user_identities WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CreateUserWithIdentity creates an account for someone signing up through
+// a provider, in one transaction with its identity. The account has no
+// password until one is set with a reset link.
+func CreateUserWithIdentity(conn *sql.DB, username, email string, emailVerified bool, provider, subject string) (int, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var verifiedAt *time.Time
+	if emailVerified {
+		verifiedAt = &now
+	}
+	res, err := tx.Exec(`
+		INSERT INTO users (username, email, password, email_verified_at) VALUES (?, ?, '', ?)
+	`, username, email, verifiedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, id, provider, subject, email, now, now); err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
+}
+
+// CreateOAuthState stores a sign-in in progress by the hash of its state
+func CreateOAuthState(conn *sql.DB, stateHash string, state OAuthState, expiresAt time.Time) error {
+	_, err := conn.Exec(`
+		INSERT INTO oauth_states (state_hash, provider, nonce, verifier, link_user_id, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, stateHash, state.Provider, state.Nonce, state.Verifier, state.LinkUserID, expiresAt.UTC(), time.Now().UTC())
+	return err
+}
+
+// TakeOAuthState returns and deletes an unexpired sign-in, so each state is
+// used once; nil if there is none
+func TakeOAuthState(conn *sql.DB, stateHash string) (*OAuthState, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var s OAuthState
+	var linkUserID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT provider, nonce, verifier, link_user_id FROM oauth_states
+		WHERE state_hash = ? AND expires_at > ?
+	`, stateHash, time.Now().UTC()).Scan(&s.Provider, &s.Nonce, &s.Verifier, &linkUserID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if linkUserID.Valid {
+		id := int(linkUserID.Int64)
+		s.LinkUserID = &id
+	}
+
+	if _, err := tx.Exec(`DELETE FROM oauth_states WHERE state_hash = ?`, stateHash); err != nil {
+		return nil, err
+	}
+	return &s, tx.Commit()
+}
+
+// DeleteExpiredOAuthStates removes sign-ins that were never finished
+func DeleteExpiredOAuthStates(conn *sql.DB, now time.Time) (int64, error) {
+	res, err := conn.Exec(`DELETE FROM oauth_states WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
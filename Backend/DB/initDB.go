@@ -3,36 +3,46 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"forum/Backend/errors"
-	"os"
+	"forum/Backend/DB/migrations"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var DB *sql.DB
 
-// InitDB initializes the SQLite database and applies schema.sql
-func InitDB() {
+// OpenDB opens the SQLite database without touching the schema.
+//
+// Foreign keys are not enforced: content filter reports and command line
+// actions use 0 for "no user", and the moderation log keeps the IDs of
+// deleted accounts. Code that deletes rows removes their dependents itself,
+// so migrations don't declare ON DELETE actions.
+func OpenDB() bool {
 	var err error
 	DB, err = sql.Open("sqlite3", "./forum.db")
 	if err != nil {
-		errors.InternalServerError(nil, nil, "Error opening database: "+err.Error())
-		return
+		fmt.Println("Error opening database: " + err.Error())
+		DB = nil
+		return false
 	}
+	return true
+}
 
-	// Read schema.sql file
-	schema, err := os.ReadFile("Backend/DB/schema.sql")
-	if err != nil {
-		errors.InternalServerError(nil, nil, "Error reading schema.sql: "+err.Error())
+// InitDB opens the SQLite database and applies any pending migrations
+func InitDB() {
+	if !OpenDB() {
 		return
 	}
 
-	// Execute schema
-	_, err = DB.Exec(string(schema))
+	count, err := migrations.Up(DB)
 	if err != nil {
-		errors.InternalServerError(nil, nil, "Error executing schema.sql: "+err.Error())
+		fmt.Println("Error applying migrations: " + err.Error())
+		DB.Close()
+		DB = nil
 		return
 	}
+	if count > 0 {
+		fmt.Printf("Applied %d database migration(s)\n", count)
+	}
 
 	fmt.Println("Database initialized successfully ✅")
 }
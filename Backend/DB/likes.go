@@ -56,7 +56,7 @@ func ToggleLike(conn *sql.DB, target LikeTarget) error {
 
 func CheckPostExists(conn *sql.DB, postID int) (bool, error) {
 	var exists int
-	err := conn.QueryRow(`SELECT id FROM posts WHERE id=?`, postID).Scan(&exists)
+	err := conn.QueryRow(`SELECT id FROM posts WHERE id=? AND deleted_at IS NULL AND hidden_at IS NULL`, postID).Scan(&exists)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -65,7 +65,7 @@ func CheckPostExists(conn *sql.DB, postID int) (bool, error) {
 
 func CheckCommentExists(conn *sql.DB, commentID int) (bool, error) {
 	var exists int
-	err := conn.QueryRow(`SELECT id FROM comments WHERE id=?`, commentID).Scan(&exists)
+	err := conn.QueryRow(`SELECT id FROM comments WHERE id=? AND deleted_at IS NULL AND hidden_at IS NULL`, commentID).Scan(&exists)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
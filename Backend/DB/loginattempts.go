@@ -0,0 +1,115 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LoginAttempt is one failed or successful login
+type LoginAttempt struct {
+	ID         int
+	Identifier string
+	IP         string
+	CreatedAt  time.Time
+}
+
+// RecordLoginAttempt stores an attempt; userID is nil when no account matched
+func RecordLoginAttempt(conn *sql.DB, userID *int, identifier, ip string, success bool) error {
+	_, err := conn.Exec(`
+		INSERT INTO login_attempts (user_id, identifier, ip, success, created_at) VALUES (?, ?, ?, ?, ?)
+	`, userID, identifier, ip, success, time.Now().UTC())
+	return err
+}
+
+// GetAccountFailures returns the times of failed logins to an account since
+// the cutoff and its last successful login, newest first. Unknown accounts
+// (userID nil) are tracked by identifier so they behave like real ones.
+func GetAccountFailures(conn *sql.DB, userID *int, identifier string, since time.Time) ([]time.Time, error) {
+	if userID == nil {
+		return queryTimes(conn, `
+			SELECT created_at FROM login_attempts
+			WHERE user_id IS NULL AND identifier = ? AND success = 0 AND created_at > ?
+			ORDER BY created_at DESC
+		`, identifier, since.UTC())
+	}
+	return queryTimes(conn, `
+		SELECT created_at FROM login_attempts
+		WHERE user_id = ? AND success = 0 AND created_at > ?
+		  AND created_at > COALESCE((SELECT MAX(created_at) FROM login_attempts WHERE user_id = ? AND success = 1), '')
+		ORDER BY created_at DESC
+	`, *userID, since.UTC(), *userID)
+}
+
+// GetIPFailures returns the times of failed logins from an IP since the cutoff, newest first
+func GetIPFailures(conn *sql.DB, ip string, since time.Time) ([]time.Time, error) {
+	return queryTimes(conn, `
+		SELECT created_at FROM login_attempts
+		WHERE ip = ? AND success = 0 AND created_at > ?
+		ORDER BY created_at DESC
+	`, ip, since.UTC())
+}
+
+func queryTimes(conn *sql.DB, query string, args ...interface{}) ([]time.Time, error) {
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
+// GetFailuresBeforeLastLogin returns the failed logins to an account between
+// its two most recent successful logins, newest first, at most limit of them.
+// Called right after a login, these are the ones the user has not seen yet.
+func GetFailuresBeforeLastLogin(conn *sql.DB, userID, limit int) ([]LoginAttempt, error) {
+	logins, err := queryTimes(conn, `
+		SELECT created_at FROM login_attempts
+		WHERE user_id = ? AND success = 1
+		ORDER BY created_at DESC LIMIT 2
+	`, userID)
+	if err != nil || len(logins) == 0 {
+		return nil, err
+	}
+	var previous time.Time
+	if len(logins) == 2 {
+		previous = logins[1]
+	}
+
+	rows, err := conn.Query(`
+		SELECT id, identifier, ip, created_at FROM login_attempts
+		WHERE user_id = ? AND success = 0 AND created_at > ? AND created_at < ?
+		ORDER BY created_at DESC LIMIT ?
+	`, userID, previous.UTC(), logins[0].UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []LoginAttempt
+	for rows.Next() {
+		var a LoginAttempt
+		if err := rows.Scan(&a.ID, &a.Identifier, &a.IP, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// DeleteLoginAttemptsBefore removes attempts older than the cutoff
+func DeleteLoginAttemptsBefore(conn *sql.DB, cutoff time.Time) (int64, error) {
+	res, err := conn.Exec(`DELETE FROM login_attempts WHERE created_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -76,3 +76,12 @@ INSERT OR IGNORE INTO categories (name) VALUES
 ('souls games'),
 ('online games'),
 ('story games');
+
+-- Timestamps are compared as text, so they must be in UTC. Databases from
+-- before migrations may hold the server's local time with its offset; rewrite
+-- those values in UTC, in the format the Go driver uses. Values already in
+-- UTC, with +00:00 or from CURRENT_TIMESTAMP, are left alone.
+UPDATE posts SET created_at = strftime('%Y-%m-%d %H:%M:%f', created_at) || '+00:00' WHERE substr(created_at, -6, 1) IN ('+', '-') AND substr(created_at, -6) != '+00:00';
+UPDATE comments SET created_at = strftime('%Y-%m-%d %H:%M:%f', created_at) || '+00:00' WHERE substr(created_at, -6, 1) IN ('+', '-') AND substr(created_at, -6) != '+00:00';
+UPDATE sessions SET created_at = strftime('%Y-%m-%d %H:%M:%f', created_at) || '+00:00' WHERE substr(created_at, -6, 1) IN ('+', '-') AND substr(created_at, -6) != '+00:00';
+UPDATE sessions SET expires_at = strftime('%Y-%m-%d %H:%M:%f', expires_at) || '+00:00' WHERE substr(expires_at, -6, 1) IN ('+', '-') AND substr(expires_at, -6) != '+00:00';
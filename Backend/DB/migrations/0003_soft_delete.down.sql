@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_comments_deleted_at;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+ALTER TABLE comments DROP COLUMN deleted_at;
+ALTER TABLE posts DROP COLUMN deleted_at;
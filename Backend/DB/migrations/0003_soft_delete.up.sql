@@ -0,0 +1,6 @@
+-- Soft delete: rows stay in place as "[deleted]" tombstones until purged
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments(deleted_at);
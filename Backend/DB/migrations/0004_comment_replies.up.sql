@@ -0,0 +1,4 @@
+-- Threaded replies: a comment may answer another comment on the same post
+ALTER TABLE comments ADD COLUMN parent_id INTEGER REFERENCES comments(id);
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_moderation_actions_created;
+DROP TABLE IF EXISTS moderation_actions;
+DROP INDEX IF EXISTS idx_reports_status;
+DROP INDEX IF EXISTS idx_reports_open_unique;
+DROP TABLE IF EXISTS reports;
+ALTER TABLE comments DROP COLUMN hidden_at;
+ALTER TABLE posts DROP COLUMN hidden_at;
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN shadow_banned;
+DROP INDEX IF EXISTS idx_user_bans_user;
+DROP TABLE IF EXISTS user_bans;
@@ -0,0 +1,19 @@
+-- Bans (permanent), suspensions (until expires_at) and shadow-bans
+CREATE TABLE IF NOT EXISTS user_bans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('ban', 'suspension', 'shadow')),
+    reason TEXT NOT NULL,
+    expires_at DATETIME, -- NULL = until lifted
+    created_by INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    lifted_by INTEGER,
+    lifted_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_bans_user ON user_bans(user_id);
+
+-- Mirrors an active shadow-ban so content queries can filter with a join
+ALTER TABLE users ADD COLUMN shadow_banned INTEGER NOT NULL DEFAULT 0;
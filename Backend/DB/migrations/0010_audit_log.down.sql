@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS moderation_actions_no_delete;
+DROP TRIGGER IF EXISTS moderation_actions_no_update;
+DROP INDEX IF EXISTS idx_moderation_actions_target;
+DROP INDEX IF EXISTS idx_moderation_actions_actor;
+ALTER TABLE moderation_actions DROP COLUMN after_state;
+ALTER TABLE moderation_actions DROP COLUMN before_state;
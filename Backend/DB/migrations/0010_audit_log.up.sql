@@ -0,0 +1,19 @@
+-- JSON snapshots of the target before and after each moderation action
+ALTER TABLE moderation_actions ADD COLUMN before_state TEXT NOT NULL DEFAULT '';
+ALTER TABLE moderation_actions ADD COLUMN after_state TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_actor ON moderation_actions(actor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_target ON moderation_actions(target_type, target_id);
+
+-- The log is append-only
+CREATE TRIGGER IF NOT EXISTS moderation_actions_no_update
+BEFORE UPDATE ON moderation_actions
+BEGIN
+    SELECT RAISE(ABORT, 'moderation_actions is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS moderation_actions_no_delete
+BEFORE DELETE ON moderation_actions
+BEGIN
+    SELECT RAISE(ABORT, 'moderation_actions is append-only');
+END;
@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN held;
+ALTER TABLE posts DROP COLUMN held;
+DROP TABLE IF EXISTS blocked_words;
@@ -0,0 +1,14 @@
+-- Words screened out of new posts and comments
+CREATE TABLE IF NOT EXISTS blocked_words (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    word TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    mode TEXT NOT NULL CHECK (mode IN ('mask', 'reject')),
+    created_by INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+-- Held content is also hidden until a moderator approves it; held reports
+-- are filed by reporter 0, the content filter
+ALTER TABLE posts ADD COLUMN held INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN held INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Token buckets of the rate limiter, when persistence is enabled
+CREATE TABLE IF NOT EXISTS rate_limits (
+    bucket_key TEXT PRIMARY KEY,
+    tokens REAL NOT NULL,
+    updated_at DATETIME NOT NULL
+);
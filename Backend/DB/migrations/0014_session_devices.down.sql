@@ -0,0 +1,13 @@
+CREATE TABLE sessions_old (
+    token TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+INSERT INTO sessions_old (token, user_id, created_at, expires_at)
+SELECT token, user_id, created_at, expires_at FROM sessions;
+
+DROP TABLE sessions;
+ALTER TABLE sessions_old RENAME TO sessions;
@@ -0,0 +1,22 @@
+-- Users can be logged in on several devices. Sessions get an id so they can
+-- be listed and revoked without exposing their tokens, and remember the
+-- device they were started from.
+CREATE TABLE sessions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token TEXT NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    last_seen_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+INSERT INTO sessions_new (token, user_id, created_at, expires_at, last_seen_at)
+SELECT token, user_id, created_at, expires_at, created_at FROM sessions;
+
+DROP TABLE sessions;
+ALTER TABLE sessions_new RENAME TO sessions;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
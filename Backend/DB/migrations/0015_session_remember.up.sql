@@ -0,0 +1,3 @@
+-- "Remember me" sessions slide over a longer idle period than normal ones
+ALTER TABLE sessions ADD COLUMN remember INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at);
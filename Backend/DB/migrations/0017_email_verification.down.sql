@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS app_secrets;
+ALTER TABLE users DROP COLUMN email_verified_at;
@@ -0,0 +1,12 @@
+-- New accounts must confirm their email address before posting. Accounts
+-- that existed before this migration count as verified.
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME;
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP;
+
+-- Server-side keys, generated once per database
+CREATE TABLE IF NOT EXISTS app_secrets (
+    name TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+INSERT OR IGNORE INTO app_secrets (name, value) VALUES ('email_verification', lower(hex(randomblob(32))));
@@ -0,0 +1,29 @@
+-- Accounts at OAuth/OpenID Connect providers that log in to a forum account.
+-- subject is the provider's stable user ID; the email is informational.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    last_login_at DATETIME,
+    UNIQUE (provider, subject),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+
+-- Sign-ins in progress at a provider. The state travels through the
+-- provider and must come back to the browser that started it.
+CREATE TABLE IF NOT EXISTS oauth_states (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    state_hash TEXT NOT NULL UNIQUE,
+    provider TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    verifier TEXT NOT NULL,
+    link_user_id INTEGER,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (link_user_id) REFERENCES users(id)
+);
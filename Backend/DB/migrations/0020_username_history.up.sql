@@ -0,0 +1,12 @@
+-- Usernames people had before renaming, so links to old profiles still lead
+-- to them. A name leaves the history when someone takes it again.
+CREATE TABLE IF NOT EXISTS username_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    old_username TEXT NOT NULL,
+    changed_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_name ON username_history(old_username COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_username_history_user ON username_history(user_id, changed_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_data_exports_status;
+DROP INDEX IF EXISTS idx_data_exports_user;
+DROP TABLE IF EXISTS data_exports;
+
+-- The [deleted user] account stays: content of deleted accounts belongs to it
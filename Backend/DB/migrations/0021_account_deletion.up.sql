@@ -0,0 +1,20 @@
+-- The account that posts, comments and revisions of deleted accounts are
+-- moved to. It has no email and no password, and its name can't be
+-- registered, so nobody can log in as it.
+INSERT OR IGNORE INTO users (username, email, password) VALUES ('[deleted user]', '', '');
+
+-- "Download my data" requests. A background job builds the ZIP archive,
+-- which can be downloaded until it expires.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    archive BLOB,
+    created_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    expires_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN avatar_version;
+DROP TABLE IF EXISTS user_avatars;
+DROP TABLE IF EXISTS user_profiles;
@@ -0,0 +1,24 @@
+-- What users tell others about themselves on their profile
+CREATE TABLE IF NOT EXISTS user_profiles (
+    user_id INTEGER PRIMARY KEY,
+    bio TEXT NOT NULL DEFAULT '',
+    favourite_games TEXT NOT NULL DEFAULT '',
+    steam TEXT NOT NULL DEFAULT '',
+    psn TEXT NOT NULL DEFAULT '',
+    xbox TEXT NOT NULL DEFAULT '',
+    minecraft TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Uploaded avatars, already cropped and resized to a square PNG
+CREATE TABLE IF NOT EXISTS user_avatars (
+    user_id INTEGER PRIMARY KEY,
+    image BLOB NOT NULL,
+    updated_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Changes with every upload, so avatar URLs can be cached for good.
+-- 0 = no avatar. Kept on users because every post and comment joins it.
+ALTER TABLE users ADD COLUMN avatar_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+-- Codes emailed to accounts without a password, which confirm deleting the
+-- account or changing its email address instead. One per user; asking again
+-- replaces it.
+CREATE TABLE IF NOT EXISTS confirmation_codes (
+    user_id INTEGER PRIMARY KEY,
+    code_hash TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+const usage = "usage: forum migrate up|down [steps]|status"
+
+// RunCommand handles the "forum migrate ..." subcommand
+func RunCommand(conn *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := Up(conn)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			fmt.Println("Database is already up to date")
+		} else {
+			fmt.Printf("Applied %d migration(s)\n", count)
+		}
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+			steps = n
+		}
+		count, err := Down(conn, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", count)
+		return nil
+
+	case "status":
+		statuses, err := GetStatus(conn)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			} else if s.Unsupported {
+				state = "pending, needs SQLite built with " + s.Requires
+			}
+			fmt.Printf("%04d  %-30s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	}
+
+	return fmt.Errorf(usage)
+}
@@ -0,0 +1,300 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// requiresPrefix starts the first line of an up script that needs an
+// optional SQLite feature, e.g. "-- requires: ENABLE_FTS5"
+const requiresPrefix = "-- requires: "
+
+// createdPattern finds the tables, indexes, triggers and views a script creates
+var createdPattern = regexp.MustCompile(`(?i)\bCREATE\s+(?:UNIQUE\s+|VIRTUAL\s+)?(?:TABLE|INDEX|TRIGGER|VIEW)\s+(?:IF\s+NOT\s+EXISTS\s+)?["\x60]?(\w+)`)
+
+// Migration is a single numbered schema change with its rollback
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Requires is the SQLite compile option the migration needs, if any.
+	// Without it the migration is skipped and stays pending, so later
+	// migrations may only use what it creates if they require it too.
+	Requires string
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Unsupported is true when this SQLite build lacks what the migration requires
+	Unsupported bool
+}
+
+// Load reads all embedded NNNN_name.up.sql / NNNN_name.down.sql pairs sorted by version
+func Load() ([]Migration, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration version in %s", name)
+		}
+
+		body, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		} else if m.Name != parts[1] {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s and %s", version, m.Name, parts[1])
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+			if strings.HasPrefix(m.Up, requiresPrefix) {
+				line := strings.SplitN(m.Up, "\n", 2)[0]
+				m.Requires = strings.TrimSpace(strings.TrimPrefix(line, requiresPrefix))
+			}
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	var list []Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	if err := checkOptional(list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// checkOptional makes sure nothing depends on a migration that may be
+// skipped: a later migration that mentions a table, index, trigger or view
+// created by one must require the same option, so both are skipped together.
+func checkOptional(list []Migration) error {
+	for i, m := range list {
+		if m.Requires == "" {
+			continue
+		}
+		for _, match := range createdPattern.FindAllStringSubmatch(m.Up, -1) {
+			used := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(match[1]) + `\b`)
+			for _, later := range list[i+1:] {
+				if later.Requires != m.Requires && (used.MatchString(later.Up) || used.MatchString(later.Down)) {
+					return fmt.Errorf("migration %04d_%s uses %s from %04d_%s, so it must also require %s",
+						later.Version, later.Name, match[1], m.Version, m.Name, m.Requires)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ensureTable creates the schema_migrations tracking table
+func ensureTable(conn *sql.DB) error {
+	_, err := conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// appliedVersions returns applied migration versions with their timestamps
+func appliedVersions(conn *sql.DB) (map[int]time.Time, error) {
+	rows, err := conn.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// supported reports whether SQLite was built with a compile option
+func supported(conn *sql.DB, option string) (bool, error) {
+	if option == "" {
+		return true, nil
+	}
+	var used bool
+	err := conn.QueryRow(`SELECT sqlite_compileoption_used(?)`, option).Scan(&used)
+	return used, err
+}
+
+// Up applies every pending migration in order, each inside its own
+// transaction. Migrations this SQLite build can't run are skipped with a
+// warning and applied by a later Up from a build that can; Load makes sure
+// no other migration depends on them.
+func Up(conn *sql.DB) (int, error) {
+	list, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	return up(conn, list)
+}
+
+// up applies the pending migrations of list
+func up(conn *sql.DB, list []Migration) (int, error) {
+	if err := ensureTable(conn); err != nil {
+		return 0, err
+	}
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range list {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		ok, err := supported(conn, m.Requires)
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			fmt.Printf("Skipping migration %04d_%s: SQLite was built without %s\n", m.Version, m.Name, m.Requires)
+			continue
+		}
+		if err := apply(conn, m.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name)
+			return err
+		}); err != nil {
+			return count, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down rolls back the given number of most recently applied migrations
+func Down(conn *sql.DB, steps int) (int, error) {
+	if err := ensureTable(conn); err != nil {
+		return 0, err
+	}
+	list, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(list) - 1; i >= 0 && count < steps; i-- {
+		m := list[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return count, fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+		}
+		if err := apply(conn, m.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+			return err
+		}); err != nil {
+			return count, fmt.Errorf("rollback %04d_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// GetStatus lists every known migration and whether it is applied
+func GetStatus(conn *sql.DB) ([]Status, error) {
+	if err := ensureTable(conn); err != nil {
+		return nil, err
+	}
+	list, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, m := range list {
+		at, ok := applied[m.Version]
+		usable, err := supported(conn, m.Requires)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, Status{Migration: m, Applied: ok, AppliedAt: at, Unsupported: !usable})
+	}
+	return statuses, nil
+}
+
+// apply runs a script and its bookkeeping statement in one transaction
+func apply(conn *sql.DB, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,109 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// missingOption is a compile option no SQLite build has
+const missingOption = "ENABLE_FORUM_TEST_OPTION"
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func tableExists(t *testing.T, conn *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestUpSkipsUnsupportedMigration(t *testing.T) {
+	conn := openDB(t)
+	list := []Migration{
+		{Version: 1, Name: "games", Up: `CREATE TABLE games (id INTEGER PRIMARY KEY);`},
+		{Version: 2, Name: "game_search", Requires: missingOption,
+			Up: "-- requires: " + missingOption + "\nCREATE TABLE game_search (id INTEGER PRIMARY KEY);"},
+		{Version: 3, Name: "players", Up: `CREATE TABLE players (id INTEGER PRIMARY KEY, game_id INTEGER REFERENCES games(id));`},
+	}
+	if err := checkOptional(list); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := up(conn, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("applied %d migrations, want 2", count)
+	}
+	if !tableExists(t, conn, "players") || tableExists(t, conn, "game_search") {
+		t.Error("want players created and game_search skipped")
+	}
+
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := applied[2]; ok || len(applied) != 2 {
+		t.Errorf("applied versions = %v, want 1 and 3", applied)
+	}
+
+	// Running again leaves the skipped migration pending
+	if count, err := up(conn, list); err != nil || count != 0 {
+		t.Errorf("second up = %d, %v; want 0, nil", count, err)
+	}
+}
+
+func TestCheckOptionalRejectsDependents(t *testing.T) {
+	optional := Migration{Version: 1, Name: "game_search", Requires: missingOption,
+		Up: "-- requires: " + missingOption + "\nCREATE VIRTUAL TABLE IF NOT EXISTS game_search USING fts5(title);"}
+
+	uses := Migration{Version: 2, Name: "search_trigger",
+		Up: `CREATE TRIGGER games_ai AFTER INSERT ON games BEGIN INSERT INTO game_search(rowid, title) VALUES (new.id, new.title); END;`}
+	err := checkOptional([]Migration{optional, uses})
+	if err == nil || !strings.Contains(err.Error(), "0002_search_trigger") {
+		t.Errorf("checkOptional = %v, want an error naming 0002_search_trigger", err)
+	}
+
+	// Requiring the same option skips them together, which is fine
+	uses.Requires = missingOption
+	if err := checkOptional([]Migration{optional, uses}); err != nil {
+		t.Errorf("checkOptional with the same requirement = %v", err)
+	}
+
+	// Names that merely start the same aren't uses
+	unrelated := Migration{Version: 2, Name: "game_search_log", Up: `CREATE TABLE game_search_log (id INTEGER PRIMARY KEY);`}
+	if err := checkOptional([]Migration{optional, unrelated}); err != nil {
+		t.Errorf("checkOptional with an unrelated name = %v", err)
+	}
+}
+
+func TestUpAppliesEverythingSupported(t *testing.T) {
+	conn := openDB(t)
+	if _, err := Up(conn); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := GetStatus(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.Applied == s.Unsupported {
+			t.Errorf("%04d_%s: applied = %v, unsupported = %v", s.Version, s.Name, s.Applied, s.Unsupported)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package db
+
+import "time"
+
+// HiddenPlaceholder replaces the content and author of hidden comments for
+// viewers who may not see them
+const HiddenPlaceholder = "[hidden by a moderator]"
+
+// SetPostHidden hides or unhides a post. Unhiding also releases held posts.
+func SetPostHidden(conn Querier, postID int, hidden bool, at time.Time) error {
+	var hiddenAt interface{}
+	if hidden {
+		hiddenAt = at
+	}
+	_, err := conn.Exec(`UPDATE posts SET hidden_at=?, held = held AND ? WHERE id=?`, hiddenAt, hidden, postID)
+	return err
+}
+
+// SetCommentHidden hides or unhides a comment. Unhiding also releases held comments.
+func SetCommentHidden(conn Querier, commentID int, hidden bool, at time.Time) error {
+	var hiddenAt interface{}
+	if hidden {
+		hiddenAt = at
+	}
+	_, err := conn.Exec(`UPDATE comments SET hidden_at=?, held = held AND ? WHERE id=?`, hiddenAt, hidden, commentID)
+	return err
+}
+
+// MaskHiddenComments replaces hidden comments in a tree with a placeholder,
+// except those written by viewerID. Moderators should see the tree unmasked.
+func MaskHiddenComments(comments []Comment, viewerID int) {
+	for i := range comments {
+		c := &comments[i]
+		if c.Hidden && !c.Deleted && c.UserID != viewerID {
+			placeholder := HiddenPlaceholder
+			if c.Held {
+				placeholder = HeldPlaceholder
+			}
+			c.UserID = 0
+			c.Username = placeholder
+			c.Content = placeholder
+			c.AvatarVersion = 0
+		}
+		MaskHiddenComments(c.Replies, viewerID)
+	}
+}
+
+// RemoveShadowBanned drops comments by shadow-banned users from a tree,
+// except those written by viewerID. A removed comment that has visible
+// replies is shown as deleted so the thread stays intact.
+func RemoveShadowBanned(comments []Comment, viewerID int) []Comment {
+	kept := comments[:0]
+	for _, c := range comments {
+		c.Replies = RemoveShadowBanned(c.Replies, viewerID)
+		if c.ShadowBanned && !c.Deleted && c.UserID != viewerID {
+			if len(c.Replies) == 0 {
+				continue
+			}
+			c.Deleted = true
+			c.UserID = 0
+			c.Username = DeletedPlaceholder
+			c.Content = DeletedPlaceholder
+			c.AvatarVersion = 0
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
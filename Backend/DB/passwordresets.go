@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PasswordReset is an unused, unexpired reset token
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	Username  string
+	ExpiresAt time.Time
+}
+
+// CreatePasswordReset stores a reset token by its hash
+func CreatePasswordReset(conn *sql.DB, userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := conn.Exec(`
+		INSERT INTO password_resets (user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)
+	`, userID, tokenHash, expiresAt.UTC(), time.Now().UTC())
+	return err
+}
+
+// CountPasswordResetsSince counts the reset tokens issued to a user since a time
+func CountPasswordResetsSince(conn *sql.DB, userID int, since time.Time) (int, error) {
+	var n int
+	err := conn.QueryRow(`
+		SELECT COUNT(*) FROM password_resets WHERE user_id = ? AND created_at > ?
+	`, userID, since.UTC()).Scan(&n)
+	return n, err
+}
+
+// GetPasswordReset looks up a usable reset token; nil if it is unknown, used or expired
+func GetPasswordReset(conn *sql.DB, tokenHash string) (*PasswordReset, error) {
+	var p PasswordReset
+	err := conn.QueryRow(`
+		SELECT r.id, r.user_id, u.username, r.expires_at
+		FROM password_resets r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.token_hash = ? AND r.used_at IS NULL AND r.expires_at > ?
+	`, tokenHash, time.Now().UTC()).Scan(&p.ID, &p.UserID, &p.Username, &p.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ResetPassword sets a new password hash with a reset token, in one
+// transaction: the token and any other outstanding ones are used up and all
+// of the user's sessions end. False if the token was used in the meantime.
+func ResetPassword(conn *sql.DB, resetID, userID int, passwordHash string) (bool, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	res, err := tx.Exec(`UPDATE password_resets SET used_at = ? WHERE id = ? AND used_at IS NULL`, now, resetID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, now, userID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE users SET password = ? WHERE id = ?`, passwordHash, userID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
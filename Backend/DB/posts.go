@@ -10,56 +10,73 @@ import (
 
 type PostShow struct {
 	ID                 int
+	UserID             int
 	Username           string
 	Title              string
 	Content            string
 	Categories         []string
 	CreatedAt          time.Time
-	CreatedAtFormatted string // Add nice readable format
+	CreatedAtFormatted string     // Add nice readable format
+	UpdatedAt          *time.Time // nil = never edited
+	Deleted            bool
+	Hidden             bool // hidden by a moderator; content is kept
+	Held               bool // hidden by the content filter until approved
+	ShadowBanned       bool // the author is shadow-banned
 	Likes              int
 	Dislikes           int
-	Comments           int  // total number of comments
-	UserLiked          *int // nil = not liked, 0 = disliked, 1 = liked
+	Comments           int   // total number of comments
+	UserLiked          *int  // nil = not liked, 0 = disliked, 1 = liked
+	AvatarVersion      int64 // the author's avatar; 0 = none
 }
 
 type Comment struct {
-	ID        int
-	Username  string
-	Content   string
-	CreatedAt time.Time
-	Likes     int
-	Dislikes  int
+	ID            int
+	UserID        int
+	ParentID      *int // nil = top-level comment
+	Username      string
+	Content       string
+	CreatedAt     time.Time
+	Likes         int
+	Dislikes      int
+	Deleted       bool
+	Hidden        bool      // hidden by a moderator; content is kept
+	Held          bool      // hidden by the content filter until approved
+	ShadowBanned  bool      // the author is shadow-banned
+	AvatarVersion int64     // the author's avatar; 0 = none
+	Depth         int       // 0 = top-level, set by GetCommentTree
+	Replies       []Comment // set by GetCommentTree
 }
 
 // -------------------- Post Functions --------------------
 
-// GetUserPosts fetches posts created or liked by a user
-func GetUserPosts(conn *sql.DB, userID int, fetchType string) ([]PostShow, error) {
+// GetUserPosts fetches posts created or liked by a user, as seen by viewerID
+// (0 for guests)
+func GetUserPosts(conn *sql.DB, userID int, fetchType string, viewerID int) ([]PostShow, error) {
 	var query string
 
 	switch fetchType {
 	case "created":
 		query = `
-			SELECT p.id, u.username, p.title, p.content, p.created_at,
+			SELECT p.id, p.user_id, u.username, u.avatar_version, p.title, p.content, p.created_at,
 				GROUP_CONCAT(c.name, ',') AS categories
 			FROM posts p
 			JOIN users u ON p.user_id = u.id
 			LEFT JOIN post_categories pc ON p.id = pc.post_id
 			LEFT JOIN categories c ON pc.category_id = c.id
-			WHERE p.user_id = ?
+			WHERE p.user_id = ? AND p.deleted_at IS NULL AND p.hidden_at IS NULL AND ` + shadowFilter + `
 			GROUP BY p.id
 			ORDER BY p.created_at DESC
 		`
 	case "liked":
 		query = `
-			SELECT p.id, u.username, p.title, p.content, p.created_at,
+			SELECT p.id, p.user_id, u.username, u.avatar_version, p.title, p.content, p.created_at,
 				GROUP_CONCAT(c.name, ',') AS categories
 			FROM likes l
 			JOIN posts p ON l.post_id = p.id
 			JOIN users u ON p.user_id = u.id
 			LEFT JOIN post_categories pc ON p.id = pc.post_id
 			LEFT JOIN categories c ON pc.category_id = c.id
-			WHERE l.user_id = ? AND l.is_like = 1
+			WHERE l.user_id = ? AND l.is_like = 1 AND p.deleted_at IS NULL AND p.hidden_at IS NULL AND ` + shadowFilter + `
 			GROUP BY p.id
 			ORDER BY p.created_at DESC
 		`
@@ -67,7 +84,7 @@ func GetUserPosts(conn *sql.DB, userID int, fetchType string) ([]PostShow, error
 		return nil, nil
 	}
 
-	rows, err := conn.Query(query, userID)
+	rows, err := conn.Query(query, userID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +95,7 @@ func GetUserPosts(conn *sql.DB, userID int, fetchType string) ([]PostShow, error
 		var p PostShow
 		var created time.Time
 		var categories sql.NullString
-		if err := rows.Scan(&p.ID, &p.Username, &p.Title, &p.Content, &created, &categories); err != nil {
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Username, &p.AvatarVersion, &p.Title, &p.Content, &created, &categories); err != nil {
 			return nil, err
 		}
 		p.CreatedAt = created
@@ -93,7 +110,7 @@ func GetUserPosts(conn *sql.DB, userID int, fetchType string) ([]PostShow, error
 		// Fetch likes/dislikes/comments
 		_ = conn.QueryRow(`SELECT COUNT(*) FROM likes WHERE post_id=? AND is_like=1`, p.ID).Scan(&p.Likes)
 		_ = conn.QueryRow(`SELECT COUNT(*) FROM likes WHERE post_id=? AND is_like=0`, p.ID).Scan(&p.Dislikes)
-		_ = conn.QueryRow(`SELECT COUNT(*) FROM comments WHERE post_id=?`, p.ID).Scan(&p.Comments)
+		_ = conn.QueryRow(`SELECT COUNT(*) FROM comments WHERE post_id=? AND `+visibleComments, p.ID).Scan(&p.Comments)
 
 		posts = append(posts, p)
 	}
@@ -106,10 +123,11 @@ func GetPostWithCategories(conn *sql.DB, postID int) (*PostShow, error) {
 	var post PostShow
 	var categoriesStr sql.NullString
 	var createdAt time.Time
+	var updatedAt, deletedAt, hiddenAt sql.NullTime
 
 	query := `
-		SELECT p.id, u.username, p.title, p.content, p.created_at,
-			   GROUP_CONCAT(c.name, ',') AS categories
+		SELECT p.id, p.user_id, u.username, u.avatar_version, p.title, p.content, p.created_at, p.updated_at, p.deleted_at, p.hidden_at,
+			   p.held, u.shadow_banned, GROUP_CONCAT(c.name, ',') AS categories
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		LEFT JOIN post_categories pc ON p.id = pc.post_id
@@ -118,12 +136,23 @@ func GetPostWithCategories(conn *sql.DB, postID int) (*PostShow, error) {
 		GROUP BY p.id
 	`
 	err := conn.QueryRow(query, postID).Scan(
-		&post.ID, &post.Username, &post.Title, &post.Content, &createdAt, &categoriesStr,
+		&post.ID, &post.UserID, &post.Username, &post.AvatarVersion, &post.Title, &post.Content, &createdAt, &updatedAt, &deletedAt, &hiddenAt, &post.Held, &post.ShadowBanned, &categoriesStr,
 	)
 	if err != nil {
 		return nil, err
 	}
 	post.CreatedAt = createdAt
+	if updatedAt.Valid {
+		post.UpdatedAt = &updatedAt.Time
+	}
+	post.Hidden = hiddenAt.Valid
+	if deletedAt.Valid {
+		post.Deleted = true
+		post.Username = DeletedPlaceholder
+		post.AvatarVersion = 0
+		post.Title = DeletedPlaceholder
+		post.Content = DeletedPlaceholder
+	}
 
 	if categoriesStr.Valid && categoriesStr.String != "" {
 		post.Categories = parseCategories(categoriesStr.String)
@@ -133,7 +162,7 @@ func GetPostWithCategories(conn *sql.DB, postID int) (*PostShow, error) {
 
 	_ = conn.QueryRow(`SELECT COUNT(*) FROM likes WHERE post_id=? AND is_like=1`, postID).Scan(&post.Likes)
 	_ = conn.QueryRow(`SELECT COUNT(*) FROM likes WHERE post_id=? AND is_like=0`, postID).Scan(&post.Dislikes)
-	_ = conn.QueryRow(`SELECT COUNT(*) FROM comments WHERE post_id=?`, postID).Scan(&post.Comments)
+	_ = conn.QueryRow(`SELECT COUNT(*) FROM comments WHERE post_id=? AND `+visibleComments, postID).Scan(&post.Comments)
 
 	return &post, nil
 }
@@ -141,7 +170,7 @@ func GetPostWithCategories(conn *sql.DB, postID int) (*PostShow, error) {
 // GetCommentsForPost fetches all comments for a post with likes/dislikes
 func GetCommentsForPost(conn *sql.DB, postID int) ([]Comment, error) {
 	query := `
-		SELECT c.id, u.username, c.content, c.created_at
+		SELECT c.id, c.user_id, c.parent_id, u.username, u.avatar_version, c.content, c.created_at, c.deleted_at, c.hidden_at, c.held, u.shadow_banned
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
 		WHERE c.post_id = ?
@@ -157,10 +186,23 @@ func GetCommentsForPost(conn *sql.DB, postID int) ([]Comment, error) {
 	for rows.Next() {
 		var c Comment
 		var createdAt time.Time
-		if err := rows.Scan(&c.ID, &c.Username, &c.Content, &createdAt); err != nil {
+		var deletedAt, hiddenAt sql.NullTime
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.UserID, &parentID, &c.Username, &c.AvatarVersion, &c.Content, &createdAt, &deletedAt, &hiddenAt, &c.Held, &c.ShadowBanned); err != nil {
 			return nil, err
 		}
 		c.CreatedAt = createdAt
+		if parentID.Valid {
+			pid := int(parentID.Int64)
+			c.ParentID = &pid
+		}
+		c.Hidden = hiddenAt.Valid
+		if deletedAt.Valid {
+			c.Deleted = true
+			c.Username = DeletedPlaceholder
+			c.Content = DeletedPlaceholder
+			c.AvatarVersion = 0
+		}
 
 		_ = conn.QueryRow(`SELECT COUNT(*) FROM likes WHERE comment_id=? AND is_like=1`, c.ID).Scan(&c.Likes)
 		_ = conn.QueryRow(`SELECT COUNT(*) FROM likes WHERE comment_id=? AND is_like=0`, c.ID).Scan(&c.Dislikes)
@@ -181,14 +223,56 @@ func parseCategories(s string) []string {
 	return parts
 }
 
+// GetPostCategories lists the category names of one post
+func GetPostCategories(conn Querier, postID int) ([]string, error) {
+	rows, err := conn.Query(`
+		SELECT c.name FROM post_categories pc
+		JOIN categories c ON pc.category_id = c.id
+		WHERE pc.post_id = ?
+	`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// GetCategories lists all category names
+func GetCategories(conn *sql.DB) ([]string, error) {
+	rows, err := conn.Query(`SELECT name FROM categories ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
 // EnsureCategory inserts a category if it doesn't exist
-func EnsureCategory(conn *sql.DB, name string) error {
+func EnsureCategory(conn Querier, name string) error {
 	_, err := conn.Exec(`INSERT OR IGNORE INTO categories (name) VALUES (?)`, name)
 	return err
 }
 
 // GetCategoryID returns the ID of a category
-func GetCategoryID(conn *sql.DB, name string) (int, error) {
+func GetCategoryID(conn Querier, name string) (int, error) {
 	var id int
 	err := conn.QueryRow(`SELECT id FROM categories WHERE name = ?`, name).Scan(&id)
 	if err != nil {
@@ -198,7 +282,7 @@ func GetCategoryID(conn *sql.DB, name string) (int, error) {
 }
 
 // LinkPostToCategory associates a post with a category
-func LinkPostToCategory(conn *sql.DB, postID, categoryID int) error {
+func LinkPostToCategory(conn Querier, postID, categoryID int) error {
 	_, err := conn.Exec(`INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`, postID, categoryID)
 	return err
 }
@@ -206,7 +290,7 @@ func LinkPostToCategory(conn *sql.DB, postID, categoryID int) error {
 // -------------------- Post Creation --------------------
 
 // CreatePost inserts a new post and returns its ID
-func CreatePost(conn *sql.DB, userID int, title, content string, createdAt time.Time) (int, error) {
+func CreatePost(conn Querier, userID int, title, content string, createdAt time.Time) (int, error) {
 	res, err := conn.Exec(
 		`INSERT INTO posts (user_id, title, content, created_at) VALUES (?, ?, ?, ?)`,
 		userID, title, content, createdAt,
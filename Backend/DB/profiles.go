@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Profile is what a user tells others about themselves
+type Profile struct {
+	Bio            string `json:"bio"`
+	FavouriteGames string `json:"favourite_games"`
+	Steam          string `json:"steam"`
+	PSN            string `json:"psn"`
+	Xbox           string `json:"xbox"`
+	Minecraft      string `json:"minecraft"`
+}
+
+// GetProfile returns the user's profile; empty if they never filled it in
+func GetProfile(conn *sql.DB, userID int) (*Profile, error) {
+	var p Profile
+	err := conn.QueryRow(`
+		SELECT bio, favourite_games, steam, psn, xbox, minecraft FROM user_profiles WHERE user_id = ?
+	`, userID).Scan(&p.Bio, &p.FavouriteGames, &p.Steam, &p.PSN, &p.Xbox, &p.Minecraft)
+	if err == sql.ErrNoRows {
+		return &Profile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveProfile replaces the user's profile
+func SaveProfile(conn *sql.DB, userID int, p Profile) error {
+	_, err := conn.Exec(`
+		INSERT INTO user_profiles (user_id, bio, favourite_games, steam, psn, xbox, minecraft, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			bio = excluded.bio, favourite_games = excluded.favourite_games, steam = excluded.steam,
+			psn = excluded.psn, xbox = excluded.xbox, minecraft = excluded.minecraft, updated_at = excluded.updated_at
+	`, userID, p.Bio, p.FavouriteGames, p.Steam, p.PSN, p.Xbox, p.Minecraft, time.Now().UTC())
+	return err
+}
+
+// GetAvatarVersion returns the version of the user's avatar, 0 if they have none
+func GetAvatarVersion(conn *sql.DB, userID int) (int64, error) {
+	var version int64
+	err := conn.QueryRow(`SELECT avatar_version FROM users WHERE id = ?`, userID).Scan(&version)
+	return version, err
+}
+
+// GetAvatar returns the user's avatar PNG and its version
+func GetAvatar(conn *sql.DB, userID int) ([]byte, int64, error) {
+	var image []byte
+	var version int64
+	err := conn.QueryRow(`
+		SELECT a.image, u.avatar_version FROM user_avatars a JOIN users u ON u.id = a.user_id WHERE a.user_id = ?
+	`, userID).Scan(&image, &version)
+	return image, version, err
+}
+
+// SetAvatar stores a new avatar PNG for the user and bumps its version
+func SetAvatar(conn *sql.DB, userID int, image []byte) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`
+		INSERT INTO user_avatars (user_id, image, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET image = excluded.image, updated_at = excluded.updated_at
+	`, userID, image, now); err != nil {
+		return err
+	}
+	// Versions only grow, so an old cached image is never served for a new one
+	if _, err := tx.Exec(`
+		UPDATE users SET avatar_version = MAX(avatar_version + 1, ?) WHERE id = ?
+	`, now.UnixMilli(), userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAvatar removes the user's avatar
+func DeleteAvatar(conn *sql.DB, userID int) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_avatars WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET avatar_version = 0 WHERE id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
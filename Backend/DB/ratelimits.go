@@ -0,0 +1,31 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LoadRateBucket returns a stored token bucket; found is false if there is none
+func LoadRateBucket(conn *sql.DB, key string) (tokens float64, updatedAt time.Time, found bool, err error) {
+	err = conn.QueryRow(`SELECT tokens, updated_at FROM rate_limits WHERE bucket_key = ?`, key).Scan(&tokens, &updatedAt)
+	if err == sql.ErrNoRows {
+		return 0, time.Time{}, false, nil
+	}
+	return tokens, updatedAt, err == nil, err
+}
+
+// SaveRateBucket stores a token bucket
+func SaveRateBucket(conn *sql.DB, key string, tokens float64, updatedAt time.Time) error {
+	_, err := conn.Exec(`
+		INSERT INTO rate_limits (bucket_key, tokens, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(bucket_key) DO UPDATE SET tokens = excluded.tokens, updated_at = excluded.updated_at
+	`, key, tokens, updatedAt)
+	return err
+}
+
+// DeleteRateBucketsBefore removes buckets untouched since the cutoff; they
+// have refilled and behave like new ones
+func DeleteRateBucketsBefore(conn *sql.DB, cutoff time.Time) error {
+	_, err := conn.Exec(`DELETE FROM rate_limits WHERE updated_at < ?`, cutoff)
+	return err
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is one user's report of a post or comment
+type Report struct {
+	ID         int
+	ReporterID int
+	Reporter   string
+	Reason     string
+	Details    string
+	CreatedAt  time.Time
+}
+
+// ReportedItem is a post or comment with all its open reports
+type ReportedItem struct {
+	TargetType string // "post" or "comment"
+	TargetID   int
+	PostID     int
+	PostTitle  string
+	AuthorID   int
+	Author     string
+	Content    string
+	Hidden     bool
+	Held       bool
+	Deleted    bool
+	Reports    []Report
+}
+
+// CreateReport files a report. It returns false if the user already has an
+// open report on the same target.
+func CreateReport(conn *sql.DB, reporterID int, targetType string, targetID, postID int, reason, details string) (bool, error) {
+	res, err := conn.Exec(`
+		INSERT OR IGNORE INTO reports (reporter_id, target_type, target_id, post_id, reason, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, reporterID, targetType, targetID, postID, reason, details, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetOpenReports lists reported items, oldest report first. When categories
+// is non-nil only items on posts in those categories are returned.
+func GetOpenReports(conn *sql.DB, categories []string) ([]ReportedItem, error) {
+	query := `
+		SELECT r.id, r.reporter_id, COALESCE(u.username, 'content filter'), r.target_type, r.target_id, r.post_id, r.reason, r.details, r.created_at
+		FROM reports r
+		LEFT JOIN users u ON u.id = r.reporter_id
+		WHERE r.status = 'open'`
+	var args []interface{}
+	if categories != nil {
+		if len(categories) == 0 {
+			return nil, nil
+		}
+		query += ` AND r.post_id IN (
+			SELECT pc.post_id FROM post_categories pc
+			JOIN categories c ON c.id = pc.category_id
+			WHERE c.name IN (` + strings.TrimSuffix(strings.Repeat("?,", len(categories)), ",") + `))`
+		for _, c := range categories {
+			args = append(args, c)
+		}
+	}
+	query += ` ORDER BY r.created_at, r.id`
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ReportedItem
+	type target struct {
+		kind string
+		id   int
+	}
+	index := make(map[target]int) // position of each target in items
+	for rows.Next() {
+		var rep Report
+		var item ReportedItem
+		if err := rows.Scan(&rep.ID, &rep.ReporterID, &rep.Reporter, &item.TargetType, &item.TargetID, &item.PostID,
+			&rep.Reason, &rep.Details, &rep.CreatedAt); err != nil {
+			return nil, err
+		}
+		key := target{item.TargetType, item.TargetID}
+		i, ok := index[key]
+		if !ok {
+			items = append(items, item)
+			i = len(items) - 1
+			index[key] = i
+		}
+		items[i].Reports = append(items[i].Reports, rep)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range items {
+		if err := loadReportedContent(conn, &items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// loadReportedContent fills in the author and text of a reported item
+func loadReportedContent(conn *sql.DB, item *ReportedItem) error {
+	var deletedAt, hiddenAt sql.NullTime
+	var err error
+	if item.TargetType == "post" {
+		err = conn.QueryRow(`
+			SELECT p.id, p.title, p.user_id, u.username, p.content, p.deleted_at, p.hidden_at, p.held
+			FROM posts p JOIN users u ON u.id = p.user_id
+			WHERE p.id = ?
+		`, item.TargetID).Scan(&item.PostID, &item.PostTitle, &item.AuthorID, &item.Author, &item.Content, &deletedAt, &hiddenAt, &item.Held)
+	} else {
+		err = conn.QueryRow(`
+			SELECT p.id, p.title, c.user_id, u.username, c.content, c.deleted_at, c.hidden_at, c.held
+			FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			JOIN users u ON u.id = c.user_id
+			WHERE c.id = ?
+		`, item.TargetID).Scan(&item.PostID, &item.PostTitle, &item.AuthorID, &item.Author, &item.Content, &deletedAt, &hiddenAt, &item.Held)
+	}
+	if err == sql.ErrNoRows {
+		// Purged since it was reported
+		item.Deleted = true
+		item.Content = DeletedPlaceholder
+		return nil
+	}
+	item.Deleted = deletedAt.Valid
+	item.Hidden = hiddenAt.Valid
+	return err
+}
+
+// GetReportTarget loads a post or comment the way the report views show it.
+// It returns sql.ErrNoRows if the target does not exist.
+func GetReportTarget(conn *sql.DB, targetType string, targetID int) (*ReportedItem, error) {
+	item := ReportedItem{TargetType: targetType, TargetID: targetID}
+	if err := loadReportedContent(conn, &item); err != nil {
+		return nil, err
+	}
+	if item.PostID == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &item, nil
+}
+
+// GetOpenReportReasons summarizes the open reports on a target, e.g. ["spam x2", "hate"]
+func GetOpenReportReasons(conn *sql.DB, targetType string, targetID int) ([]string, error) {
+	rows, err := conn.Query(`
+		SELECT reason, COUNT(*) FROM reports
+		WHERE target_type = ? AND target_id = ? AND status = 'open'
+		GROUP BY reason
+		ORDER BY COUNT(*) DESC, reason
+	`, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reasons []string
+	for rows.Next() {
+		var reason string
+		var count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			return nil, err
+		}
+		if count > 1 {
+			reason += " x" + strconv.Itoa(count)
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons, rows.Err()
+}
+
+// ResolveReports closes every open report on a target with the given resolution
+func ResolveReports(conn Querier, targetType string, targetID, resolverID int, resolution string) (int64, error) {
+	res, err := conn.Exec(`
+		UPDATE reports SET status = 'resolved', resolution = ?, resolved_by = ?, resolved_at = ?
+		WHERE target_type = ? AND target_id = ? AND status = 'open'
+	`, resolution, resolverID, time.Now().UTC(), targetType, targetID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetModeratedCategories lists the categories a user moderates
+func GetModeratedCategories(conn Querier, userID int) ([]string, error) {
+	rows, err := conn.Query(`
+		SELECT c.name FROM category_moderators cm
+		JOIN categories c ON c.id = cm.category_id
+		WHERE cm.user_id = ?
+		ORDER BY c.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
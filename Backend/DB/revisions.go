@@ -0,0 +1,93 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type PostRevision struct {
+	ID         int
+	PostID     int
+	EditorName string
+	Title      string
+	Content    string
+	Categories []string
+	CreatedAt  time.Time // when this version was replaced
+}
+
+// UpdatePost saves the current version of a post as a revision and applies the edit
+func UpdatePost(conn Querier, postID, editorID int, title, content string, categories []string, editedAt time.Time) error {
+	return inTx(conn, func(tx *sql.Tx) error {
+		var oldTitle, oldContent string
+		var oldCategories sql.NullString
+		err := tx.QueryRow(`
+			SELECT p.title, p.content, GROUP_CONCAT(c.name, ',')
+			FROM posts p
+			LEFT JOIN post_categories pc ON p.id = pc.post_id
+			LEFT JOIN categories c ON pc.category_id = c.id
+			WHERE p.id = ?
+			GROUP BY p.id
+		`, postID).Scan(&oldTitle, &oldContent, &oldCategories)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO post_revisions (post_id, editor_id, title, content, categories, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, postID, editorID, oldTitle, oldContent, oldCategories.String, editedAt); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE posts SET title=?, content=?, updated_at=? WHERE id=?`,
+			title, content, editedAt, postID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM post_categories WHERE post_id=?`, postID); err != nil {
+			return err
+		}
+		for _, name := range categories {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO categories (name) VALUES (?)`, name); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`
+				INSERT OR IGNORE INTO post_categories (post_id, category_id)
+				SELECT ?, id FROM categories WHERE name = ?
+			`, postID, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPostRevisions fetches all previous versions of a post, oldest first
+func GetPostRevisions(conn *sql.DB, postID int) ([]PostRevision, error) {
+	rows, err := conn.Query(`
+		SELECT r.id, r.post_id, u.username, r.title, r.content, r.categories, r.created_at
+		FROM post_revisions r
+		JOIN users u ON u.id = r.editor_id
+		WHERE r.post_id = ?
+		ORDER BY r.id ASC
+	`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []PostRevision
+	for rows.Next() {
+		var rev PostRevision
+		var categories string
+		if err := rows.Scan(&rev.ID, &rev.PostID, &rev.EditorName, &rev.Title, &rev.Content, &categories, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(categories) != "" {
+			rev.Categories = parseCategories(categories)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
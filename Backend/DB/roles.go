@@ -0,0 +1,100 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// StaffMember is a user with a site-wide role or category moderation rights
+type StaffMember struct {
+	UserID     int
+	Username   string
+	Role       string
+	Categories []string // categories they moderate
+}
+
+// GetUserRole returns the site-wide role of a user
+func GetUserRole(conn *sql.DB, userID int) (string, error) {
+	var role string
+	err := conn.QueryRow(`SELECT role FROM users WHERE id = ?`, userID).Scan(&role)
+	return role, err
+}
+
+// SetUserRole changes the site-wide role of a user
+func SetUserRole(conn Querier, userID int, role string) error {
+	_, err := conn.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, userID)
+	return err
+}
+
+// IsCategoryModerator reports whether the user moderates any of the categories
+func IsCategoryModerator(conn *sql.DB, userID int, categories []string) (bool, error) {
+	if len(categories) == 0 {
+		return false, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(categories)), ",")
+	args := []interface{}{userID}
+	for _, c := range categories {
+		args = append(args, c)
+	}
+
+	var exists bool
+	err := conn.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM category_moderators cm
+			JOIN categories c ON cm.category_id = c.id
+			WHERE cm.user_id = ? AND c.name IN (`+placeholders+`)
+		)
+	`, args...).Scan(&exists)
+	return exists, err
+}
+
+// AddCategoryModerator makes the user a moderator of one category
+func AddCategoryModerator(conn Querier, userID int, category string) error {
+	categoryID, err := GetCategoryID(conn, category)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(`INSERT OR IGNORE INTO category_moderators (user_id, category_id) VALUES (?, ?)`, userID, categoryID)
+	return err
+}
+
+// RemoveCategoryModerator takes away a user's moderation of one category
+func RemoveCategoryModerator(conn Querier, userID int, category string) error {
+	_, err := conn.Exec(`
+		DELETE FROM category_moderators
+		WHERE user_id = ? AND category_id = (SELECT id FROM categories WHERE name = ?)
+	`, userID, category)
+	return err
+}
+
+// GetStaff lists admins, moderators and category moderators
+func GetStaff(conn *sql.DB) ([]StaffMember, error) {
+	rows, err := conn.Query(`
+		SELECT u.id, u.username, u.role, GROUP_CONCAT(c.name, ',')
+		FROM users u
+		LEFT JOIN category_moderators cm ON cm.user_id = u.id
+		LEFT JOIN categories c ON cm.category_id = c.id
+		WHERE u.role != 'user' OR cm.user_id IS NOT NULL
+		GROUP BY u.id
+		ORDER BY u.username
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var staff []StaffMember
+	for rows.Next() {
+		var m StaffMember
+		var categories sql.NullString
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &categories); err != nil {
+			return nil, err
+		}
+		if categories.Valid && categories.String != "" {
+			m.Categories = parseCategories(categories.String)
+		}
+		staff = append(staff, m)
+	}
+	return staff, rows.Err()
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Blocked word modes
+const (
+	WordMask   = "mask"   // replace the word with asterisks
+	WordReject = "reject" // refuse the post or comment
+)
+
+// HeldReason is the report reason of content held by the content filter
+const HeldReason = "held"
+
+// HeldPlaceholder replaces held comments for viewers who may not see them
+const HeldPlaceholder = "[awaiting moderation]"
+
+// BlockedWord is an entry of the word filter
+type BlockedWord struct {
+	ID        int
+	Word      string
+	Mode      string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// GetBlockedWords lists the word filter, alphabetically
+func GetBlockedWords(conn *sql.DB) ([]BlockedWord, error) {
+	rows, err := conn.Query(`
+		SELECT b.id, b.word, b.mode, COALESCE(u.username, ''), b.created_at
+		FROM blocked_words b
+		LEFT JOIN users u ON u.id = b.created_by
+		ORDER BY b.word
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var words []BlockedWord
+	for rows.Next() {
+		var b BlockedWord
+		if err := rows.Scan(&b.ID, &b.Word, &b.Mode, &b.CreatedBy, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		words = append(words, b)
+	}
+	return words, rows.Err()
+}
+
+// SetBlockedWord adds a word to the filter or changes its mode, returning its ID
+func SetBlockedWord(conn Querier, word, mode string, createdBy int) (int, error) {
+	_, err := conn.Exec(`
+		INSERT INTO blocked_words (word, mode, created_by, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(word) DO UPDATE SET mode = excluded.mode
+	`, word, mode, createdBy, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = conn.QueryRow(`SELECT id FROM blocked_words WHERE word = ?`, word).Scan(&id)
+	return id, err
+}
+
+// GetBlockedWordID returns the ID of a filtered word, or sql.ErrNoRows
+func GetBlockedWordID(conn Querier, word string) (int, error) {
+	var id int
+	err := conn.QueryRow(`SELECT id FROM blocked_words WHERE word = ?`, word).Scan(&id)
+	return id, err
+}
+
+// DeleteBlockedWord removes a word from the filter
+func DeleteBlockedWord(conn Querier, id int) error {
+	_, err := conn.Exec(`DELETE FROM blocked_words WHERE id = ?`, id)
+	return err
+}
+
+// normalizeForDuplicates lowercases text and collapses whitespace so trivial
+// variations of the same message compare equal
+func normalizeForDuplicates(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// HasRecentDuplicate reports whether the user posted the same text, as a
+// post or a comment, since the given time. The post excludeID, which is
+// being edited, is not compared with itself.
+func HasRecentDuplicate(conn *sql.DB, userID, excludeID int, text string, since time.Time) (bool, error) {
+	want := normalizeForDuplicates(text)
+	rows, err := conn.Query(`
+		SELECT content FROM posts WHERE user_id = ? AND id != ? AND created_at >= ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT content FROM comments WHERE user_id = ? AND created_at >= ? AND deleted_at IS NULL
+	`, userID, excludeID, since, userID, since)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return false, err
+		}
+		if normalizeForDuplicates(content) == want {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// HoldContent hides a post or comment and files a report from the content
+// filter so it shows up in the moderation queue. Pass the transaction that
+// saved the content so it is never visible before being held.
+func HoldContent(conn Querier, targetType string, targetID, postID int, reasons []string) error {
+	table := "posts"
+	if targetType == "comment" {
+		table = "comments"
+	}
+
+	return inTx(conn, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		if _, err := tx.Exec(`UPDATE `+table+` SET held = 1, hidden_at = ? WHERE id = ?`, now, targetID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO reports (reporter_id, target_type, target_id, post_id, reason, details, created_at)
+			VALUES (0, ?, ?, ?, ?, ?, ?)
+		`, targetType, targetID, postID, HeldReason, strings.Join(reasons, "; "), now)
+		return err
+	})
+}
+
+// ApproveHeld publishes held content
+func ApproveHeld(conn Querier, targetType string, targetID int) error {
+	table := "posts"
+	if targetType == "comment" {
+		table = "comments"
+	}
+	_, err := conn.Exec(`UPDATE `+table+` SET held = 0, hidden_at = NULL WHERE id = ? AND held = 1`, targetID)
+	return err
+}
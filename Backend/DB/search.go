@@ -0,0 +1,288 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+type SearchResult struct {
+	Kind      string // "post" or "comment"
+	PostID    int
+	CommentID int // 0 for post results
+	Title     string
+	Snippet   []SnippetPart
+	Username  string
+	CreatedAt time.Time
+	Rank      float64 // bm25, lower is better; 0 without the search index
+}
+
+// SnippetPart is a piece of a search snippet, either plain text or a
+// matched term
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+// snippetRunes is about as long as the snippets FTS5 makes of 16 tokens
+const snippetRunes = 100
+
+// BuildMatchQuery turns free text into a safe FTS5 query: every word is quoted
+// and prefix-matched, and all words must appear.
+func BuildMatchQuery(input string) string {
+	var terms []string
+	for _, word := range strings.Fields(input) {
+		word = strings.ReplaceAll(word, `"`, `""`)
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchPosts finds posts and comments matching the query, ranked by bm25.
+// Title matches weigh more than content matches. When categories are given,
+// only posts in one of them (and comments on those posts) are returned.
+// Content by shadow-banned users is only found by themselves (viewerID).
+// Without the full-text index, which needs FTS5, it falls back to
+// searchPostsLike.
+func SearchPosts(conn *sql.DB, query string, categories []string, viewerID, limit int) ([]SearchResult, error) {
+	match := BuildMatchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	var indexed bool
+	err := conn.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'posts_fts')`).Scan(&indexed)
+	if err != nil {
+		return nil, err
+	}
+	if !indexed {
+		return searchPostsLike(conn, strings.Fields(query), categories, viewerID, limit)
+	}
+
+	filter, filterArgs := categoryFilter(categories)
+	if filter != "" {
+		filter = "AND " + filter
+	}
+	start, end, err := snippetMarkers()
+	if err != nil {
+		return nil, err
+	}
+
+	q := `
+		SELECT 'post', p.id, 0, p.title,
+			snippet(posts_fts, -1, ?, ?, '…', 16),
+			u.username, p.created_at, bm25(posts_fts, 10.0, 1.0) AS rank
+		FROM posts_fts
+		JOIN posts p ON p.id = posts_fts.rowid
+		JOIN users u ON u.id = p.user_id
+		WHERE posts_fts MATCH ? AND p.deleted_at IS NULL AND p.hidden_at IS NULL AND ` + shadowFilter + ` ` + filter + `
+		UNION ALL
+		SELECT 'comment', p.id, c.id, p.title,
+			snippet(comments_fts, 0, ?, ?, '…', 16),
+			u.username, c.created_at, bm25(comments_fts) AS rank
+		FROM comments_fts
+		JOIN comments c ON c.id = comments_fts.rowid
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON u.id = c.user_id
+		WHERE comments_fts MATCH ? AND c.deleted_at IS NULL AND c.hidden_at IS NULL AND p.deleted_at IS NULL AND p.hidden_at IS NULL
+			AND ` + shadowFilter + ` AND p.user_id IN (SELECT id FROM users WHERE shadow_banned = 0 OR id = ?) ` + filter + `
+		ORDER BY rank
+		LIMIT ?
+	`
+
+	args := []interface{}{start, end, match, viewerID}
+	args = append(args, filterArgs...)
+	args = append(args, start, end, match, viewerID, viewerID)
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	rows, err := conn.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var res SearchResult
+		var snippet string
+		if err := rows.Scan(&res.Kind, &res.PostID, &res.CommentID, &res.Title, &snippet,
+			&res.Username, &res.CreatedAt, &res.Rank); err != nil {
+			return nil, err
+		}
+		res.Snippet = splitSnippet(snippet, start, end)
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// snippetMarkers returns the markers snippet() puts around matched terms.
+// They are random, so no post can contain them and fake a match.
+func snippetMarkers() (start, end string, err error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	nonce := hex.EncodeToString(buf)
+	return "\x02" + nonce + "\x02", "\x03" + nonce + "\x03", nil
+}
+
+// splitSnippet cuts a snippet at the markers snippet() put around matches
+func splitSnippet(snippet, start, end string) []SnippetPart {
+	var parts []SnippetPart
+	for snippet != "" {
+		i := strings.Index(snippet, start)
+		if i < 0 {
+			return append(parts, SnippetPart{Text: snippet})
+		}
+		if i > 0 {
+			parts = append(parts, SnippetPart{Text: snippet[:i]})
+		}
+		snippet = snippet[i+len(start):]
+		j := strings.Index(snippet, end)
+		if j < 0 {
+			j = len(snippet)
+		}
+		parts = append(parts, SnippetPart{Text: snippet[:j], Match: true})
+		snippet = strings.TrimPrefix(snippet[j:], end)
+	}
+	return parts
+}
+
+// searchPostsLike is SearchPosts without the full-text index: every word
+// must appear in the title or content, newest results first. Only ASCII
+// letters match case-insensitively.
+func searchPostsLike(conn *sql.DB, words []string, categories []string, viewerID, limit int) ([]SearchResult, error) {
+	filter, filterArgs := categoryFilter(categories)
+	if filter != "" {
+		filter = "AND " + filter
+	}
+
+	var postMatch, commentMatch []string
+	var postArgs, commentArgs []interface{}
+	for _, word := range words {
+		pattern := "%" + likeEscaper.Replace(word) + "%"
+		postMatch = append(postMatch, `(p.title LIKE ? ESCAPE '\' OR p.content LIKE ? ESCAPE '\')`)
+		postArgs = append(postArgs, pattern, pattern)
+		commentMatch = append(commentMatch, `c.content LIKE ? ESCAPE '\'`)
+		commentArgs = append(commentArgs, pattern)
+	}
+
+	q := `
+		SELECT 'post', p.id, 0, p.title, p.content, u.username, p.created_at
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + strings.Join(postMatch, " AND ") + ` AND p.deleted_at IS NULL AND p.hidden_at IS NULL AND ` + shadowFilter + ` ` + filter + `
+		UNION ALL
+		SELECT 'comment', p.id, c.id, p.title, c.content, u.username, c.created_at
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON u.id = c.user_id
+		WHERE ` + strings.Join(commentMatch, " AND ") + ` AND c.deleted_at IS NULL AND c.hidden_at IS NULL AND p.deleted_at IS NULL AND p.hidden_at IS NULL
+			AND ` + shadowFilter + ` AND p.user_id IN (SELECT id FROM users WHERE shadow_banned = 0 OR id = ?) ` + filter + `
+		ORDER BY 7 DESC
+		LIMIT ?
+	`
+
+	args := append(postArgs, viewerID)
+	args = append(args, filterArgs...)
+	args = append(args, commentArgs...)
+	args = append(args, viewerID, viewerID)
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	rows, err := conn.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var res SearchResult
+		var content string
+		if err := rows.Scan(&res.Kind, &res.PostID, &res.CommentID, &res.Title, &content,
+			&res.Username, &res.CreatedAt); err != nil {
+			return nil, err
+		}
+		res.Snippet = likeSnippet(content, words)
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeSnippet cuts text around the first of the words it contains and marks
+// every occurrence of them, like FTS5's snippet()
+func likeSnippet(text string, words []string) []SnippetPart {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		lower = runes // lowercasing changed the length; match case-sensitively
+	}
+	var terms [][]rune
+	for _, w := range words {
+		terms = append(terms, []rune(strings.ToLower(w)))
+	}
+
+	// matchAt returns the length of the longest term found at lower[i:]
+	matchAt := func(i int) int {
+		longest := 0
+		for _, t := range terms {
+			if len(t) > longest && i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == string(t) {
+				longest = len(t)
+			}
+		}
+		return longest
+	}
+
+	first := 0
+	for i := range lower {
+		if matchAt(i) > 0 {
+			first = i
+			break
+		}
+	}
+	start := first - snippetRunes/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var parts []SnippetPart
+	var plain strings.Builder
+	if start > 0 {
+		plain.WriteString("…")
+	}
+	for i := start; i < end; {
+		n := matchAt(i)
+		if n == 0 {
+			plain.WriteRune(runes[i])
+			i++
+			continue
+		}
+		if plain.Len() > 0 {
+			parts = append(parts, SnippetPart{Text: plain.String()})
+			plain.Reset()
+		}
+		parts = append(parts, SnippetPart{Text: string(runes[i : i+n]), Match: true})
+		i += n
+	}
+	if end < len(runes) {
+		plain.WriteString("…")
+	}
+	if plain.Len() > 0 {
+		parts = append(parts, SnippetPart{Text: plain.String()})
+	}
+	return parts
+}
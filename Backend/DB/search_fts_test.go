@@ -0,0 +1,61 @@
+//go:build sqlite_fts5
+
+package db_test
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"strings"
+	"testing"
+)
+
+// With -tags sqlite_fts5 the search index is built and SearchPosts ranks
+// with bm25
+func TestSearchPostsFTS(t *testing.T) {
+	conn := dbtest.Open(t)
+	var indexed bool
+	if err := conn.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = 'posts_fts')`).Scan(&indexed); err != nil {
+		t.Fatal(err)
+	}
+	if !indexed {
+		t.Fatal("the search index migration didn't run")
+	}
+	userID := dbtest.CreateUser(t, conn, "alice")
+	inContent := addPost(t, conn, userID, 0, "Boss guide", "How to beat the dragon without armour")
+	inTitle := addPost(t, conn, userID, 1, "Dragon strategies", "Bring beds")
+	addPost(t, conn, userID, 2, "Quoting", `He said "OR" and left`)
+
+	t.Run("title matches rank first", func(t *testing.T) {
+		results, err := db.SearchPosts(conn, "drag", nil, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].PostID != inTitle || results[1].PostID != inContent {
+			t.Fatalf("results %+v, want post %d then %d", results, inTitle, inContent)
+		}
+		if got := matches(results[1].Snippet); len(got) != 1 || got[0] != "dragon" {
+			t.Errorf("matched %q, want [dragon]", got)
+		}
+	})
+
+	t.Run("query syntax is searched for", func(t *testing.T) {
+		for _, query := range []string{`"OR"`, "OR", `said"`} {
+			if _, err := db.SearchPosts(conn, query, nil, 0, 10); err != nil {
+				t.Errorf("%q: %v", query, err)
+			}
+		}
+	})
+
+	t.Run("edits are indexed", func(t *testing.T) {
+		if _, err := conn.Exec(`UPDATE posts SET content = 'Now about wither skeletons' WHERE id = ?`, inContent); err != nil {
+			t.Fatal(err)
+		}
+		results, err := db.SearchPosts(conn, "wither", nil, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || !strings.Contains(results[0].Snippet[len(results[0].Snippet)-1].Text, "skeletons") {
+			t.Errorf("results %+v, want the edited post", results)
+		}
+	})
+}
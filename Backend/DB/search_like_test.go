@@ -0,0 +1,88 @@
+//go:build !sqlite_fts5
+
+package db_test
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"strings"
+	"testing"
+)
+
+// Without FTS5 the search index migration is skipped and SearchPosts
+// matches with LIKE
+func TestSearchPostsLike(t *testing.T) {
+	conn := dbtest.Open(t)
+	userID := dbtest.CreateUser(t, conn, "alice")
+	long := strings.Repeat("filler ", 40) + "the Nether portal " + strings.Repeat("filler ", 40)
+	older := addPost(t, conn, userID, 0, "Portals", long)
+	newer := addPost(t, conn, userID, 1, "Nether tips", "Bring fire resistance to the NETHER, then more nether")
+	addPost(t, conn, userID, 2, "Discounts", "Everything 1000 off")
+	addPost(t, conn, userID, 3, "Nether hub", "Sorted by portal_name")
+
+	t.Run("newest first, every word", func(t *testing.T) {
+		results, err := db.SearchPosts(conn, "nether portal", nil, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, r := range results {
+			ids = append(ids, r.PostID)
+		}
+		if len(ids) != 2 || ids[0] == older || ids[1] != older {
+			t.Errorf("found posts %v, want the hub then %d", ids, older)
+		}
+	})
+
+	t.Run("snippet is cut around the first match", func(t *testing.T) {
+		results, err := db.SearchPosts(conn, "portal", []string{"minecraft"}, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parts []db.SnippetPart
+		for _, r := range results {
+			if r.PostID == older {
+				parts = r.Snippet
+			}
+		}
+		if len(parts) < 3 {
+			t.Fatalf("snippet %+v, want text around a match", parts)
+		}
+		if !strings.HasPrefix(parts[0].Text, "…") || !strings.HasSuffix(parts[len(parts)-1].Text, "…") {
+			t.Errorf("snippet %+v isn't cut on both sides", parts)
+		}
+		if got := matches(parts); len(got) != 1 || got[0] != "portal" {
+			t.Errorf("matched %q, want [portal]", got)
+		}
+	})
+
+	t.Run("every occurrence is marked in its own case", func(t *testing.T) {
+		results, err := db.SearchPosts(conn, "NeThEr", nil, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.PostID != newer {
+				continue
+			}
+			got := matches(r.Snippet)
+			if len(got) != 2 || got[0] != "NETHER" || got[1] != "nether" {
+				t.Errorf("matched %q, want [NETHER nether]", got)
+			}
+			return
+		}
+		t.Errorf("post %d not found", newer)
+	})
+
+	t.Run("wildcards are literal", func(t *testing.T) {
+		for _, query := range []string{"100%", "by_portal", "1_00"} {
+			results, err := db.SearchPosts(conn, query, nil, 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 0 {
+				t.Errorf("%q found %d results, want none", query, len(results))
+			}
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package db_test
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildMatchQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"zelda", `"zelda"*`},
+		{"  elden   ring ", `"elden"* "ring"*`},
+		// FTS5 syntax is quoted, so it is searched for rather than run
+		{"dark OR souls", `"dark"* "OR"* "souls"*`},
+		{"title:boss NEAR(a b)", `"title:boss"* "NEAR(a"* "b)"*`},
+		{`say "hi"`, `"say"* """hi"""*`},
+		{`"`, `""""*`},
+	}
+	for _, tt := range tests {
+		if got := db.BuildMatchQuery(tt.input); got != tt.want {
+			t.Errorf("BuildMatchQuery(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// addPost adds a post in the minecraft category, created minutes after a
+// fixed time so results have a stable order
+func addPost(t *testing.T, conn *sql.DB, userID, minutes int, title, content string) int {
+	t.Helper()
+	created := time.Date(2024, 1, 1, 0, minutes, 0, 0, time.UTC)
+	res, err := conn.Exec(`INSERT INTO posts (user_id, title, content, created_at) VALUES (?, ?, ?, ?)`,
+		userID, title, content, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(`INSERT INTO post_categories (post_id, category_id) SELECT ?, id FROM categories WHERE name = 'minecraft'`, id); err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// matches returns the matched terms of a snippet
+func matches(parts []db.SnippetPart) []string {
+	var terms []string
+	for _, p := range parts {
+		if p.Match {
+			terms = append(terms, p.Text)
+		}
+	}
+	return terms
+}
+
+func TestSearchPostsPaging(t *testing.T) {
+	conn := dbtest.Open(t)
+	userID := dbtest.CreateUser(t, conn, "alice")
+	for i := 0; i < db.DefaultPageSize+5; i++ {
+		addPost(t, conn, userID, i, "Side quest", "Where does this quest start?")
+	}
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, db.DefaultPageSize},
+		{-1, db.DefaultPageSize},
+		{3, 3},
+		{100, db.DefaultPageSize + 5},
+	}
+	for _, tt := range tests {
+		results, err := db.SearchPosts(conn, "quest", nil, 0, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != tt.want {
+			t.Errorf("limit %d: %d results, want %d", tt.limit, len(results), tt.want)
+		}
+	}
+
+	results, err := db.SearchPosts(conn, "quest", []string{"souls games"}, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("searching another category found %d results", len(results))
+	}
+}
+
+func TestSearchSnippetsIgnoreMarkerBytes(t *testing.T) {
+	conn := dbtest.Open(t)
+	userID := dbtest.CreateUser(t, conn, "alice")
+	// The bytes older versions used to mark matches, unbalanced on purpose
+	addPost(t, conn, userID, 0, "Fake marks", "a \x03 creeper \x02 blew up \x02 my house")
+
+	results, err := db.SearchPosts(conn, "creeper", nil, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d results, want 1", len(results))
+	}
+	got := matches(results[0].Snippet)
+	if len(got) != 1 || !strings.EqualFold(got[0], "creeper") {
+		t.Errorf("matched %q, want only creeper", got)
+	}
+	var text strings.Builder
+	for _, p := range results[0].Snippet {
+		text.WriteString(p.Text)
+	}
+	if !strings.Contains(text.String(), "blew up \x02 my") {
+		t.Errorf("snippet %q lost the post's own text", text.String())
+	}
+}
@@ -6,38 +6,116 @@ import (
 )
 
 type SessionRecord struct {
+	ID       int
 	Token    string
 	UserID   sql.NullInt64
 	Username sql.NullString
 	Expires  time.Time
+	LastSeen time.Time
+	Remember bool
+}
+
+// DeviceSession is a session as shown on the devices page
+type DeviceSession struct {
+	ID         int
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
 }
 
 func GetSessionByToken(conn *sql.DB, token string) (*SessionRecord, error) {
 	var s SessionRecord
+	var lastSeen sql.NullTime
 	err := conn.QueryRow(`
-		SELECT s.token, s.user_id, u.username, s.expires_at
+		SELECT s.id, s.token, s.user_id, u.username, s.expires_at, s.last_seen_at, s.remember
 		FROM sessions s
 		LEFT JOIN users u ON s.user_id = u.id
 		WHERE s.token=? AND (s.expires_at IS NULL OR s.expires_at>CURRENT_TIMESTAMP)
-	`, token).Scan(&s.Token, &s.UserID, &s.Username, &s.Expires)
+	`, token).Scan(&s.ID, &s.Token, &s.UserID, &s.Username, &s.Expires, &lastSeen, &s.Remember)
 	if err != nil {
 		return nil, err
 	}
+	s.LastSeen = lastSeen.Time
 	return &s, nil
 }
 
-func CreateSession(conn *sql.DB, userID int, token string, expires time.Time) error {
-	_, err := conn.Exec(`INSERT INTO sessions (token, user_id, expires_at) VALUES (?, ?, ?)`,
-		token, userID, expires)
+func CreateSession(conn *sql.DB, userID int, token string, expires time.Time, remember bool, userAgent, ip string) error {
+	_, err := conn.Exec(`INSERT INTO sessions (token, user_id, expires_at, remember, user_agent, ip, last_seen_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token, userID, expires, remember, userAgent, ip, time.Now().UTC())
+	return err
+}
+
+// TouchSession records activity on a session from the given IP and moves its expiry
+func TouchSession(conn *sql.DB, id int, ip string, seenAt, expires time.Time) error {
+	_, err := conn.Exec(`UPDATE sessions SET last_seen_at = ?, ip = ?, expires_at = ? WHERE id = ?`, seenAt, ip, expires, id)
 	return err
 }
 
+// DeleteExpiredSessions removes sessions that expired before now
+func DeleteExpiredSessions(conn *sql.DB, now time.Time) (int64, error) {
+	res, err := conn.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetUserSessions lists a user's unexpired sessions, most recently active first
+func GetUserSessions(conn *sql.DB, userID int) ([]DeviceSession, error) {
+	rows, err := conn.Query(`
+		SELECT id, user_agent, ip, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		ORDER BY COALESCE(last_seen_at, created_at) DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []DeviceSession
+	for rows.Next() {
+		var d DeviceSession
+		var lastSeen, expires sql.NullTime
+		if err := rows.Scan(&d.ID, &d.UserAgent, &d.IP, &d.CreatedAt, &lastSeen, &expires); err != nil {
+			return nil, err
+		}
+		d.LastSeenAt, d.ExpiresAt = d.CreatedAt, expires.Time
+		if lastSeen.Valid {
+			d.LastSeenAt = lastSeen.Time
+		}
+		sessions = append(sessions, d)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteUserSession ends one of the user's sessions; false if it wasn't theirs
+func DeleteUserSession(conn *sql.DB, id, userID int) (bool, error) {
+	res, err := conn.Exec(`DELETE FROM sessions WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteOtherUserSessions ends all of the user's sessions except the one with keepToken
+func DeleteOtherUserSessions(conn *sql.DB, userID int, keepToken string) (int64, error) {
+	res, err := conn.Exec(`DELETE FROM sessions WHERE user_id = ? AND token != ?`, userID, keepToken)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 func DeleteSessionByToken(conn *sql.DB, token string) error {
 	_, err := conn.Exec(`DELETE FROM sessions WHERE token=?`, token)
 	return err
 }
 
-func DeleteUserSessions(conn *sql.DB, userID int) error {
+func DeleteUserSessions(conn Querier, userID int) error {
 	_, err := conn.Exec(`DELETE FROM sessions WHERE user_id=?`, userID)
 	return err
 }
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type APIToken struct {
+	ID         int
+	UserID     int
+	Username   string
+	Name       string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// CreateAPIToken stores a new token by its hash
+func CreateAPIToken(conn *sql.DB, userID int, name, tokenHash string, scopes []string, createdAt time.Time) (int, error) {
+	res, err := conn.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_hash, scopes, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, name, tokenHash, strings.Join(scopes, ","), createdAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetAPITokens lists a user's tokens, newest first
+func GetAPITokens(conn *sql.DB, userID int) ([]APIToken, error) {
+	rows, err := conn.Query(`
+		SELECT t.id, t.user_id, u.username, t.name, t.scopes, t.created_at, t.last_used_at
+		FROM api_tokens t
+		JOIN users u ON t.user_id = u.id
+		WHERE t.user_id = ?
+		ORDER BY t.created_at DESC, t.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetAPITokenByHash looks up a token for authentication; nil if unknown
+func GetAPITokenByHash(conn *sql.DB, tokenHash string) (*APIToken, error) {
+	row := conn.QueryRow(`
+		SELECT t.id, t.user_id, u.username, t.name, t.scopes, t.created_at, t.last_used_at
+		FROM api_tokens t
+		JOIN users u ON t.user_id = u.id
+		WHERE t.token_hash = ?
+	`, tokenHash)
+	t, err := scanAPIToken(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+func scanAPIToken(row interface{ Scan(...interface{}) error }) (*APIToken, error) {
+	var t APIToken
+	var scopes string
+	var lastUsed sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Username, &t.Name, &scopes, &t.CreatedAt, &lastUsed); err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	return &t, nil
+}
+
+// TouchAPIToken records that a token was just used
+func TouchAPIToken(conn *sql.DB, tokenID int, usedAt time.Time) error {
+	_, err := conn.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, usedAt, tokenID)
+	return err
+}
+
+// DeleteAPIToken revokes one of the user's tokens; false if it wasn't theirs
+func DeleteAPIToken(conn *sql.DB, tokenID, userID int) (bool, error) {
+	res, err := conn.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, tokenID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,220 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TOTP is a user's authenticator secret. Enabled is false while the
+// enrolment waits to be confirmed with a first code.
+type TOTP struct {
+	UserID    int
+	Secret    string
+	Enabled   bool
+	EnabledAt time.Time
+	// LastStep is the time step of the last accepted code, so a code cannot be used twice
+	LastStep int64
+}
+
+// LoginChallenge is a login that passed the password check and waits for
+// the second factor
+type LoginChallenge struct {
+	ID         int
+	UserID     int
+	Identifier string
+	Remember   bool
+	Attempts   int
+}
+
+// GetTOTP returns a user's authenticator secret, or nil if they have none
+func GetTOTP(conn *sql.DB, userID int) (*TOTP, error) {
+	var t TOTP
+	var enabledAt sql.NullTime
+	err := conn.QueryRow(`
+		SELECT user_id, secret, enabled_at, last_step FROM user_totp WHERE user_id = ?
+	`, userID).Scan(&t.UserID, &t.Secret, &enabledAt, &t.LastStep)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Enabled, t.EnabledAt = enabledAt.Valid, enabledAt.Time
+	return &t, nil
+}
+
+// IsTOTPEnabled reports whether logging in to the account needs a second factor
+func IsTOTPEnabled(conn *sql.DB, userID int) (bool, error) {
+	var enabled bool
+	err := conn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = ? AND enabled_at IS NOT NULL)
+	`, userID).Scan(&enabled)
+	return enabled, err
+}
+
+// SetPendingTOTP starts an enrolment with a new secret, replacing an
+// unconfirmed one. An enabled secret is left alone.
+func SetPendingTOTP(conn *sql.DB, userID int, secret string) error {
+	_, err := conn.Exec(`
+		INSERT INTO user_totp (user_id, secret, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_step = 0, created_at = excluded.created_at
+		WHERE user_totp.enabled_at IS NULL
+	`, userID, secret, time.Now().UTC())
+	return err
+}
+
+// EnableTOTP confirms a pending enrolment with the time step of its first
+// code and stores the recovery codes, in one transaction. False if there
+// was no pending enrolment.
+func EnableTOTP(conn *sql.DB, userID int, step int64, codeHashes []string) (bool, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	res, err := tx.Exec(`
+		UPDATE user_totp SET enabled_at = ?, last_step = ? WHERE user_id = ? AND enabled_at IS NULL
+	`, now, step, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes, now); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// UseTOTPStep records that a code for the time step was accepted. False if
+// that step or a later one was already used.
+func UseTOTPStep(conn *sql.DB, userID int, step int64) (bool, error) {
+	res, err := conn.Exec(`
+		UPDATE user_totp SET last_step = ? WHERE user_id = ? AND enabled_at IS NOT NULL AND last_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DisableTOTP removes a user's secret, recovery codes and pending login challenges
+func DisableTOTP(conn *sql.DB, userID int) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM user_totp WHERE user_id = ?`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		`DELETE FROM login_challenges WHERE user_id = ?`,
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new ones
+func ReplaceRecoveryCodes(conn *sql.DB, userID int, codeHashes []string) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string, now time.Time) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`
+			INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)
+		`, userID, hash, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks a recovery code as used. False if the user has no
+// unused code with that hash.
+func UseRecoveryCode(conn *sql.DB, userID int, codeHash string) (bool, error) {
+	res, err := conn.Exec(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE id = (SELECT id FROM recovery_codes WHERE user_id = ? AND code_hash = ? AND used_at IS NULL LIMIT 1)
+	`, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes counts a user's unused recovery codes
+func CountRecoveryCodes(conn *sql.DB, userID int) (int, error) {
+	var n int
+	err := conn.QueryRow(`
+		SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&n)
+	return n, err
+}
+
+// CreateLoginChallenge stores a pending second login step by its token hash
+func CreateLoginChallenge(conn *sql.DB, tokenHash string, userID int, identifier string, remember bool, expiresAt time.Time) error {
+	_, err := conn.Exec(`
+		INSERT INTO login_challenges (token_hash, user_id, identifier, remember, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, tokenHash, userID, identifier, remember, expiresAt.UTC(), time.Now().UTC())
+	return err
+}
+
+// GetLoginChallenge looks up an unexpired challenge; nil if there is none
+func GetLoginChallenge(conn *sql.DB, tokenHash string) (*LoginChallenge, error) {
+	var c LoginChallenge
+	err := conn.QueryRow(`
+		SELECT id, user_id, identifier, remember, attempts FROM login_challenges
+		WHERE token_hash = ? AND expires_at > ?
+	`, tokenHash, time.Now().UTC()).Scan(&c.ID, &c.UserID, &c.Identifier, &c.Remember, &c.Attempts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// AddLoginChallengeAttempt counts a code entered for a challenge
+func AddLoginChallengeAttempt(conn *sql.DB, id int) error {
+	_, err := conn.Exec(`UPDATE login_challenges SET attempts = attempts + 1 WHERE id = ?`, id)
+	return err
+}
+
+// DeleteLoginChallenge removes a challenge once it is used up
+func DeleteLoginChallenge(conn *sql.DB, id int) error {
+	_, err := conn.Exec(`DELETE FROM login_challenges WHERE id = ?`, id)
+	return err
+}
+
+// DeleteExpiredLoginChallenges removes challenges that expired before now
+func DeleteExpiredLoginChallenges(conn *sql.DB, now time.Time) (int64, error) {
+	res, err := conn.Exec(`DELETE FROM login_challenges WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
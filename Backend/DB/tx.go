@@ -0,0 +1,41 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Querier runs statements on the database or inside a transaction. Both
+// *sql.DB and *sql.Tx satisfy it, so a change can be one step of a larger
+// transaction, such as an audited moderator action.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in q when q is already a transaction. Otherwise it starts
+// one, committed if fn succeeds and rolled back if not.
+func inTx(q Querier, fn func(tx *sql.Tx) error) error {
+	switch q := q.(type) {
+	case *sql.Tx:
+		return fn(q)
+	case *sql.DB:
+		tx, err := q.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	return fmt.Errorf("db: cannot start a transaction on %T", q)
+}
+
+// Transaction runs fn in a new transaction on conn, committed if fn succeeds
+// and rolled back if not
+func Transaction(conn *sql.DB, fn func(q Querier) error) error {
+	return inTx(conn, func(tx *sql.Tx) error { return fn(tx) })
+}
@@ -1,21 +1,41 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type User struct {
-	ID       int
-	Username string
-	Email    string
-	Password string
+	ID            int
+	Username      string
+	Email         string
+	Password      string
+	EmailVerified bool
 }
 
+// GetUserByIdentifier finds the user to log in or reset by username or
+// email. The [deleted user] account has no email and is never found.
 func GetUserByIdentifier(conn *sql.DB, identifier string) (*User, error) {
 	var u User
 	err := conn.QueryRow(`
-		SELECT id, username, password, email
+		SELECT id, username, password, email, email_verified_at IS NOT NULL
 		FROM users
-		WHERE LOWER(username)=LOWER(?) OR LOWER(email)=LOWER(?)
-	`, identifier, identifier).Scan(&u.ID, &u.Username, &u.Password, &u.Email)
+		WHERE (LOWER(username)=LOWER(?) OR LOWER(email)=LOWER(?)) AND email != ''
+	`, identifier, identifier).Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.EmailVerified)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUserByEmail returns the user with the given email address
+func GetUserByEmail(conn *sql.DB, email string) (*User, error) {
+	var u User
+	err := conn.QueryRow(`
+		SELECT id, username, password, email, email_verified_at IS NOT NULL
+		FROM users
+		WHERE LOWER(email)=LOWER(?)
+	`, email).Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.EmailVerified)
 	if err != nil {
 		return nil, err
 	}
@@ -65,3 +85,153 @@ func UserExists(conn *sql.DB, userID int) (bool, error) {
 	err := conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&exists)
 	return exists, err
 }
+
+// GetUserByID returns a user by ID
+func GetUserByID(conn *sql.DB, userID int) (*User, error) {
+	var u User
+	err := conn.QueryRow(`
+		SELECT id, username, password, email, email_verified_at IS NOT NULL
+		FROM users
+		WHERE id = ?
+	`, userID).Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.EmailVerified)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func IsEmailVerified(conn *sql.DB, userID int) (bool, error) {
+	var verified bool
+	err := conn.QueryRow(`SELECT email_verified_at IS NOT NULL FROM users WHERE id = ?`, userID).Scan(&verified)
+	return verified, err
+}
+
+// MarkEmailVerified confirms the user's address, provided it is still email.
+// False if it changed since or was already verified.
+func MarkEmailVerified(conn *sql.DB, userID int, email string) (bool, error) {
+	res, err := conn.Exec(`
+		UPDATE users SET email_verified_at = ? WHERE id = ? AND email = ? AND email_verified_at IS NULL
+	`, time.Now().UTC(), userID, email)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetAppSecret returns a server-side key created by the migrations
+func GetAppSecret(conn *sql.DB, name string) (string, error) {
+	var value string
+	err := conn.QueryRow(`SELECT value FROM app_secrets WHERE name = ?`, name).Scan(&value)
+	return value, err
+}
+
+// UpdatePassword sets a new password hash. Outstanding reset links stop
+// working and every session except keepToken ends.
+func UpdatePassword(conn *sql.DB, userID int, passwordHash, keepToken string) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET password = ? WHERE id = ?`, passwordHash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, time.Now().UTC(), userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ? AND token != ?`, userID, keepToken); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateEmail changes a user's address, which then needs verifying again.
+// Reset links sent to the old address stop working.
+func UpdateEmail(conn *sql.DB, userID int, email string) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET email = ?, email_verified_at = NULL WHERE id = ?`, email, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, time.Now().UTC(), userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ChangeUsername renames a user and keeps the old name in the history. If
+// the new name is someone's old one, it now belongs to this user instead.
+func ChangeUsername(conn *sql.DB, userID int, oldUsername, newUsername string) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET username = ? WHERE id = ?`, newUsername, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM username_history WHERE LOWER(old_username) = LOWER(?)`, newUsername); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO username_history (user_id, old_username, changed_at) VALUES (?, ?, ?)
+	`, userID, oldUsername, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUserIDByOldUsername finds who used to be called username, most
+// recent first; 0 if nobody
+func GetUserIDByOldUsername(conn *sql.DB, username string) (int, error) {
+	var id int
+	err := conn.QueryRow(`
+		SELECT user_id FROM username_history
+		WHERE LOWER(old_username) = LOWER(?)
+		ORDER BY changed_at DESC LIMIT 1
+	`, username).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// GetLastUsernameChange returns when the user last changed their username,
+// or the zero time if they never did
+func GetLastUsernameChange(conn *sql.DB, userID int) (time.Time, error) {
+	var changed time.Time
+	err := conn.QueryRow(`
+		SELECT changed_at FROM username_history WHERE user_id = ? ORDER BY changed_at DESC LIMIT 1
+	`, userID).Scan(&changed)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return changed, err
+}
+
+// GetOldUsernames lists the names the user had before renaming, oldest first
+func GetOldUsernames(conn *sql.DB, userID int) ([]string, error) {
+	rows, err := conn.Query(`SELECT old_username FROM username_history WHERE user_id = ? ORDER BY changed_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
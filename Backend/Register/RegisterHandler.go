@@ -1,9 +1,13 @@
 package register
 
 import (
+	"database/sql"
+	"fmt"
 	db "forum/Backend/DB"
 	"forum/Backend/errors"
 	"forum/Backend/login"
+	"forum/Backend/oauth"
+	"forum/Backend/security"
 	"html/template"
 	"net/http"
 	"strings"
@@ -14,27 +18,19 @@ import (
 // RegisterHandler handles user registration
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	dbConn := db.DB
-	tmpl, err := template.ParseFiles("templates/register.html")
+	tmpl, err := template.New("register.html").Funcs(security.Funcs(r)).Funcs(template.FuncMap{
+		"providers": oauth.Providers,
+	}).ParseFiles("templates/register.html")
 	if err != nil {
 		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
 		return
 	}
 
-	// End active session if user already logged in
-	session, _ := login.GetSessionFromRequest(r)
-	if session != nil && !session.IsGuest {
-		if dbConn != nil {
-			_ = db.DeleteSessionByToken(dbConn, session.Token)
-		}
-
-		// Expire session cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_token",
-			Value:    "",
-			Path:     "/",
-			HttpOnly: true,
-			MaxAge:   -1, // expire immediately
-		})
+	// Logged-in users have nothing to do here; like the login page, this
+	// must not end their session, since cross-site links can trigger a GET
+	if session, _ := login.GetSessionFromRequest(r); session != nil && !session.IsGuest {
+		http.Redirect(w, r, "/homePage", http.StatusSeeOther)
+		return
 	}
 
 	// Serve registration form on GET request
@@ -54,68 +50,80 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	email := strings.ToLower(r.FormValue("email"))
 	password := r.FormValue("password")
 
-	// Validate required fields
-	if username == "" || email == "" || password == "" {
+	userMsg, err := RegisterUser(dbConn, username, email, password)
+	if err != nil {
+		errors.InternalServerError(w, r, err.Error())
+		return
+	}
+	if userMsg != "" {
 		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]string{"Error": "All fields are required"})
+		tmpl.Execute(w, map[string]string{"Error": userMsg})
 		return
 	}
 
+	// Redirect to login page after successful registration
+	http.Redirect(w, r, "/login?registered=1", http.StatusSeeOther)
+}
+
+// RegisterUser validates and stores a new account, and emails it a link to
+// verify its address.
+// A non-empty message is returned when the submission is invalid;
+// err is only set for server-side failures.
+func RegisterUser(dbConn *sql.DB, username, email, password string) (string, error) {
+	// Validate required fields
+	if username == "" || email == "" || password == "" {
+		return "All fields are required", nil
+	}
+
 	// Check if username already exists
 	usernameExists, err := db.UsernameExists(dbConn, username)
 	if err != nil {
-		errors.InternalServerError(w, r, "Database error: "+err.Error())
-		return
+		return "", fmt.Errorf("Database error: %w", err)
 	}
 	if usernameExists {
-		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]string{"Error": "Username already exists"})
-		return
+		return "Username already exists", nil
 	}
 
 	// Check if email already exists
 	emailExists, err := db.EmailExists(dbConn, email)
 	if err != nil {
-		errors.InternalServerError(w, r, "Database error: "+err.Error())
-		return
+		return "", fmt.Errorf("Database error: %w", err)
 	}
 	if emailExists {
-		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]string{"Error": "Email already registered"})
-		return
+		return "Email already registered", nil
 	}
 
 	// Validate password, email, username
-	if !isValidEmail(email) {
-		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]string{"Error": "Invalid email format"})
-		return
+	if !IsValidEmail(email) {
+		return "Invalid email format", nil
 	}
-	if err := validateUsername(username); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]string{"Error": err.Error()})
-		return
+	if err := ValidateUsername(username); err != nil {
+		return err.Error(), nil
 	}
-	if valid, err := isValidPassword(password); !valid {
-		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]string{"Error": err.Error()})
-		return
+	if valid, err := IsValidPassword(password); !valid {
+		return err.Error(), nil
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		errors.InternalServerError(w, r, "Error hashing password: "+err.Error())
-		return
+		return "", fmt.Errorf("Error hashing password: %w", err)
 	}
 
 	// Convert []byte to string
 	if err := db.InsertUser(dbConn, username, email, string(hashedPassword)); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		tmpl.Execute(w, map[string]string{"Error": "Database error: " + err.Error()})
-		return
+		return "", fmt.Errorf("Database error: %w", err)
 	}
 
-	// Redirect to login page after successful registration
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	// The account works without it; a lost email can be sent again from the profile
+	user, err := db.GetUserByIdentifier(dbConn, username)
+	if err != nil {
+		return "", fmt.Errorf("Database error: %w", err)
+	}
+	go func() {
+		if err := SendVerification(user); err != nil {
+			fmt.Println("Sending verification email failed:", err)
+		}
+	}()
+	return "", nil
 }
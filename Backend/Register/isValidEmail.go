@@ -6,6 +6,7 @@ import (
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
 
-func isValidEmail(email string) bool {
+// IsValidEmail checks the format of an email address
+func IsValidEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
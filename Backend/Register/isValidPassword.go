@@ -5,7 +5,8 @@ import (
 	"strings"
 )
 
-func isValidPassword(password string) (bool, error) {
+// IsValidPassword checks the password rules for new accounts and password changes
+func IsValidPassword(password string) (bool, error) {
 	if len(password) < 8 {
 		return false, errors.New("password must be at least 8 characters long")
 	} // Password too short
@@ -6,7 +6,8 @@ import (
 	"unicode"
 )
 
-func validateUsername(username string) error {
+// ValidateUsername checks the username rules for new accounts and renames
+func ValidateUsername(username string) error {
 	if len(username) < 3 || len(username) > 20 {
 		return errors.New("username must be between 3 and 20 characters")
 	}
@@ -0,0 +1,142 @@
+package register
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/mailer"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VerificationLifetime is how long an email verification link works
+const VerificationLifetime = 48 * time.Hour
+
+// SendVerification emails the user a link confirming their address. The
+// link is signed rather than stored, and stops working if the address changes.
+func SendVerification(user *db.User) error {
+	expires := time.Now().Add(VerificationLifetime).Unix()
+	sig, err := verificationSignature(user.ID, user.Email, expires)
+	if err != nil {
+		return err
+	}
+	token := fmt.Sprintf("%d.%d.%s", user.ID, expires, sig)
+
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your forum email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm that this is your email address by opening this link within two days:\n\n"+
+			"%s\n\n"+
+			"You can read the forum in the meantime, but posting and commenting unlock once the address is confirmed.\n"+
+			"If you didn't create an account, ignore this email.\n",
+			user.Username, mailer.Link("/verify-email?token="+url.QueryEscape(token))),
+	})
+}
+
+// verificationSignature signs a user's address until expires with the
+// database's verification key
+func verificationSignature(userID int, email string, expires int64) (string, error) {
+	key, err := db.GetAppSecret(db.DB, "email_verification")
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "verify-email:%d:%s:%d", userID, strings.ToLower(email), expires)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyEmailHandler confirms an address from the emailed link
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+	tmpl, err := template.ParseFiles("templates/verify.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+
+	user, err := checkVerificationToken(r.URL.Query().Get("token"))
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking link: "+err.Error())
+		return
+	}
+	if user == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		tmpl.Execute(w, map[string]interface{}{"Error": "This verification link is invalid or has expired. Log in and request a new one from your profile."})
+		return
+	}
+
+	if !user.EmailVerified {
+		if _, err := db.MarkEmailVerified(db.DB, user.ID, user.Email); err != nil {
+			errors.InternalServerError(w, r, "Error verifying email: "+err.Error())
+			return
+		}
+	}
+	tmpl.Execute(w, map[string]interface{}{"Email": user.Email})
+}
+
+// checkVerificationToken returns the user a token was issued to, or nil if
+// it is malformed, expired, forged or for an address they no longer have
+func checkVerificationToken(token string) (*db.User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil
+	}
+	userID, err1 := strconv.Atoi(parts[0])
+	expires, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil || time.Now().Unix() > expires {
+		return nil, nil
+	}
+
+	user, err := db.GetUserByID(db.DB, userID)
+	if err != nil {
+		return nil, nil
+	}
+	want, err := verificationSignature(user.ID, user.Email, expires)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(want), []byte(parts[2])) {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// ResendVerificationHandler emails the logged-in user a new verification link
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	user, err := db.GetUserByID(db.DB, *session.UserID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if !user.EmailVerified {
+		if err := SendVerification(user); err != nil {
+			errors.InternalServerError(w, r, "Error sending verification email: "+err.Error())
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/profile?id="+strconv.Itoa(user.ID)+"&verification=sent", http.StatusSeeOther)
+}
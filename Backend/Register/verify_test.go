@@ -0,0 +1,160 @@
+package register
+
+import (
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"forum/Backend/mailer"
+	"forum/Backend/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// newForum gives the test an empty database, runs it from the repository
+// root where the templates are, and drops the emails it sends
+func newForum(t *testing.T) {
+	t.Helper()
+	dbtest.Use(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	saved := mailer.Default
+	mailer.Default = discard{}
+	t.Cleanup(func() { mailer.Default = saved })
+}
+
+type discard struct{}
+
+func (discard) Send(mailer.Message) error { return nil }
+
+// signedToken builds a verification token the way SendVerification does
+func signedToken(t *testing.T, userID int, email string, expires time.Time) string {
+	t.Helper()
+	sig, err := verificationSignature(userID, email, expires.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%d.%d.%s", userID, expires.Unix(), sig)
+}
+
+func TestCheckVerificationToken(t *testing.T) {
+	newForum(t)
+	aliceID := dbtest.CreateUser(t, db.DB, "alice")
+	bobID := dbtest.CreateUser(t, db.DB, "bob")
+	later := time.Now().Add(time.Hour)
+	valid := signedToken(t, aliceID, "alice@example.com", later)
+	sig := valid[len(valid)-64:]
+
+	flipped := []byte(valid)
+	if flipped[len(flipped)-1] == '0' {
+		flipped[len(flipped)-1] = '1'
+	} else {
+		flipped[len(flipped)-1] = '0'
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int // user ID, 0 for none
+	}{
+		{"valid", valid, aliceID},
+		{"address in any case", signedToken(t, aliceID, "ALICE@example.com", later), aliceID},
+		{"tampered signature", string(flipped), 0},
+		{"someone else's ID", fmt.Sprintf("%d.%d.%s", bobID, later.Unix(), sig), 0},
+		{"extended expiry", fmt.Sprintf("%d.%d.%s", aliceID, later.Add(time.Hour).Unix(), sig), 0},
+		{"expired", signedToken(t, aliceID, "alice@example.com", time.Now().Add(-time.Minute)), 0},
+		{"other address", signedToken(t, aliceID, "mallory@example.com", later), 0},
+		{"unknown user", signedToken(t, 9999, "alice@example.com", later), 0},
+		{"empty", "", 0},
+		{"too few parts", fmt.Sprintf("%d.%s", aliceID, sig), 0},
+		{"not numbers", "alice.tomorrow." + sig, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := checkVerificationToken(tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := 0
+			if user != nil {
+				got = user.ID
+			}
+			if got != tt.want {
+				t.Errorf("user %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Changing the address voids links to the old one
+	if _, err := db.DB.Exec(`UPDATE users SET email = 'alice@new.example.com' WHERE id = ?`, aliceID); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := checkVerificationToken(valid); err != nil || user != nil {
+		t.Errorf("link to the old address = %v, %v; want nothing", user, err)
+	}
+}
+
+func TestVerifyEmailHandler(t *testing.T) {
+	newForum(t)
+	userID := dbtest.CreateUser(t, db.DB, "alice")
+
+	verify := func(token string) int {
+		rec := httptest.NewRecorder()
+		VerifyEmailHandler(rec, httptest.NewRequest(http.MethodGet, "/verify-email?token="+url.QueryEscape(token), nil))
+		return rec.Code
+	}
+	verified := func() bool {
+		user, err := db.GetUserByID(db.DB, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.EmailVerified
+	}
+
+	if got := verify(signedToken(t, userID, "alice@example.com", time.Now().Add(-time.Second))); got != http.StatusBadRequest {
+		t.Errorf("expired link: status %d, want 400", got)
+	}
+	if verified() {
+		t.Fatal("an expired link verified the address")
+	}
+	if got := verify(signedToken(t, userID, "alice@example.com", time.Now().Add(time.Hour))); got != http.StatusOK {
+		t.Errorf("valid link: status %d, want 200", got)
+	}
+	if !verified() {
+		t.Error("a valid link didn't verify the address")
+	}
+}
+
+func TestResendVerificationIsRateLimited(t *testing.T) {
+	newForum(t)
+	userID := dbtest.CreateUser(t, db.DB, "alice")
+	cookie := dbtest.SessionCookie(t, db.DB, userID)
+	resend := ratelimit.Limit("verify", ResendVerificationHandler)
+
+	// A new IP every time, so only the per-user limit applies
+	for i := 0; i <= ratelimit.Rules["verify"].Burst; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/verify-email/resend", nil)
+		r.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", i+1)
+		r.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		resend(rec, r)
+
+		want := http.StatusSeeOther
+		if i == ratelimit.Rules["verify"].Burst {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Errorf("request %d: status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package account
+
+import (
+	"crypto/rand"
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConfirmationCodeLifetime is how long an emailed confirmation code works
+const ConfirmationCodeLifetime = 15 * time.Minute
+
+// maxConfirmationAttempts is how many wrong guesses a confirmation code takes
+const maxConfirmationAttempts = 5
+
+// codeReturns is where SendCodeHandler sends the user back to, by the
+// change the code is for
+var codeReturns = map[string]string{
+	"delete": "/profile/data?done=code",
+	"email":  "/profile/settings?done=code",
+}
+
+// SendCodeHandler emails a confirmation code to an account without a
+// password, which it enters to delete the account or change its email
+func SendCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, _, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+	back, ok := codeReturns[r.FormValue("for")]
+	if !ok {
+		errors.BadRequest(w, r, "Unknown change to confirm")
+		return
+	}
+	if user.Password != "" {
+		errors.BadRequest(w, r, "Confirm changes with your password")
+		return
+	}
+
+	code, err := newConfirmationCode()
+	if err != nil {
+		errors.InternalServerError(w, r, "Error creating code: "+err.Error())
+		return
+	}
+	expires := time.Now().UTC().Add(ConfirmationCodeLifetime)
+	if err := db.SetConfirmationCode(db.DB, user.ID, login.HashToken(code), expires); err != nil {
+		errors.InternalServerError(w, r, "Error creating code: "+err.Error())
+		return
+	}
+
+	notify(user.Email, "Your forum confirmation code", fmt.Sprintf("Hi %s,\n\n"+
+		"Your confirmation code is %s. Enter it on the forum within 15 minutes; it works once.\n\n"+
+		"If you didn't ask for it, ignore this email; nothing changes without the code.\n",
+		user.Username, code))
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// newConfirmationCode returns 8 random digits
+func newConfirmationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%08d", n.Int64()), nil
+}
+
+// checkCode checks the code confirming a change to the account: the
+// two-factor code when that is on, otherwise an emailed confirmation code.
+// problem is what to tell the user if it is wrong.
+func checkCode(userID int, twoFactor bool, code string) (problem string, err error) {
+	if twoFactor {
+		valid, err := login.CheckSecondFactor(userID, code)
+		if err != nil || valid {
+			return "", err
+		}
+		return login.InvalidCodeMessage, nil
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "Enter the code we emailed you", nil
+	}
+	valid, err := db.UseConfirmationCode(db.DB, userID, login.HashToken(code), maxConfirmationAttempts)
+	if err != nil || valid {
+		return "", err
+	}
+	return "That code is wrong or has expired. Ask for a new one.", nil
+}
@@ -0,0 +1,64 @@
+package account
+
+import (
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"net/http"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DeleteAccountHandler deletes the logged-in user's account after checking
+// their password, and their two-factor code if they use it. Accounts without
+// a password confirm with the two-factor code or an emailed code instead.
+// Posts and comments stay, attributed to [deleted user].
+func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, _, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		renderData(w, r, user, map[string]interface{}{"DeleteError": msg})
+	}
+
+	if user.Password != "" && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(r.FormValue("password"))) != nil {
+		fail("Your password is wrong")
+		return
+	}
+	enabled, err := db.IsTOTPEnabled(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if enabled || user.Password == "" {
+		problem, err := checkCode(user.ID, enabled, r.FormValue("code"))
+		if err != nil {
+			errors.InternalServerError(w, r, "Error checking code: "+err.Error())
+			return
+		}
+		if problem != "" {
+			fail(problem)
+			return
+		}
+	}
+
+	if err := db.DeleteAccount(db.DB, user.ID); err != nil {
+		errors.InternalServerError(w, r, "Error deleting account: "+err.Error())
+		return
+	}
+
+	login.ClearSessionCookie(w)
+	notify(user.Email, "Your forum account was deleted", fmt.Sprintf("Hi %s,\n\n"+
+		"Your forum account was deleted, as you asked. Your posts and comments stay on the forum "+
+		"under the name %s, and nothing connects them to you any more.\n\n"+
+		"If this wasn't you, someone else got into your account. Contact the moderators.\n",
+		user.Username, db.DeletedUsername))
+	http.Redirect(w, r, "/login?deleted=1", http.StatusSeeOther)
+}
@@ -0,0 +1,185 @@
+package account
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"forum/Backend/mailer"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inRepoRoot runs the test from the repository root, where the templates are
+func inRepoRoot(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// mailbox collects the emails the forum sends during a test
+type mailbox chan mailer.Message
+
+func (m mailbox) Send(msg mailer.Message) error {
+	m <- msg
+	return nil
+}
+
+func catchMail(t *testing.T) mailbox {
+	t.Helper()
+	box := make(mailbox, 10)
+	saved := mailer.Default
+	mailer.Default = box
+	t.Cleanup(func() { mailer.Default = saved })
+	return box
+}
+
+// next waits for the next email with the subject, skipping others
+func (m mailbox) next(t *testing.T, subject string) mailer.Message {
+	t.Helper()
+	for {
+		select {
+		case msg := <-m:
+			if msg.Subject == subject {
+				return msg
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no email %q was sent", subject)
+		}
+	}
+}
+
+// post sends a form to a handler, as the user with the session cookie if
+// there is one
+func post(handler http.HandlerFunc, path string, cookie *http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+var codePattern = regexp.MustCompile(`\b\d{8}\b`)
+
+// emailedCode asks for a confirmation code and returns it from the email
+func emailedCode(t *testing.T, box mailbox, cookie *http.Cookie, change string) string {
+	t.Helper()
+	rec := post(SendCodeHandler, "/profile/confirm-code", cookie, url.Values{"for": {change}})
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != codeReturns[change] {
+		t.Fatalf("asking for a code: status %d, location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	code := codePattern.FindString(box.next(t, "Your forum confirmation code").Body)
+	if code == "" {
+		t.Fatal("the email has no code")
+	}
+	return code
+}
+
+// oauthUser creates an account through a login provider, which has no password
+func oauthUser(t *testing.T, username string) int {
+	t.Helper()
+	id, err := db.CreateUserWithIdentity(db.DB, username, username+"@example.com", true, "mock", username+"-subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestDeleteAccountWithoutPassword(t *testing.T) {
+	inRepoRoot(t)
+	dbtest.Use(t)
+	box := catchMail(t)
+	userID := oauthUser(t, "alice")
+	cookie := dbtest.SessionCookie(t, db.DB, userID)
+
+	del := func(code string) int {
+		return post(DeleteAccountHandler, "/profile/delete", cookie, url.Values{"code": {code}}).Code
+	}
+	if got := del(""); got != http.StatusBadRequest {
+		t.Errorf("deleting without a code: status %d, want 400", got)
+	}
+	code := emailedCode(t, box, cookie, "delete")
+	wrong := "00000000"
+	if code == wrong {
+		wrong = "11111111"
+	}
+	if got := del(wrong); got != http.StatusBadRequest {
+		t.Errorf("deleting with a wrong code: status %d, want 400", got)
+	}
+	if _, err := db.GetUserByID(db.DB, userID); err != nil {
+		t.Fatalf("account is gone before confirming: %v", err)
+	}
+
+	if got := del(code); got != http.StatusSeeOther {
+		t.Fatalf("deleting with the emailed code: status %d, want 303", got)
+	}
+	if _, err := db.GetUserByID(db.DB, userID); err == nil {
+		t.Error("account still exists")
+	}
+	box.next(t, "Your forum account was deleted")
+}
+
+func TestConfirmationCodes(t *testing.T) {
+	inRepoRoot(t)
+	dbtest.Use(t)
+	box := catchMail(t)
+	userID := oauthUser(t, "alice")
+	cookie := dbtest.SessionCookie(t, db.DB, userID)
+
+	t.Run("works once", func(t *testing.T) {
+		code := emailedCode(t, box, cookie, "delete")
+		for i, want := range []string{"", "That code is wrong or has expired. Ask for a new one."} {
+			problem, err := checkCode(userID, false, code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if problem != want {
+				t.Errorf("use %d: problem %q, want %q", i+1, problem, want)
+			}
+		}
+	})
+
+	t.Run("stops after too many guesses", func(t *testing.T) {
+		code := emailedCode(t, box, cookie, "delete")
+		for i := 0; i < maxConfirmationAttempts; i++ {
+			if problem, err := checkCode(userID, false, "not-it"); err != nil || problem == "" {
+				t.Fatalf("guess %d: problem %q, err %v", i+1, problem, err)
+			}
+		}
+		if problem, _ := checkCode(userID, false, code); problem == "" {
+			t.Error("the code still works after too many wrong guesses")
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		code := emailedCode(t, box, cookie, "delete")
+		if _, err := db.DB.Exec(`UPDATE confirmation_codes SET expires_at = ? WHERE user_id = ?`,
+			time.Now().UTC().Add(-time.Minute), userID); err != nil {
+			t.Fatal(err)
+		}
+		if problem, _ := checkCode(userID, false, code); problem == "" {
+			t.Error("an expired code still works")
+		}
+	})
+
+	t.Run("not for accounts with a password", func(t *testing.T) {
+		bobID := dbtest.CreateUser(t, db.DB, "bob")
+		rec := post(SendCodeHandler, "/profile/confirm-code", dbtest.SessionCookie(t, db.DB, bobID), url.Values{"for": {"delete"}})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status %d, want 400", rec.Code)
+		}
+	})
+}
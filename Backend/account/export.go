@@ -0,0 +1,321 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/mailer"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ExportRetention is how long a data export can be downloaded once it is built
+const ExportRetention = 7 * 24 * time.Hour
+
+// exportInterval is how often the export job looks for work when nobody wakes it
+const exportInterval = time.Minute
+
+// exportWake starts the export job right away when someone asks for their data
+var exportWake = make(chan struct{}, 1)
+
+// dataNotices are shown on the data page after a request, by ?done=
+var dataNotices = map[string]string{
+	"requested": "We're putting your data together. We'll email you when the download is ready.",
+	"pending":   "Your data is already being put together. We'll email you when the download is ready.",
+	"code":      "We've emailed you a confirmation code. It works for 15 minutes.",
+}
+
+// DataHandler shows the user's data exports and the account deletion form
+func DataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	user, _, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+	renderData(w, r, user, map[string]interface{}{
+		"Notice": dataNotices[r.URL.Query().Get("done")],
+	})
+}
+
+// RequestExportHandler queues a "download my data" export for the export job
+func RequestExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, _, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := db.CreateDataExport(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error requesting export: "+err.Error())
+		return
+	}
+	if !created {
+		http.Redirect(w, r, "/profile/data?done=pending", http.StatusSeeOther)
+		return
+	}
+	select {
+	case exportWake <- struct{}{}:
+	default:
+	}
+	http.Redirect(w, r, "/profile/data?done=requested", http.StatusSeeOther)
+}
+
+// DownloadExportHandler sends the ZIP archive of a finished export to its owner
+func DownloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	user, _, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id < 1 {
+		errors.BadRequest(w, r, "Invalid export ID")
+		return
+	}
+	archive, finished, err := db.GetDataExportArchive(db.DB, id, user.ID)
+	if err == sql.ErrNoRows {
+		errors.NotFound(w, r, "Export not found or expired")
+		return
+	}
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="galaxy-forum-data-`+finished.Format("2006-01-02")+`.zip"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(archive)
+}
+
+// StartExportJob builds requested data exports one at a time and deletes
+// expired ones. Requests are kept in the database, so exports interrupted
+// by a restart are built afterwards. It blocks, so run it in its own goroutine.
+func StartExportJob() {
+	for {
+		exports, err := db.GetPendingDataExports(db.DB)
+		if err != nil {
+			fmt.Println("Data export job failed:", err)
+		}
+		for _, e := range exports {
+			runExport(e)
+		}
+		if _, err := db.DeleteExpiredDataExports(db.DB, time.Now().UTC()); err != nil {
+			fmt.Println("Data export cleanup failed:", err)
+		}
+
+		select {
+		case <-exportWake:
+		case <-time.After(exportInterval):
+		}
+	}
+}
+
+// runExport builds one export and tells its owner the outcome
+func runExport(e db.DataExport) {
+	expires := time.Now().UTC().Add(ExportRetention)
+
+	// A failed export is marked as such, so it isn't pending forever and
+	// the user can ask again
+	var archive []byte
+	user, err := db.GetUserByID(db.DB, e.UserID)
+	if err == nil {
+		archive, err = buildExport(user)
+	}
+	if err != nil {
+		fmt.Printf("Data export %d failed: %v\n", e.ID, err)
+		if err := db.FailDataExport(db.DB, e.ID, expires); err != nil {
+			fmt.Printf("Data export %d: %v\n", e.ID, err)
+		}
+		return
+	}
+	if err := db.FinishDataExport(db.DB, e.ID, archive, expires); err != nil {
+		fmt.Printf("Data export %d: %v\n", e.ID, err)
+		return
+	}
+
+	notify(user.Email, "Your forum data is ready to download", fmt.Sprintf("Hi %s,\n\n"+
+		"The copy of your forum data you asked for is ready. Download it, while logged in, from:\n\n%s\n\n"+
+		"The download is available until %s.\n",
+		user.Username, mailer.Link("/profile/data"), expires.Format("Jan 02, 2006")))
+}
+
+// exportAccount is account.json in a data export
+type exportAccount struct {
+	ID                int           `json:"id"`
+	Username          string        `json:"username"`
+	PreviousUsernames []string      `json:"previous_usernames"`
+	Email             string        `json:"email"`
+	EmailVerified     bool          `json:"email_verified"`
+	Role              string        `json:"role"`
+	TwoFactor         bool          `json:"two_factor_enabled"`
+	Profile           *db.Profile   `json:"profile"`
+	LinkedAccounts    []exportLink  `json:"linked_accounts"`
+	APITokens         []exportToken `json:"api_tokens"`
+	ExportedAt        time.Time     `json:"exported_at"`
+}
+
+// exportLink is a linked login provider account in account.json
+type exportLink struct {
+	Provider string    `json:"provider"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// exportToken is a personal API token in account.json, without its secret
+type exportToken struct {
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// buildExport puts the user's account details, posts, comments, likes and
+// sessions in a ZIP archive, one JSON file each, plus their avatar if they
+// have one
+func buildExport(user *db.User) ([]byte, error) {
+	account := exportAccount{
+		ID:             user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerified,
+		LinkedAccounts: []exportLink{},
+		APITokens:      []exportToken{},
+		ExportedAt:     time.Now().UTC(),
+	}
+	var err error
+	if account.PreviousUsernames, err = db.GetOldUsernames(db.DB, user.ID); err != nil {
+		return nil, err
+	}
+	if account.Role, err = db.GetUserRole(db.DB, user.ID); err != nil {
+		return nil, err
+	}
+	if account.TwoFactor, err = db.IsTOTPEnabled(db.DB, user.ID); err != nil {
+		return nil, err
+	}
+	if account.Profile, err = db.GetProfile(db.DB, user.ID); err != nil {
+		return nil, err
+	}
+	identities, err := db.GetUserIdentities(db.DB, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		account.LinkedAccounts = append(account.LinkedAccounts, exportLink{identity.Provider, identity.Email, identity.CreatedAt})
+	}
+	tokens, err := db.GetAPITokens(db.DB, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		account.APITokens = append(account.APITokens, exportToken{token.Name, token.Scopes, token.CreatedAt})
+	}
+
+	posts, err := db.GetExportPosts(db.DB, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := db.GetExportComments(db.DB, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	likes, err := db.GetExportLikes(db.DB, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := db.GetExportSessions(db.DB, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"account.json", account},
+		{"posts.json", posts},
+		{"comments.json", comments},
+		{"likes.json", likes},
+		{"sessions.json", sessions},
+	}
+	for _, f := range files {
+		out, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: account.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return nil, err
+		}
+	}
+
+	avatar, _, err := db.GetAvatar(db.DB, user.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		// PNGs are compressed already
+		out, err := zw.CreateHeader(&zip.FileHeader{Name: "avatar.png", Method: zip.Store, Modified: account.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := out.Write(avatar); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderData renders the data page for the user plus whatever the handler
+// adds to data
+func renderData(w http.ResponseWriter, r *http.Request, user *db.User, data map[string]interface{}) {
+	exports, err := db.GetDataExports(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching exports: "+err.Error())
+		return
+	}
+	twoFactor, err := db.IsTOTPEnabled(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	data["UserID"] = user.ID
+	data["Exports"] = exports
+	data["HasPassword"] = user.Password != ""
+	data["TwoFactor"] = twoFactor
+
+	tmpl, err := template.New("data.html").Funcs(security.Funcs(r)).Funcs(template.FuncMap{
+		"kilobytes": func(n int) string { return strconv.FormatFloat(float64(n)/1024, 'f', 1, 64) + " KB" },
+	}).ParseFiles("templates/data.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		errors.InternalServerError(w, r, "Template execution error: "+err.Error())
+	}
+}
@@ -0,0 +1,171 @@
+// Package account handles account settings, recovery, data exports and deletion.
+package account
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	db "forum/Backend/DB"
+	register "forum/Backend/Register"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/mailer"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ResetTokenLifetime is how long a password reset link works
+const ResetTokenLifetime = time.Hour
+
+// maxResetsPerHour caps the reset emails one account gets, so the form
+// cannot be used to flood someone's inbox
+const maxResetsPerHour = 3
+
+// ForgotPasswordHandler shows the forgot-password form and emails a reset
+// link. The answer is the same whether or not the account exists.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.New("forgot.html").Funcs(security.Funcs(r)).ParseFiles("templates/forgot.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	data := map[string]interface{}{"Lifetime": "1 hour"}
+
+	if r.Method == http.MethodGet {
+		tmpl.Execute(w, data)
+		return
+	}
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	identifier := strings.TrimSpace(r.FormValue("identifier"))
+	if identifier == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		data["Error"] = "Enter your username or email"
+		tmpl.Execute(w, data)
+		return
+	}
+
+	// Sent in the background, so the response time doesn't tell whether
+	// the account exists either
+	if user, err := db.GetUserByIdentifier(db.DB, identifier); err == nil {
+		go func() {
+			if err := sendResetLink(user); err != nil {
+				fmt.Println("Sending password reset link failed:", err)
+			}
+		}()
+	}
+
+	data["Sent"] = true
+	tmpl.Execute(w, data)
+}
+
+// sendResetLink issues a reset token for the user and emails it, unless
+// they already got maxResetsPerHour of them
+func sendResetLink(user *db.User) error {
+	recent, err := db.CountPasswordResetsSince(db.DB, user.ID, time.Now().UTC().Add(-time.Hour))
+	if err != nil || recent >= maxResetsPerHour {
+		return err
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+	if err := db.CreatePasswordReset(db.DB, user.ID, hash, time.Now().UTC().Add(ResetTokenLifetime)); err != nil {
+		return err
+	}
+
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your forum password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password of your forum account. If it was you, open this link within an hour to choose a new one:\n\n"+
+			"%s\n\n"+
+			"The link works once. If you didn't ask for it, ignore this email; your password stays the same.\n",
+			user.Username, mailer.Link("/reset-password?token="+url.QueryEscape(token))),
+	})
+}
+
+// ResetPasswordHandler shows the new-password form for a reset link and
+// applies it. Changing the password logs the account out everywhere.
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.New("reset.html").Funcs(security.Funcs(r)).ParseFiles("templates/reset.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	token := r.FormValue("token")
+	reset, err := db.GetPasswordReset(db.DB, login.HashToken(token))
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if reset == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		tmpl.Execute(w, map[string]interface{}{"Error": "This reset link is invalid, has expired or was already used."})
+		return
+	}
+	data := map[string]interface{}{"Token": token, "Username": reset.Username}
+
+	if r.Method == http.MethodGet {
+		tmpl.Execute(w, data)
+		return
+	}
+
+	password := r.FormValue("password")
+	if password != r.FormValue("confirm") {
+		w.WriteHeader(http.StatusBadRequest)
+		data["Error"] = "The passwords don't match"
+		tmpl.Execute(w, data)
+		return
+	}
+	if valid, err := register.IsValidPassword(password); !valid {
+		w.WriteHeader(http.StatusBadRequest)
+		data["Error"] = err.Error()
+		tmpl.Execute(w, data)
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error hashing password: "+err.Error())
+		return
+	}
+	ok, err := db.ResetPassword(db.DB, reset.ID, reset.UserID, string(hashed))
+	if err != nil {
+		errors.InternalServerError(w, r, "Error resetting password: "+err.Error())
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		tmpl.Execute(w, map[string]interface{}{"Error": "This reset link was already used."})
+		return
+	}
+
+	login.ClearSessionCookie(w)
+	http.Redirect(w, r, "/login?reset=1", http.StatusSeeOther)
+}
+
+// newToken returns a random token for a link and the hash to store for it
+func newToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, login.HashToken(token), nil
+}
@@ -0,0 +1,126 @@
+package account
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var resetLink = regexp.MustCompile(`/reset-password\?token=([0-9a-f]{64})`)
+
+// requestReset fills in the forgot-password form and returns the token
+// from the emailed link
+func requestReset(t *testing.T, box mailbox, identifier string) string {
+	t.Helper()
+	rec := post(ForgotPasswordHandler, "/forgot-password", nil, url.Values{"identifier": {identifier}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("forgot password: status %d", rec.Code)
+	}
+	m := resetLink.FindStringSubmatch(box.next(t, "Reset your forum password").Body)
+	if m == nil {
+		t.Fatal("the email has no reset link")
+	}
+	return m[1]
+}
+
+func resetPassword(token, password string) int {
+	form := url.Values{"token": {token}, "password": {password}, "confirm": {password}}
+	return post(ResetPasswordHandler, "/reset-password", nil, form).Code
+}
+
+func TestResetPassword(t *testing.T) {
+	inRepoRoot(t)
+	dbtest.Use(t)
+	box := catchMail(t)
+	userID := dbtest.CreateUser(t, db.DB, "alice")
+
+	t.Run("signs out everywhere", func(t *testing.T) {
+		dbtest.SessionCookie(t, db.DB, userID)
+		dbtest.SessionCookie(t, db.DB, userID)
+		bobID := dbtest.CreateUser(t, db.DB, "bob")
+		dbtest.SessionCookie(t, db.DB, bobID)
+
+		token := requestReset(t, box, "alice")
+		if got := resetPassword(token, "newsecret1"); got != http.StatusSeeOther {
+			t.Fatalf("status %d, want 303", got)
+		}
+		user, err := db.GetUserByID(db.DB, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("newsecret1")) != nil {
+			t.Error("the new password doesn't work")
+		}
+		for id, want := range map[int]int{userID: 0, bobID: 1} {
+			var n int
+			if err := db.DB.QueryRow(`SELECT COUNT(*) FROM sessions WHERE user_id = ?`, id).Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			if n != want {
+				t.Errorf("user %d has %d sessions, want %d", id, n, want)
+			}
+		}
+	})
+
+	t.Run("links work once", func(t *testing.T) {
+		token := requestReset(t, box, "alice")
+		other := requestReset(t, box, "alice@example.com")
+		if got := resetPassword(token, "newsecret2"); got != http.StatusSeeOther {
+			t.Fatalf("first use: status %d, want 303", got)
+		}
+		if got := resetPassword(token, "newsecret3"); got != http.StatusBadRequest {
+			t.Errorf("second use: status %d, want 400", got)
+		}
+		if got := resetPassword(other, "newsecret3"); got != http.StatusBadRequest {
+			t.Errorf("another link from before the reset: status %d, want 400", got)
+		}
+	})
+
+	t.Run("links expire", func(t *testing.T) {
+		// Each subtest asks for links, and only so many are sent per hour
+		if _, err := db.DB.Exec(`DELETE FROM password_resets`); err != nil {
+			t.Fatal(err)
+		}
+		token := requestReset(t, box, "alice")
+		if _, err := db.DB.Exec(`UPDATE password_resets SET expires_at = ?`, time.Now().UTC().Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		ResetPasswordHandler(rec, httptest.NewRequest(http.MethodGet, "/reset-password?token="+token, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("opening an expired link: status %d, want 400", rec.Code)
+		}
+		if got := resetPassword(token, "newsecret4"); got != http.StatusBadRequest {
+			t.Errorf("using an expired link: status %d, want 400", got)
+		}
+	})
+
+	t.Run("a few links per hour", func(t *testing.T) {
+		if _, err := db.DB.Exec(`DELETE FROM password_resets`); err != nil {
+			t.Fatal(err)
+		}
+		user, err := db.GetUserByID(db.DB, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < maxResetsPerHour+2; i++ {
+			if err := sendResetLink(user); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var n int
+		if err := db.DB.QueryRow(`SELECT COUNT(*) FROM password_resets WHERE user_id = ?`, userID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != maxResetsPerHour {
+			t.Errorf("%d links issued, want %d", n, maxResetsPerHour)
+		}
+	})
+}
@@ -0,0 +1,288 @@
+package account
+
+import (
+	"fmt"
+	db "forum/Backend/DB"
+	register "forum/Backend/Register"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/mailer"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UsernameChangeInterval is how long a user has to wait between renames, so
+// names can't be juggled to confuse people
+const UsernameChangeInterval = 30 * 24 * time.Hour
+
+// settingsNotices are shown after a successful change, by ?done=
+var settingsNotices = map[string]string{
+	"password": "Your password was changed. Other devices were signed out.",
+	"email":    "Your email address was changed. We've sent a link to confirm the new one.",
+	"username": "Your username was changed. Links to your old name still lead to your profile.",
+	"code":     "We've emailed you a confirmation code. It works for 15 minutes.",
+}
+
+// SettingsHandler shows the account settings of the logged-in user
+func SettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	user, _, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+	renderSettings(w, r, user, map[string]interface{}{
+		"Notice": settingsNotices[r.URL.Query().Get("done")],
+	})
+}
+
+// ChangePasswordHandler changes the password after checking the current one,
+// and signs out every other device
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, session, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		renderSettings(w, r, user, map[string]interface{}{"PasswordError": msg})
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(r.FormValue("current_password"))) != nil {
+		fail("Your current password is wrong")
+		return
+	}
+	password := r.FormValue("password")
+	if password != r.FormValue("confirm") {
+		fail("The new passwords don't match")
+		return
+	}
+	if valid, err := register.IsValidPassword(password); !valid {
+		fail(err.Error())
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error hashing password: "+err.Error())
+		return
+	}
+	if err := db.UpdatePassword(db.DB, user.ID, string(hashed), session.Token); err != nil {
+		errors.InternalServerError(w, r, "Error changing password: "+err.Error())
+		return
+	}
+
+	notify(user.Email, "Your forum password was changed", fmt.Sprintf("Hi %s,\n\n"+
+		"The password of your forum account was just changed, and your other devices were signed out.\n\n"+
+		"If this wasn't you, reset your password right away:\n\n%s\n",
+		user.Username, mailer.Link("/forgot-password")))
+	http.Redirect(w, r, "/profile/settings?done=password", http.StatusSeeOther)
+}
+
+// ChangeEmailHandler changes the email address after checking the password,
+// or for accounts without one the two-factor code or an emailed code. The
+// new address has to be verified again; the old one is told about it.
+func ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, _, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		renderSettings(w, r, user, map[string]interface{}{"EmailError": msg})
+	}
+
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	if !register.IsValidEmail(email) {
+		fail("Invalid email format")
+		return
+	}
+	if email == strings.ToLower(user.Email) {
+		fail("That is already your email address")
+		return
+	}
+	if user.Password != "" {
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(r.FormValue("password"))) != nil {
+			fail("Your password is wrong")
+			return
+		}
+	} else {
+		enabled, err := db.IsTOTPEnabled(db.DB, user.ID)
+		if err != nil {
+			errors.InternalServerError(w, r, "Database error: "+err.Error())
+			return
+		}
+		problem, err := checkCode(user.ID, enabled, r.FormValue("code"))
+		if err != nil {
+			errors.InternalServerError(w, r, "Error checking code: "+err.Error())
+			return
+		}
+		if problem != "" {
+			fail(problem)
+			return
+		}
+	}
+	exists, err := db.EmailExists(db.DB, email)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if exists {
+		fail("Email already registered")
+		return
+	}
+
+	if err := db.UpdateEmail(db.DB, user.ID, email); err != nil {
+		errors.InternalServerError(w, r, "Error changing email: "+err.Error())
+		return
+	}
+	updated, err := db.GetUserByID(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	go func() {
+		if err := register.SendVerification(updated); err != nil {
+			fmt.Println("Sending verification email failed:", err)
+		}
+	}()
+	notify(user.Email, "Your forum email address was changed", fmt.Sprintf("Hi %s,\n\n"+
+		"The email address of your forum account was just changed to %s, so emails from the forum go there from now on.\n\n"+
+		"If this wasn't you, someone else got into your account. Contact the moderators.\n",
+		user.Username, email))
+	http.Redirect(w, r, "/profile/settings?done=email", http.StatusSeeOther)
+}
+
+// ChangeUsernameHandler renames the logged-in user, at most once per
+// UsernameChangeInterval. The old name is kept so links to it redirect.
+func ChangeUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, _, ok := settingsUser(w, r)
+	if !ok {
+		return
+	}
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		renderSettings(w, r, user, map[string]interface{}{"UsernameError": msg})
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	if username == user.Username {
+		fail("That is already your username")
+		return
+	}
+	if err := register.ValidateUsername(username); err != nil {
+		fail(err.Error())
+		return
+	}
+	// Changing only the capitalisation of your own name is fine
+	if !strings.EqualFold(username, user.Username) {
+		exists, err := db.UsernameExists(db.DB, username)
+		if err != nil {
+			errors.InternalServerError(w, r, "Database error: "+err.Error())
+			return
+		}
+		if exists {
+			fail("Username already exists")
+			return
+		}
+	}
+	next, err := nextUsernameChange(user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if time.Now().Before(next) {
+		fail("You can change your username again on " + next.Format("Jan 02, 2006") + ".")
+		return
+	}
+
+	if err := db.ChangeUsername(db.DB, user.ID, user.Username, username); err != nil {
+		errors.InternalServerError(w, r, "Error changing username: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/profile/settings?done=username", http.StatusSeeOther)
+}
+
+// nextUsernameChange is the earliest time the user may rename again
+func nextUsernameChange(userID int) (time.Time, error) {
+	last, err := db.GetLastUsernameChange(db.DB, userID)
+	if err != nil || last.IsZero() {
+		return time.Time{}, err
+	}
+	return last.Add(UsernameChangeInterval), nil
+}
+
+// settingsUser loads the logged-in user, redirecting guests to the login page
+func settingsUser(w http.ResponseWriter, r *http.Request) (*db.User, *login.Session, bool) {
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil, nil, false
+	}
+	user, err := db.GetUserByID(db.DB, *session.UserID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching user: "+err.Error())
+		return nil, nil, false
+	}
+	return user, session, true
+}
+
+// renderSettings renders the settings page for the user plus whatever the
+// handler adds to data
+func renderSettings(w http.ResponseWriter, r *http.Request, user *db.User, data map[string]interface{}) {
+	next, err := nextUsernameChange(user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	data["UserID"] = user.ID
+	data["Username"] = user.Username
+	data["Email"] = user.Email
+	data["EmailVerified"] = user.EmailVerified
+	// Accounts created through a login provider get a password from a reset link
+	data["HasPassword"] = user.Password != ""
+	if data["TwoFactor"], err = db.IsTOTPEnabled(db.DB, user.ID); err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if time.Now().Before(next) {
+		data["NextRename"] = next
+	}
+
+	tmpl, err := template.New("settings.html").Funcs(security.Funcs(r)).ParseFiles("templates/settings.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		errors.InternalServerError(w, r, "Template execution error: "+err.Error())
+	}
+}
+
+// notify emails the user about a change to their account in the background
+func notify(to, subject, body string) {
+	go func() {
+		if err := mailer.Send(mailer.Message{To: to, Subject: subject, Body: body}); err != nil {
+			fmt.Println("Sending account notice failed:", err)
+		}
+	}()
+}
@@ -0,0 +1,147 @@
+package account
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChangeUsername(t *testing.T) {
+	inRepoRoot(t)
+	dbtest.Use(t)
+	aliceID := dbtest.CreateUser(t, db.DB, "alice")
+	bobID := dbtest.CreateUser(t, db.DB, "bob")
+	alice := dbtest.SessionCookie(t, db.DB, aliceID)
+	bob := dbtest.SessionCookie(t, db.DB, bobID)
+
+	rename := func(cookie *http.Cookie, username string) int {
+		return post(ChangeUsernameHandler, "/profile/settings/username", cookie, url.Values{"username": {username}}).Code
+	}
+	// cooledDown moves alice's last rename back past the waiting time
+	cooledDown := func() {
+		t.Helper()
+		if _, err := db.DB.Exec(`UPDATE username_history SET changed_at = ? WHERE user_id = ?`,
+			time.Now().UTC().Add(-UsernameChangeInterval-time.Hour), aliceID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	formerly := func(username string) int {
+		t.Helper()
+		id, err := db.GetUserIDByOldUsername(db.DB, username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	steps := []struct {
+		name     string
+		setup    func()
+		cookie   *http.Cookie
+		username string
+		want     int
+	}{
+		{"taken name", nil, alice, "BOB", http.StatusBadRequest},
+		{"invalid name", nil, alice, "a!", http.StatusBadRequest},
+		{"first rename", nil, alice, "alice2", http.StatusSeeOther},
+		{"too soon", nil, alice, "alice3", http.StatusBadRequest},
+		{"after the wait", cooledDown, alice, "alice3", http.StatusSeeOther},
+		{"only the case", cooledDown, alice, "Alice3", http.StatusSeeOther},
+	}
+	for _, s := range steps {
+		if s.setup != nil {
+			s.setup()
+		}
+		if got := rename(s.cookie, s.username); got != s.want {
+			t.Errorf("%s: status %d, want %d", s.name, got, s.want)
+		}
+	}
+
+	old, err := db.GetOldUsernames(db.DB, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice", "alice2", "alice3"}; !reflect.DeepEqual(old, want) {
+		t.Errorf("old names %q, want %q", old, want)
+	}
+	if got := formerly("ALICE2"); got != aliceID {
+		t.Errorf("ALICE2 leads to user %d, want alice %d", got, aliceID)
+	}
+
+	// An old name is free for others, and stops leading to alice once taken
+	if got := rename(bob, "alice"); got != http.StatusSeeOther {
+		t.Fatalf("bob taking alice's old name: status %d, want 303", got)
+	}
+	if got := formerly("alice"); got != 0 {
+		t.Errorf("alice leads to user %d after bob took it, want nobody", got)
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	inRepoRoot(t)
+	dbtest.Use(t)
+	box := catchMail(t)
+	userID := dbtest.CreateUser(t, db.DB, "alice")
+	dbtest.CreateUser(t, db.DB, "bob")
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec(`UPDATE users SET password = ?, email_verified_at = ? WHERE id = ?`,
+		string(hash), time.Now().UTC(), userID); err != nil {
+		t.Fatal(err)
+	}
+	cookie := dbtest.SessionCookie(t, db.DB, userID)
+
+	change := func(email, password string) int {
+		form := url.Values{"email": {email}, "password": {password}}
+		return post(ChangeEmailHandler, "/profile/settings/email", cookie, form).Code
+	}
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		want     int
+	}{
+		{"wrong password", "alice@new.example.com", "wrong", http.StatusBadRequest},
+		{"invalid address", "alice", "secret123", http.StatusBadRequest},
+		{"same address", "ALICE@example.com", "secret123", http.StatusBadRequest},
+		{"someone else's address", "bob@example.com", "secret123", http.StatusBadRequest},
+		{"new address", " Alice@New.example.com ", "secret123", http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		if got := change(tt.email, tt.password); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	user, err := db.GetUserByID(db.DB, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "alice@new.example.com" || user.EmailVerified {
+		t.Errorf("email %q, verified %v; want the new address, unverified", user.Email, user.EmailVerified)
+	}
+	// Both are sent in the background, in either order
+	sentTo := map[string]string{}
+	for len(sentTo) < 2 {
+		select {
+		case msg := <-box:
+			sentTo[msg.Subject] = msg.To
+		case <-time.After(5 * time.Second):
+			t.Fatalf("emails sent: %v, want a verification and a notice", sentTo)
+		}
+	}
+	if to := sentTo["Confirm your forum email address"]; to != "alice@new.example.com" {
+		t.Errorf("verification sent to %q, want the new address", to)
+	}
+	if to := sentTo["Your forum email address was changed"]; to != "alice@example.com" {
+		t.Errorf("notice sent to %q, want the old address", to)
+	}
+}
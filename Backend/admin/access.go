@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+)
+
+// requireStaff returns the session of a user allowed to perform action.
+// Guests are sent to the login page, everyone else gets a 403.
+func requireStaff(w http.ResponseWriter, r *http.Request, action permissions.Action) (*login.Session, bool) {
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil, false
+	}
+
+	allowed, err := permissions.Can(*session.UserID, action)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+		return nil, false
+	}
+	if !allowed {
+		errors.Forbidden(w, r, "This page is for staff only")
+		return nil, false
+	}
+	return session, true
+}
+
+// render executes an admin page together with the shared admin navigation.
+// page names the active tab; data gets the Page, IsAdmin, CanBan and CanAudit
+// keys added.
+func render(w http.ResponseWriter, r *http.Request, userID int, page string, data map[string]interface{}) {
+	tmpl, err := template.New(page+".html").Funcs(security.Funcs(r)).ParseFiles("templates/admin/"+page+".html", "templates/admin/nav.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+
+	isAdmin, err := permissions.Can(userID, permissions.ManageRoles)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+		return
+	}
+	canBan, err := permissions.Can(userID, permissions.BanUsers)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+		return
+	}
+	canAudit, err := permissions.Can(userID, permissions.ViewAuditLog)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+		return
+	}
+	data["Page"] = page
+	data["IsAdmin"] = isAdmin
+	data["CanBan"] = canBan
+	data["CanAudit"] = canAudit
+
+	if err := tmpl.Execute(w, data); err != nil {
+		errors.InternalServerError(w, r, "Template execution error: "+err.Error())
+	}
+}
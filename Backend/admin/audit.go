@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"encoding/csv"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/permissions"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditPageSize is the number of log entries per page of the audit view
+const auditPageSize = 50
+
+// auditDateLayout is the format of the from/to filters (HTML date inputs)
+const auditDateLayout = "2006-01-02"
+
+// AuditHandler handles GET /admin/audit, the filterable moderation log
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	session, ok := requireStaff(w, r, permissions.ViewAuditLog)
+	if !ok {
+		return
+	}
+
+	filter, errMsg := parseAuditFilter(r.URL.Query())
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	filter.Limit = auditPageSize + 1
+	filter.Offset = (page - 1) * auditPageSize
+
+	var entries []db.ModerationAction
+	if errMsg == "" {
+		entries, err = db.GetModerationActions(db.DB, filter)
+		if err != nil {
+			errors.InternalServerError(w, r, "Error fetching moderation log: "+err.Error())
+			return
+		}
+	}
+	hasMore := len(entries) > auditPageSize
+	if hasMore {
+		entries = entries[:auditPageSize]
+	}
+
+	actions, err := db.GetModerationActionNames(db.DB)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching moderation log: "+err.Error())
+		return
+	}
+
+	// Pagination and export links keep the current filters
+	query := r.URL.Query()
+	query.Del("page")
+	pageLink := func(n int) string {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(n))
+		return "/admin/audit?" + q.Encode()
+	}
+	data := map[string]interface{}{
+		"Entries":   entries,
+		"Actions":   actions,
+		"Filter":    query,
+		"Error":     errMsg,
+		"ExportURL": "/admin/audit/export?" + query.Encode(),
+		"PageNum":   page,
+	}
+	if page > 1 {
+		data["PrevURL"] = pageLink(page - 1)
+	}
+	if hasMore {
+		data["NextURL"] = pageLink(page + 1)
+	}
+
+	if errMsg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	render(w, r, *session.UserID, "audit", data)
+}
+
+// AuditExportHandler handles GET /admin/audit/export, the moderation log as
+// CSV with the same filters as the audit view
+func AuditExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	if _, ok := requireStaff(w, r, permissions.ViewAuditLog); !ok {
+		return
+	}
+
+	filter, errMsg := parseAuditFilter(r.URL.Query())
+	if errMsg != "" {
+		errors.BadRequest(w, r, errMsg)
+		return
+	}
+	entries, err := db.GetModerationActions(db.DB, filter)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching moderation log: "+err.Error())
+		return
+	}
+
+	filename := "moderation-log-" + time.Now().UTC().Format("20060102") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "actor_id", "actor", "action", "target_type", "target_id", "reason", "before", "after"})
+	for _, e := range entries {
+		out.Write([]string{
+			strconv.Itoa(e.ID),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.Itoa(e.ActorID),
+			csvCell(actorName(e)),
+			e.Action,
+			e.TargetType,
+			strconv.Itoa(e.TargetID),
+			csvCell(e.Reason),
+			csvCell(e.Before),
+			csvCell(e.After),
+		})
+	}
+	out.Flush()
+}
+
+// parseAuditFilter reads the actor, action, type, target, from and to query
+// parameters, returning an error message for malformed ones
+func parseAuditFilter(q url.Values) (db.AuditFilter, string) {
+	f := db.AuditFilter{
+		Actor:      strings.TrimSpace(q.Get("actor")),
+		Action:     q.Get("action"),
+		TargetType: q.Get("type"),
+	}
+	if s := strings.TrimSpace(q.Get("target")); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 1 {
+			return f, "Target ID must be a positive number"
+		}
+		f.TargetID = id
+	}
+	if s := q.Get("from"); s != "" {
+		from, err := time.Parse(auditDateLayout, s)
+		if err != nil {
+			return f, "Invalid from date"
+		}
+		f.From = from
+	}
+	if s := q.Get("to"); s != "" {
+		to, err := time.Parse(auditDateLayout, s)
+		if err != nil {
+			return f, "Invalid to date"
+		}
+		f.To = to.AddDate(0, 0, 1) // the to date is inclusive
+	}
+	return f, ""
+}
+
+// actorName names the actor of a log entry; actor 0 is the command line
+func actorName(e db.ModerationAction) string {
+	if e.ActorID == 0 {
+		return "(command line)"
+	}
+	return e.ActorName
+}
+
+// csvCell stops spreadsheet programs from reading user text as a formula
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
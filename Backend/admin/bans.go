@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/permissions"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBanReasonLength limits the reason shown to a banned user
+const MaxBanReasonLength = 500
+
+// MaxSuspensionDays is the longest suspension; use a ban for anything longer
+const MaxSuspensionDays = 365
+
+// BansHandler handles GET and POST /admin/bans, where moderators ban, suspend
+// and shadow-ban accounts and lift those restrictions
+func BansHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := requireStaff(w, r, permissions.BanUsers)
+	if !ok {
+		return
+	}
+
+	var message, errMsg string
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		message, errMsg = applyBanChange(r, *session.UserID)
+		if errMsg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	default:
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	bans, err := db.GetActiveBans(db.DB)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching bans: "+err.Error())
+		return
+	}
+
+	render(w, r, *session.UserID, "bans", map[string]interface{}{
+		"Bans":            bans,
+		"MaxDays":         MaxSuspensionDays,
+		"MaxReasonLength": MaxBanReasonLength,
+		"Message":         message,
+		"Error":           errMsg,
+	})
+}
+
+// applyBanChange performs the form's action and returns a success or error message
+func applyBanChange(r *http.Request, moderatorID int) (string, string) {
+	if r.FormValue("action") == "lift" {
+		banID, err := strconv.Atoi(r.FormValue("ban_id"))
+		if err != nil {
+			return "", "Invalid ban"
+		}
+		ban, err := db.GetBan(db.DB, banID)
+		if err != nil {
+			return "", "Database error: " + err.Error()
+		}
+		if ban == nil {
+			return "", "That restriction is no longer active"
+		}
+		// Lifting a restriction takes the same rank as placing it
+		outranks, err := permissions.Outranks(moderatorID, ban.UserID)
+		if err != nil {
+			return "", "Database error: " + err.Error()
+		}
+		if !outranks {
+			return "", "You cannot restrict " + ban.Username
+		}
+		entry := db.AuditEntry{ActorID: moderatorID, Action: "user.lift_" + ban.Kind, TargetType: "user", TargetID: ban.UserID}
+		err = db.Audited(db.DB, entry, func(q db.Querier) error {
+			_, err := db.LiftBan(q, banID, moderatorID)
+			return err
+		})
+		if err != nil {
+			return "", "Database error: " + err.Error()
+		}
+		return "Restriction lifted", ""
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	userID, err := db.GetUserIDByUsername(db.DB, username)
+	if err == sql.ErrNoRows {
+		return "", "No user named " + username
+	}
+	if err != nil {
+		return "", "Database error: " + err.Error()
+	}
+	outranks, err := permissions.Outranks(moderatorID, userID)
+	if err != nil {
+		return "", "Database error: " + err.Error()
+	}
+	if !outranks {
+		return "", "You cannot restrict " + username
+	}
+
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		return "", "A reason is required; banned users are shown it"
+	}
+	if len([]rune(reason)) > MaxBanReasonLength {
+		return "", "Reason must be at most " + strconv.Itoa(MaxBanReasonLength) + " characters"
+	}
+
+	kind := r.FormValue("kind")
+	var expiresAt *time.Time
+	switch kind {
+	case db.BanPermanent, db.BanShadow:
+	case db.BanSuspension:
+		days, err := strconv.Atoi(r.FormValue("days"))
+		if err != nil || days < 1 || days > MaxSuspensionDays {
+			return "", "Suspensions last 1 to " + strconv.Itoa(MaxSuspensionDays) + " days"
+		}
+		until := time.Now().UTC().AddDate(0, 0, days)
+		expiresAt = &until
+	default:
+		return "", "Unknown restriction"
+	}
+
+	entry := db.AuditEntry{ActorID: moderatorID, Action: "user." + kind, TargetType: "user", TargetID: userID, Reason: reason}
+	err = db.Audited(db.DB, entry, func(q db.Querier) error {
+		if err := db.CreateBan(q, userID, kind, reason, expiresAt, moderatorID); err != nil {
+			return err
+		}
+		// Shadow-banned users must not notice, so only real bans log them out
+		if kind == db.BanShadow {
+			return nil
+		}
+		return db.DeleteUserSessions(q, userID)
+	})
+	if err != nil {
+		return "", "Database error: " + err.Error()
+	}
+
+	switch kind {
+	case db.BanSuspension:
+		return username + " is suspended", ""
+	case db.BanShadow:
+		return username + " is shadow-banned", ""
+	}
+	return username + " is banned", ""
+}
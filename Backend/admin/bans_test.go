@@ -0,0 +1,78 @@
+package admin
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// createUser registers an account with a site-wide role
+func createUser(t *testing.T, username, role string) int {
+	t.Helper()
+	id := dbtest.CreateUser(t, db.DB, username)
+	if _, err := db.DB.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// banForm is the bans page's form, as sent by a moderator
+func banForm(form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/admin/bans", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestLiftBanNeedsRank(t *testing.T) {
+	dbtest.Use(t)
+	adminID := createUser(t, "admin", "admin")
+	modID := createUser(t, "mod", "moderator")
+	otherModID := createUser(t, "othermod", "moderator")
+	userID := createUser(t, "bob", "user")
+
+	tests := []struct {
+		name      string
+		target    int
+		lifter    int
+		wantError string
+	}{
+		{"moderator lifts a user's ban", userID, modID, ""},
+		{"moderator lifts a moderator's ban", otherModID, modID, "You cannot restrict othermod"},
+		{"admin lifts a moderator's ban", otherModID, adminID, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.CreateBan(db.DB, tt.target, db.BanPermanent, "spam", nil, adminID); err != nil {
+				t.Fatal(err)
+			}
+			bans, err := db.GetActiveBans(db.DB)
+			if err != nil || len(bans) != 1 {
+				t.Fatalf("active bans = %v, %v", bans, err)
+			}
+
+			_, errMsg := applyBanChange(banForm(url.Values{"action": {"lift"}, "ban_id": {strconv.Itoa(bans[0].ID)}}), tt.lifter)
+			if errMsg != tt.wantError {
+				t.Errorf("error = %q, want %q", errMsg, tt.wantError)
+			}
+			ban, err := db.GetActiveBan(db.DB, tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lifted := ban == nil; lifted != (tt.wantError == "") {
+				t.Errorf("lifted = %v, want %v", lifted, tt.wantError == "")
+			}
+
+			// Leave no ban behind for the next case
+			if ban != nil {
+				if _, err := db.LiftBan(db.DB, ban.ID, adminID); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/permissions"
+	"forum/Backend/screening"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxBlockedWordLength limits entries of the word filter
+const MaxBlockedWordLength = 50
+
+// FiltersHandler handles GET and POST /admin/filters, where admins manage
+// the blocked word list used to screen new posts and comments
+func FiltersHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := requireStaff(w, r, permissions.ManageFilters)
+	if !ok {
+		return
+	}
+
+	var message, errMsg string
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		message, errMsg = applyFilterChange(r, *session.UserID)
+		if errMsg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	default:
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	words, err := db.GetBlockedWords(db.DB)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching blocked words: "+err.Error())
+		return
+	}
+
+	render(w, r, *session.UserID, "filters", map[string]interface{}{
+		"Words":          words,
+		"MaxWordLength":  MaxBlockedWordLength,
+		"MaxLinks":       screening.MaxLinks,
+		"DuplicateHours": int(screening.DuplicateWindow.Hours()),
+		"Message":        message,
+		"Error":          errMsg,
+	})
+}
+
+// applyFilterChange performs the form's action and returns a success or error message
+func applyFilterChange(r *http.Request, adminID int) (string, string) {
+	switch r.FormValue("action") {
+	case "add":
+		word := strings.ToLower(strings.TrimSpace(r.FormValue("word")))
+		mode := r.FormValue("mode")
+		if word == "" || len([]rune(word)) > MaxBlockedWordLength {
+			return "", "Words must be 1 to " + strconv.Itoa(MaxBlockedWordLength) + " characters"
+		}
+		if mode != db.WordMask && mode != db.WordReject {
+			return "", "Unknown mode"
+		}
+
+		// Log against the word's ID, which only exists after the insert for new words
+		entry := db.AuditEntry{ActorID: adminID, Action: "filter.set", TargetType: "filter"}
+		_, err := db.AuditedUpsert(db.DB, entry, func(q db.Querier) (int, error) {
+			id, err := db.GetBlockedWordID(q, word)
+			if err == sql.ErrNoRows {
+				return 0, nil
+			}
+			return id, err
+		}, func(q db.Querier) (int, error) {
+			return db.SetBlockedWord(q, word, mode, adminID)
+		})
+		if err != nil {
+			return "", "Database error: " + err.Error()
+		}
+		return "\"" + word + "\" is now filtered (" + mode + ")", ""
+
+	case "remove":
+		id, err := strconv.Atoi(r.FormValue("word_id"))
+		if err != nil {
+			return "", "Invalid word"
+		}
+		entry := db.AuditEntry{ActorID: adminID, Action: "filter.remove", TargetType: "filter", TargetID: id}
+		if err := db.Audited(db.DB, entry, func(q db.Querier) error { return db.DeleteBlockedWord(q, id) }); err != nil {
+			return "", "Database error: " + err.Error()
+		}
+		return "Word removed from the filter", ""
+	}
+
+	return "", "Unknown action"
+}
@@ -0,0 +1,256 @@
+package admin
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"forum/Backend/posts"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reasonLabels maps report reason codes to their labels
+func reasonLabels() map[string]string {
+	labels := make(map[string]string, len(posts.ReportReasons))
+	for _, r := range posts.ReportReasons {
+		labels[r.Code] = r.Label
+	}
+	labels[db.HeldReason] = "Held by the content filter"
+	return labels
+}
+
+// ReportsHandler handles GET /admin/reports, the moderation queue. Category
+// moderators only see reports on posts in their categories.
+func ReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+
+	session, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	all, categories, err := permissions.Scope(*session.UserID, permissions.ReviewReports)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+		return
+	}
+	if all {
+		categories = nil
+	}
+
+	items, err := db.GetOpenReports(db.DB, categories)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching reports: "+err.Error())
+		return
+	}
+
+	render(w, r, *session.UserID, "reports", map[string]interface{}{
+		"Items":      items,
+		"Categories": categories,
+		"Labels":     reasonLabels(),
+		"Done":       r.URL.Query().Get("done"),
+	})
+}
+
+// ReportActionHandler handles POST /admin/reports/action: dismiss the reports
+// on an item, hide or delete it, or approve held content, which also closes
+// its reports
+func ReportActionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+
+	session, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+	moderatorID := *session.UserID
+
+	target, ok := loadTarget(w, r)
+	if !ok {
+		return
+	}
+
+	action := r.FormValue("action")
+	var required permissions.Action
+	var resolution string
+	switch action {
+	case "dismiss":
+		required, resolution = permissions.ReviewReports, "dismissed"
+	case "hide":
+		required, resolution = permissions.HideContent, "hidden"
+	case "approve":
+		if !target.Held {
+			errors.BadRequest(w, r, "Only held content can be approved")
+			return
+		}
+		required, resolution = permissions.HideContent, "approved"
+	case "delete":
+		required, resolution = permissions.DeletePost, "deleted"
+		if target.TargetType == "comment" {
+			required = permissions.DeleteComment
+		}
+	default:
+		errors.BadRequest(w, r, "Unknown action")
+		return
+	}
+
+	if !allowedOnPost(w, r, moderatorID, required, target.PostID) {
+		return
+	}
+
+	reasons, err := db.GetOpenReportReasons(db.DB, target.TargetType, target.TargetID)
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+
+	reason := strings.TrimSpace(r.FormValue("note"))
+	if reason == "" {
+		reason = "reported: " + strings.Join(reasons, ", ")
+	}
+	logAction := target.TargetType + "." + action
+	if action == "dismiss" {
+		logAction = "report.dismiss"
+	}
+	entry := db.AuditEntry{ActorID: moderatorID, Action: logAction, TargetType: target.TargetType, TargetID: target.TargetID, Reason: reason}
+
+	err = db.Audited(db.DB, entry, func(q db.Querier) error {
+		var err error
+		now := time.Now().UTC()
+		switch {
+		case action == "hide" && target.TargetType == "post":
+			err = db.SetPostHidden(q, target.TargetID, true, now)
+		case action == "hide":
+			err = db.SetCommentHidden(q, target.TargetID, true, now)
+		case action == "approve":
+			err = db.ApproveHeld(q, target.TargetType, target.TargetID)
+		case action == "delete" && target.TargetType == "post":
+			err = db.SoftDeletePost(q, target.TargetID, now)
+		case action == "delete":
+			err = db.SoftDeleteComment(q, target.TargetID, now)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = db.ResolveReports(q, target.TargetType, target.TargetID, moderatorID, resolution)
+		return err
+	})
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/admin/reports?done="+resolution, http.StatusSeeOther)
+}
+
+// UnhideHandler handles POST /admin/unhide, making hidden content public again
+func UnhideHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+
+	session, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	target, ok := loadTarget(w, r)
+	if !ok {
+		return
+	}
+	if !allowedOnPost(w, r, *session.UserID, permissions.HideContent, target.PostID) {
+		return
+	}
+
+	entry := db.AuditEntry{ActorID: *session.UserID, Action: target.TargetType + ".unhide", TargetType: target.TargetType, TargetID: target.TargetID}
+	err := db.Audited(db.DB, entry, func(q db.Querier) error {
+		if target.TargetType == "post" {
+			return db.SetPostHidden(q, target.TargetID, false, time.Now().UTC())
+		}
+		return db.SetCommentHidden(q, target.TargetID, false, time.Now().UTC())
+	})
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+
+	redirect := "/post?id=" + strconv.Itoa(target.PostID)
+	if target.TargetType == "comment" {
+		redirect += "#comment-" + strconv.Itoa(target.TargetID)
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// requireModerator lets in anyone who moderates at least one category
+func requireModerator(w http.ResponseWriter, r *http.Request) (*login.Session, bool) {
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil, false
+	}
+
+	staff, err := permissions.IsStaff(*session.UserID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+		return nil, false
+	}
+	if !staff {
+		errors.Forbidden(w, r, "This page is for moderators only")
+		return nil, false
+	}
+	return session, true
+}
+
+// loadTarget reads target_type and target_id from the form and loads the item
+func loadTarget(w http.ResponseWriter, r *http.Request) (*db.ReportedItem, bool) {
+	targetType := r.FormValue("target_type")
+	if targetType != "post" && targetType != "comment" {
+		errors.BadRequest(w, r, "Invalid target type")
+		return nil, false
+	}
+	targetID, err := strconv.Atoi(r.FormValue("target_id"))
+	if err != nil || targetID < 1 {
+		errors.BadRequest(w, r, "Invalid target ID")
+		return nil, false
+	}
+
+	target, err := db.GetReportTarget(db.DB, targetType, targetID)
+	if err == sql.ErrNoRows {
+		errors.NotFound(w, r, "Content not found")
+		return nil, false
+	}
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return nil, false
+	}
+	return target, true
+}
+
+// allowedOnPost checks a moderator permission against the categories of a post
+func allowedOnPost(w http.ResponseWriter, r *http.Request, userID int, action permissions.Action, postID int) bool {
+	categories, err := db.GetPostCategories(db.DB, postID)
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return false
+	}
+	allowed, err := permissions.Can(userID, action, categories...)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+		return false
+	}
+	if !allowed {
+		errors.Forbidden(w, r, "You don't moderate this category")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/permissions"
+	"forum/Backend/posts"
+	"net/http"
+	"strings"
+)
+
+// RolesHandler handles GET and POST /admin/roles, where admins appoint
+// moderators and admins and assign category moderators
+func RolesHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := requireStaff(w, r, permissions.ManageRoles)
+	if !ok {
+		return
+	}
+
+	var message, errMsg string
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		message, errMsg = applyRoleChange(r, *session.UserID)
+		if errMsg != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	default:
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	staff, err := db.GetStaff(db.DB)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching staff: "+err.Error())
+		return
+	}
+
+	render(w, r, *session.UserID, "roles", map[string]interface{}{
+		"Staff":      staff,
+		"Roles":      permissions.Roles,
+		"Categories": posts.Categories(),
+		"Message":    message,
+		"Error":      errMsg,
+	})
+}
+
+// applyRoleChange performs the form's action and returns a success or error message
+func applyRoleChange(r *http.Request, adminID int) (string, string) {
+	username := strings.TrimSpace(r.FormValue("username"))
+	userID, err := db.GetUserIDByUsername(db.DB, username)
+	if err == sql.ErrNoRows {
+		return "", "No user named " + username
+	}
+	if err != nil {
+		return "", "Database error: " + err.Error()
+	}
+
+	switch r.FormValue("action") {
+	case "set_role":
+		role := r.FormValue("role")
+		if !permissions.ValidRole(role) {
+			return "", "Unknown role"
+		}
+		if userID == adminID && role != permissions.RoleAdmin {
+			return "", "You cannot remove your own admin role"
+		}
+		entry := db.AuditEntry{ActorID: adminID, Action: "user.role", TargetType: "user", TargetID: userID}
+		if err := db.Audited(db.DB, entry, func(q db.Querier) error { return db.SetUserRole(q, userID, role) }); err != nil {
+			return "", "Database error: " + err.Error()
+		}
+		return username + " is now " + role, ""
+
+	case "add_category":
+		category := r.FormValue("category")
+		if !validCategory(category) {
+			return "", "Unknown category"
+		}
+		entry := db.AuditEntry{ActorID: adminID, Action: "user.category_add", TargetType: "user", TargetID: userID, Reason: category}
+		if err := db.Audited(db.DB, entry, func(q db.Querier) error { return db.AddCategoryModerator(q, userID, category) }); err != nil {
+			return "", "Database error: " + err.Error()
+		}
+		return username + " now moderates " + category, ""
+
+	case "remove_category":
+		category := r.FormValue("category")
+		entry := db.AuditEntry{ActorID: adminID, Action: "user.category_remove", TargetType: "user", TargetID: userID, Reason: category}
+		if err := db.Audited(db.DB, entry, func(q db.Querier) error { return db.RemoveCategoryModerator(q, userID, category) }); err != nil {
+			return "", "Database error: " + err.Error()
+		}
+		return username + " no longer moderates " + category, ""
+	}
+
+	return "", "Unknown action"
+}
+
+func validCategory(category string) bool {
+	for _, c := range posts.Categories() {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	db "forum/Backend/DB"
+	register "forum/Backend/Register"
+	"forum/Backend/login"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// registerHandler handles POST /api/v1/auth/register
+func registerHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	var req registerRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	req.Email = strings.ToLower(req.Email)
+
+	userMsg, err := register.RegisterUser(db.DB, req.Username, req.Email, req.Password)
+	if err != nil {
+		internalError(w, err.Error())
+		return
+	}
+	if userMsg != "" {
+		badRequest(w, userMsg)
+		return
+	}
+
+	user, err := db.GetUserByIdentifier(db.DB, req.Username)
+	if err != nil {
+		internalError(w, "Database error: "+err.Error())
+		return
+	}
+	writeData(w, http.StatusCreated, userJSON{ID: user.ID, Username: user.Username, Email: user.Email})
+}
+
+// loginHandler handles POST /api/v1/auth/login.
+// The session token is returned in the body and also set as the session cookie.
+func loginHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	var req loginRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Identifier == "" || req.Password == "" {
+		badRequest(w, "All fields are required")
+		return
+	}
+
+	result, err := login.Authenticate(req.Identifier, req.Password, login.ClientIP(r))
+	if err != nil {
+		internalError(w, "Error checking credentials: "+err.Error())
+		return
+	}
+	// Accounts with two-factor authentication send the code along with the password
+	if result.TwoFactor && req.Code != "" {
+		result, err = login.VerifySecondFactor(result.User, req.Identifier, req.Code, login.ClientIP(r))
+		if err != nil {
+			internalError(w, "Error checking code: "+err.Error())
+			return
+		}
+	}
+	if result.User == nil || result.TwoFactor {
+		switch result.Reason {
+		case login.FailLocked:
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.Wait.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, result.Reason, result.Message)
+		case login.FailBanned:
+			writeError(w, http.StatusForbidden, result.Reason, result.Message)
+		default:
+			writeError(w, http.StatusUnauthorized, result.Reason, result.Message)
+		}
+		return
+	}
+	user := result.User
+
+	token, expires, err := login.StartSession(w, r, user.ID, req.Remember)
+	if err != nil {
+		internalError(w, "Failed to create session: "+err.Error())
+		return
+	}
+
+	writeData(w, http.StatusOK, sessionJSON{
+		Token:        token,
+		ExpiresAt:    expires,
+		User:         userJSON{ID: user.ID, Username: user.Username, Email: user.Email},
+		FailedLogins: toLoginAttemptsJSON(result.Failures),
+	})
+}
+
+// logoutHandler handles POST /api/v1/auth/logout
+func logoutHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	if session.TokenID != 0 {
+		badRequest(w, "API tokens are revoked from the profile page, not logged out")
+		return
+	}
+	if err := db.DeleteSessionByToken(db.DB, session.Token); err != nil {
+		internalError(w, "Error deleting session: "+err.Error())
+		return
+	}
+	login.ClearSessionCookie(w)
+	writeData(w, http.StatusOK, struct{}{})
+}
+
+// meHandler handles GET /api/v1/auth/me
+func meHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	user, err := db.GetUserByID(db.DB, *session.UserID)
+	if err != nil {
+		internalError(w, "Database error: "+err.Error())
+		return
+	}
+	writeData(w, http.StatusOK, userJSON{ID: user.ID, Username: user.Username, Email: user.Email})
+}
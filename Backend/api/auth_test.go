@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"forum/Backend/login"
+	"net/http"
+	"testing"
+)
+
+func TestMeReturnsSessionUser(t *testing.T) {
+	dbtest.Use(t)
+	// carol's email is bob's username, so a lookup by name could find her
+	carolID := dbtest.CreateUser(t, db.DB, "carol")
+	if _, err := db.DB.Exec(`UPDATE users SET email = 'bob' WHERE id = ?`, carolID); err != nil {
+		t.Fatal(err)
+	}
+	bobID := dbtest.CreateUser(t, db.DB, "bob")
+
+	rec := call(http.MethodGet, "/auth/me", apiToken(t, bobID, login.ScopeRead), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Data userJSON `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data.ID != bobID || body.Data.Username != "bob" {
+		t.Errorf("me = %+v, want bob (%d)", body.Data, bobID)
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/permissions"
+	"forum/Backend/posts"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// listCommentsHandler handles GET /api/v1/posts/{id}/comments
+func listCommentsHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	postID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+	post, ok := fetchVisiblePost(w, r, postID)
+	if !ok {
+		return
+	}
+
+	tree, err := db.GetCommentTree(db.DB, postID, posts.MaxCommentDepth)
+	if err != nil {
+		internalError(w, "Error fetching comments: "+err.Error())
+		return
+	}
+	allowed, err := canSeeHidden(r, post)
+	if err != nil {
+		internalError(w, "Error checking permissions: "+err.Error())
+		return
+	}
+	if !allowed {
+		viewer := 0
+		if id := viewerID(r); id != nil {
+			viewer = *id
+		}
+		db.MaskHiddenComments(tree, viewer)
+		tree = db.RemoveShadowBanned(tree, viewer)
+	}
+	writeData(w, http.StatusOK, toCommentTree(tree))
+}
+
+// createCommentHandler handles POST /api/v1/posts/{id}/comments
+func createCommentHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok || !requireAction(w, session, permissions.Comment) {
+		return
+	}
+	postID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+	var req commentRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		badRequest(w, "Content is required")
+		return
+	}
+	if len(content) > posts.MaxCommentLength {
+		badRequest(w, "Comment too long (max 100 characters)")
+		return
+	}
+
+	exists, err := db.CheckPostExists(db.DB, postID)
+	if err != nil || !exists {
+		notFound(w, "Post does not exist")
+		return
+	}
+
+	if req.ParentID != 0 {
+		parentPostID, err := db.GetReplyParent(db.DB, req.ParentID)
+		if err != nil || parentPostID != postID {
+			badRequest(w, "Parent comment does not exist on this post")
+			return
+		}
+	}
+
+	commentID, held, errMsg, err := posts.PublishComment(*session.UserID, postID, req.ParentID, content)
+	if err != nil {
+		internalError(w, err.Error())
+		return
+	}
+	if errMsg != "" {
+		writeError(w, http.StatusBadRequest, "content_rejected", errMsg)
+		return
+	}
+
+	writeData(w, http.StatusCreated, commentCreatedJSON{ID: commentID, Held: held})
+}
+
+// deleteCommentHandler handles DELETE /api/v1/comments/{id}
+func deleteCommentHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	commentID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+
+	authorID, postID, err := db.GetCommentAuthor(db.DB, commentID)
+	if err == sql.ErrNoRows {
+		notFound(w, "Comment not found")
+		return
+	}
+	if err != nil {
+		internalError(w, "DB error: "+err.Error())
+		return
+	}
+	allowed, err := permissions.CanModifyOnPost(*session.UserID, authorID, postID, permissions.DeleteComment)
+	if err != nil {
+		internalError(w, "Error checking permissions: "+err.Error())
+		return
+	}
+	if !allowed {
+		forbidden(w, "You can only delete your own comments")
+		return
+	}
+
+	err = posts.AuditedChange(*session.UserID, authorID, db.AuditEntry{Action: "comment.delete", TargetType: "comment", TargetID: commentID}, func(q db.Querier) error {
+		return db.SoftDeleteComment(q, commentID, time.Now().UTC())
+	})
+	if err != nil {
+		internalError(w, "DB error deleting comment: "+err.Error())
+		return
+	}
+	writeData(w, http.StatusOK, struct{}{})
+}
+
+// likeCommentHandler handles POST /api/v1/comments/{id}/like
+func likeCommentHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok || !requireAction(w, session, permissions.Vote) {
+		return
+	}
+	commentID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+	var req likeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.IsLike == nil {
+		badRequest(w, "is_like is required")
+		return
+	}
+
+	exists, err := db.CheckCommentExists(db.DB, commentID)
+	if err != nil || !exists {
+		notFound(w, "Comment does not exist")
+		return
+	}
+	if err := db.ToggleLike(db.DB, db.LikeTarget{ID: commentID, UserID: *session.UserID, IsPost: false, IsLike: *req.IsLike}); err != nil {
+		internalError(w, "DB error: "+err.Error())
+		return
+	}
+	writeData(w, http.StatusOK, struct{}{})
+}
+
+// reportHandler handles POST /api/v1/posts/{id}/report and /api/v1/comments/{id}/report
+func reportHandler(targetType string) func(w http.ResponseWriter, r *http.Request, p pathParams) {
+	return func(w http.ResponseWriter, r *http.Request, p pathParams) {
+		session, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		targetID, ok := pathID(w, p)
+		if !ok {
+			return
+		}
+		var req reportRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		_, msg, status := posts.FileReport(*session.UserID, targetType, targetID, req.Reason, req.Details)
+		switch status {
+		case http.StatusOK:
+			writeData(w, http.StatusCreated, struct{}{})
+		case http.StatusNotFound:
+			notFound(w, msg)
+		case http.StatusInternalServerError:
+			internalError(w, msg)
+		default:
+			badRequest(w, msg)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"forum/Backend/posts"
+	"net/http"
+	"strings"
+)
+
+// obj is shorthand for a JSON object in the OpenAPI document
+type obj = map[string]interface{}
+
+func ref(name string) obj {
+	return obj{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(item obj) obj {
+	return obj{"type": "array", "items": item}
+}
+
+func object(required []string, props obj) obj {
+	o := obj{"type": "object", "properties": props}
+	if len(required) > 0 {
+		o["required"] = required
+	}
+	return o
+}
+
+var (
+	str      = obj{"type": "string"}
+	integer  = obj{"type": "integer"}
+	boolean  = obj{"type": "boolean"}
+	number   = obj{"type": "number"}
+	dateTime = obj{"type": "string", "format": "date-time"}
+)
+
+// schemas describes every request and response body named in routes()
+func schemas() obj {
+	post := object([]string{"id", "author", "title", "content", "categories", "created_at", "deleted", "likes", "dislikes", "comments"}, obj{
+		"id":         integer,
+		"author_id":  integer,
+		"author":     str,
+		"title":      str,
+		"content":    str,
+		"categories": arrayOf(str),
+		"created_at": dateTime,
+		"updated_at": dateTime,
+		"deleted":    boolean,
+		"hidden":     boolean,
+		"held":       obj{"type": "boolean", "description": "held by the content filter until a moderator approves it"},
+		"likes":      integer,
+		"dislikes":   integer,
+		"comments":   integer,
+		"user_liked": obj{"type": "integer", "enum": []int{0, 1}, "description": "1 = liked, 0 = disliked; absent if no vote"},
+	})
+	comment := object([]string{"id", "author", "content", "created_at", "deleted", "likes", "dislikes", "replies"}, obj{
+		"id":         integer,
+		"parent_id":  integer,
+		"author_id":  integer,
+		"author":     str,
+		"content":    str,
+		"created_at": dateTime,
+		"deleted":    boolean,
+		"hidden":     boolean,
+		"held":       boolean,
+		"likes":      integer,
+		"dislikes":   integer,
+		"replies":    arrayOf(ref("Comment")),
+	})
+	user := object([]string{"id", "username"}, obj{
+		"id":       integer,
+		"username": str,
+		"email":    str,
+	})
+	page := object([]string{"has_older", "has_newer"}, obj{
+		"has_older": boolean,
+		"has_newer": boolean,
+		"older":     obj{"type": "string", "description": "URL of the next (older) page"},
+		"newer":     obj{"type": "string", "description": "URL of the previous (newer) page"},
+	})
+
+	data := func(schema obj) obj {
+		return object([]string{"data"}, obj{"data": schema})
+	}
+
+	return obj{
+		"Error": object([]string{"error"}, obj{
+			"error": object([]string{"status", "code", "message"}, obj{
+				"status":  integer,
+				"code":    str,
+				"message": str,
+			}),
+		}),
+		"Post":     data(ref("PostData")),
+		"Comment":  comment,
+		"PostData": post,
+		"PostList": object([]string{"data"}, obj{
+			"data": arrayOf(ref("PostData")),
+			"page": page,
+		}),
+		"CommentList": data(arrayOf(ref("Comment"))),
+		"CommentCreated": data(object([]string{"id", "held"}, obj{
+			"id":   integer,
+			"held": obj{"type": "boolean", "description": "awaiting moderator review; only visible to the author"},
+		})),
+		"RevisionList": data(arrayOf(object(nil, obj{
+			"id":          integer,
+			"editor":      str,
+			"title":       str,
+			"content":     str,
+			"categories":  arrayOf(str),
+			"replaced_at": dateTime,
+		}))),
+		"User": data(user),
+		"Session": data(object([]string{"token", "expires_at", "user", "failed_logins"}, obj{
+			"token":      str,
+			"expires_at": dateTime,
+			"user":       user,
+			"failed_logins": obj{
+				"type":        "array",
+				"description": "failed login attempts on the account since its previous login",
+				"items": object([]string{"ip", "created_at"}, obj{
+					"ip":         str,
+					"created_at": dateTime,
+				}),
+			},
+		})),
+		"Profile": data(object(nil, obj{
+			"id":         integer,
+			"username":   str,
+			"avatar_url": obj{"type": "string", "description": "Path of the avatar image; absent when the user has none"},
+			"profile": object(nil, obj{
+				"bio":             str,
+				"favourite_games": obj{"type": "string", "description": "Comma-separated"},
+				"steam":           str,
+				"psn":             str,
+				"xbox":            str,
+				"minecraft":       str,
+			}),
+			"created_posts": arrayOf(ref("PostData")),
+			"liked_posts":   arrayOf(ref("PostData")),
+		})),
+		"CategoryList": data(arrayOf(str)),
+		"SearchResultList": data(arrayOf(object(nil, obj{
+			"kind":       obj{"type": "string", "enum": []string{"post", "comment"}},
+			"post_id":    integer,
+			"comment_id": integer,
+			"title":      str,
+			"snippet":    obj{"type": "string", "description": "Matched terms are wrapped in [[ ]]"},
+			"author":     str,
+			"created_at": dateTime,
+			"rank":       number,
+		}))),
+		"Empty": data(obj{"type": "object"}),
+
+		"RegisterRequest": object([]string{"username", "email", "password"}, obj{
+			"username": str,
+			"email":    str,
+			"password": str,
+		}),
+		"LoginRequest": object([]string{"identifier", "password"}, obj{
+			"identifier": obj{"type": "string", "description": "Username or email"},
+			"password":   str,
+			"remember":   obj{"type": "boolean", "description": "keep the session for 30 days of inactivity instead of 24 hours"},
+			"code":       obj{"type": "string", "description": "TOTP or recovery code; required when two-factor authentication is on, which is signalled by a 401 with code two_factor_required"},
+		}),
+		"PostRequest": object([]string{"title", "content", "categories"}, obj{
+			"title":      str,
+			"content":    str,
+			"categories": arrayOf(str),
+		}),
+		"CommentRequest": object([]string{"content"}, obj{
+			"content":   str,
+			"parent_id": obj{"type": "integer", "description": "Reply to this comment instead of the post"},
+		}),
+		"ReportRequest": object([]string{"reason"}, obj{
+			"reason":  obj{"type": "string", "enum": reportReasonCodes()},
+			"details": obj{"type": "string", "maxLength": posts.MaxReportDetailsLength},
+		}),
+		"LikeRequest": object([]string{"is_like"}, obj{
+			"is_like": obj{"type": "boolean", "description": "true = like, false = dislike"},
+		}),
+	}
+}
+
+func reportReasonCodes() []string {
+	var codes []string
+	for _, r := range posts.ReportReasons {
+		codes = append(codes, r.Code)
+	}
+	return codes
+}
+
+// openAPIDocument builds the OpenAPI 3.0 description of the API from the route table
+func openAPIDocument() obj {
+	paths := obj{}
+	for _, rt := range routes() {
+		op := obj{
+			"summary":     rt.Summary,
+			"tags":        []string{rt.Tag},
+			"operationId": operationID(rt),
+		}
+
+		if len(rt.Params) > 0 {
+			var params []obj
+			for _, p := range rt.Params {
+				params = append(params, obj{
+					"name":        p.Name,
+					"in":          p.In,
+					"required":    p.In == "path",
+					"description": p.Description,
+					"schema":      obj{"type": p.Type},
+				})
+			}
+			op["parameters"] = params
+		}
+
+		if rt.Body != "" {
+			op["requestBody"] = obj{
+				"required": true,
+				"content":  obj{"application/json": obj{"schema": ref(rt.Body)}},
+			}
+		}
+
+		errorResponse := func(desc string) obj {
+			return obj{"description": desc, "content": obj{"application/json": obj{"schema": ref("Error")}}}
+		}
+		status := "200"
+		if rt.Method == "POST" && (rt.Path == "/posts" || rt.Path == "/auth/register" || strings.HasSuffix(rt.Path, "/comments")) {
+			status = "201"
+		}
+		responses := obj{
+			status: obj{"description": "Success", "content": obj{"application/json": obj{"schema": ref(rt.Response)}}},
+			"400":  errorResponse("Invalid request"),
+			"500":  errorResponse("Server error"),
+		}
+		if rt.Auth {
+			op["security"] = []obj{{"sessionCookie": []string{}}, {"bearerAuth": []string{}}}
+			if rt.Scope != "" {
+				op["x-token-scope"] = rt.Scope
+			}
+			responses["401"] = errorResponse("Login required")
+			responses["403"] = errorResponse("Not allowed")
+		}
+		if len(rt.Params) > 0 && rt.Params[0].In == "path" {
+			responses["404"] = errorResponse("Not found")
+		}
+		if rt.Limit != "" {
+			op["x-rate-limit"] = rt.Limit
+			responses["429"] = errorResponse("Rate limited, see Retry-After")
+		}
+		op["responses"] = responses
+
+		item, _ := paths[rt.Path].(obj)
+		if item == nil {
+			item = obj{}
+			paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	return obj{
+		"openapi": "3.0.3",
+		"info": obj{
+			"title":       "Gaming Forum API",
+			"version":     "1.0.0",
+			"description": "JSON API for the forum. Successful responses are wrapped in {\"data\": ...}, failures in {\"error\": ...}.",
+		},
+		"servers": []obj{{"url": Prefix}},
+		"paths":   paths,
+		"components": obj{
+			"schemas": schemas(),
+			"securitySchemes": obj{
+				"sessionCookie": obj{"type": "apiKey", "in": "cookie", "name": "session_token", "description": "Set by /auth/login. Writes must send Content-Type: application/json"},
+				"bearerAuth":    obj{"type": "http", "scheme": "bearer", "description": "A personal API token created on the profile page. Tokens always have the read scope; post (writing, editing, deleting and reporting content) and vote must be granted."},
+			},
+		},
+	}
+}
+
+// operationID derives a stable operationId such as "get_posts_id_comments"
+func operationID(rt route) string {
+	path := strings.NewReplacer("{", "", "}", "", ".", "_").Replace(strings.Trim(rt.Path, "/"))
+	return strings.ToLower(rt.Method) + "_" + strings.ReplaceAll(path, "/", "_")
+}
+
+// openAPIHandler handles GET /api/v1/openapi.json
+func openAPIHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	writeJSON(w, http.StatusOK, openAPIDocument())
+}
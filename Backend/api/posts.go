@@ -0,0 +1,332 @@
+package api
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/permissions"
+	"forum/Backend/posts"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxPageSize caps the ?limit= of post listings
+const maxPageSize = 100
+
+// listPostsHandler handles GET /api/v1/posts
+func listPostsHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	q := r.URL.Query()
+	page := db.PageRequest{Limit: db.DefaultPageSize}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			badRequest(w, "limit must be between 1 and 100")
+			return
+		}
+		page.Limit = limit
+	}
+	if v := q.Get("before"); v != "" {
+		before, err := strconv.Atoi(v)
+		if err != nil || before < 1 {
+			badRequest(w, "Invalid before cursor")
+			return
+		}
+		page.Before = before
+	} else if v := q.Get("after"); v != "" {
+		after, err := strconv.Atoi(v)
+		if err != nil || after < 1 {
+			badRequest(w, "Invalid after cursor")
+			return
+		}
+		page.After = after
+	}
+
+	ps, info, err := db.FetchPostsByCategories(db.DB, q["category"], viewerID(r), page)
+	if err != nil {
+		internalError(w, "Failed to fetch posts")
+		return
+	}
+
+	pj := &pageJSON{HasOlder: info.HasOlder, HasNewer: info.HasNewer}
+	link := func(key string, cursor int) string {
+		values := url.Values{}
+		for k, v := range q {
+			if k != "before" && k != "after" {
+				values[k] = v
+			}
+		}
+		values.Set(key, strconv.Itoa(cursor))
+		return Prefix + "/posts?" + values.Encode()
+	}
+	if info.HasOlder {
+		pj.Older = link("before", info.OlderCursor)
+	}
+	if info.HasNewer {
+		pj.Newer = link("after", info.NewerCursor)
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Data: toPostList(ps), Page: pj})
+}
+
+// fetchPost loads a post or writes a 404/500
+func fetchPost(w http.ResponseWriter, postID int) (*db.PostShow, bool) {
+	p, err := db.GetPostWithCategories(db.DB, postID)
+	if err == sql.ErrNoRows {
+		notFound(w, "Post not found")
+		return nil, false
+	}
+	if err != nil {
+		internalError(w, "Error fetching post: "+err.Error())
+		return nil, false
+	}
+	return p, true
+}
+
+// canSeeHidden reports whether the viewer may see hidden content on a post
+func canSeeHidden(r *http.Request, post *db.PostShow) (bool, error) {
+	viewer := viewerID(r)
+	if viewer == nil {
+		return false, nil
+	}
+	return permissions.Can(*viewer, permissions.HideContent, post.Categories...)
+}
+
+// getPostHandler handles GET /api/v1/posts/{id}
+func getPostHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	postID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+	post, ok := fetchVisiblePost(w, r, postID)
+	if !ok {
+		return
+	}
+	writeData(w, http.StatusOK, toPostJSON(*post))
+}
+
+// fetchVisiblePost is fetchPost that 404s on hidden posts and posts by
+// shadow-banned users unless the viewer is their author or a moderator
+func fetchVisiblePost(w http.ResponseWriter, r *http.Request, postID int) (*db.PostShow, bool) {
+	post, ok := fetchPost(w, postID)
+	if !ok || !(post.Hidden || post.ShadowBanned) || post.Deleted {
+		return post, ok
+	}
+	if viewer := viewerID(r); viewer != nil && *viewer == post.UserID {
+		return post, true
+	}
+	allowed, err := canSeeHidden(r, post)
+	if err != nil {
+		internalError(w, "Error checking permissions: "+err.Error())
+		return nil, false
+	}
+	if !allowed {
+		notFound(w, "Post not found")
+		return nil, false
+	}
+	return post, true
+}
+
+// createPostHandler handles POST /api/v1/posts
+func createPostHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok || !requireAction(w, session, permissions.CreatePost) {
+		return
+	}
+	var req postRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	title, content, categories, errMsg := posts.ValidatePost(req.Title, req.Content, req.Categories)
+	if errMsg != "" {
+		badRequest(w, errMsg)
+		return
+	}
+
+	postID, _, errMsg, err := posts.PublishPost(*session.UserID, title, content, categories)
+	if err != nil {
+		internalError(w, err.Error())
+		return
+	}
+	if errMsg != "" {
+		writeError(w, http.StatusBadRequest, "content_rejected", errMsg)
+		return
+	}
+
+	post, ok := fetchPost(w, postID)
+	if !ok {
+		return
+	}
+	writeData(w, http.StatusCreated, toPostJSON(*post))
+}
+
+// updatePostHandler handles PUT /api/v1/posts/{id}
+func updatePostHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	postID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+	post, ok := fetchPost(w, postID)
+	if !ok {
+		return
+	}
+	if post.Deleted {
+		notFound(w, "Post not found")
+		return
+	}
+	allowed, err := permissions.CanModify(*session.UserID, post.UserID, permissions.EditPost, post.Categories...)
+	if err != nil {
+		internalError(w, "Error checking permissions: "+err.Error())
+		return
+	}
+	if !allowed {
+		forbidden(w, "You can only edit your own posts")
+		return
+	}
+
+	var req postRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	title, content, categories, errMsg := posts.ValidatePost(req.Title, req.Content, req.Categories)
+	if errMsg != "" {
+		badRequest(w, errMsg)
+		return
+	}
+
+	_, errMsg, err = posts.RevisePost(*session.UserID, post, title, content, categories)
+	if err != nil {
+		internalError(w, err.Error())
+		return
+	}
+	if errMsg != "" {
+		writeError(w, http.StatusBadRequest, "content_rejected", errMsg)
+		return
+	}
+
+	post, ok = fetchPost(w, postID)
+	if !ok {
+		return
+	}
+	writeData(w, http.StatusOK, toPostJSON(*post))
+}
+
+// deletePostHandler handles DELETE /api/v1/posts/{id}
+func deletePostHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	postID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+
+	authorID, err := db.GetPostAuthorID(db.DB, postID)
+	if err == sql.ErrNoRows {
+		notFound(w, "Post not found")
+		return
+	}
+	if err != nil {
+		internalError(w, "DB error: "+err.Error())
+		return
+	}
+	allowed, err := permissions.CanModifyOnPost(*session.UserID, authorID, postID, permissions.DeletePost)
+	if err != nil {
+		internalError(w, "Error checking permissions: "+err.Error())
+		return
+	}
+	if !allowed {
+		forbidden(w, "You can only delete your own posts")
+		return
+	}
+
+	err = posts.AuditedChange(*session.UserID, authorID, db.AuditEntry{Action: "post.delete", TargetType: "post", TargetID: postID}, func(q db.Querier) error {
+		return db.SoftDeletePost(q, postID, time.Now().UTC())
+	})
+	if err != nil {
+		internalError(w, "DB error deleting post: "+err.Error())
+		return
+	}
+	writeData(w, http.StatusOK, struct{}{})
+}
+
+// listRevisionsHandler handles GET /api/v1/posts/{id}/revisions
+func listRevisionsHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	postID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+	post, ok := fetchVisiblePost(w, r, postID)
+	if !ok {
+		return
+	}
+	if post.Deleted {
+		notFound(w, "Post not found")
+		return
+	}
+
+	revisions, err := db.GetPostRevisions(db.DB, postID)
+	if err != nil {
+		internalError(w, "Error fetching revisions: "+err.Error())
+		return
+	}
+
+	out := make([]revisionJSON, 0, len(revisions))
+	for _, rev := range revisions {
+		categories := rev.Categories
+		if categories == nil {
+			categories = []string{}
+		}
+		out = append(out, revisionJSON{
+			ID:         rev.ID,
+			Editor:     rev.EditorName,
+			Title:      rev.Title,
+			Content:    rev.Content,
+			Categories: categories,
+			ReplacedAt: rev.CreatedAt,
+		})
+	}
+	writeData(w, http.StatusOK, out)
+}
+
+// likePostHandler handles POST /api/v1/posts/{id}/like
+func likePostHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	session, ok := requireUser(w, r)
+	if !ok || !requireAction(w, session, permissions.Vote) {
+		return
+	}
+	postID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+	var req likeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.IsLike == nil {
+		badRequest(w, "is_like is required")
+		return
+	}
+
+	exists, err := db.CheckPostExists(db.DB, postID)
+	if err != nil || !exists {
+		notFound(w, "Post does not exist")
+		return
+	}
+	if err := db.ToggleLike(db.DB, db.LikeTarget{ID: postID, UserID: *session.UserID, IsPost: true, IsLike: *req.IsLike}); err != nil {
+		internalError(w, "DB error: "+err.Error())
+		return
+	}
+
+	post, ok := fetchPost(w, postID)
+	if !ok {
+		return
+	}
+	writeData(w, http.StatusOK, toPostJSON(*post))
+}
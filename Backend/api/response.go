@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"net/http"
+	"strconv"
+)
+
+// envelope wraps every successful response as {"data": ...}
+type envelope struct {
+	Data interface{} `json:"data"`
+	Page *pageJSON   `json:"page,omitempty"`
+}
+
+// errorEnvelope wraps every failure as {"error": {"status", "code", "message"}}
+type errorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// maxBodySize limits JSON request bodies
+const maxBodySize = 1 << 20
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	writeJSON(w, status, envelope{Data: data})
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorEnvelope{Error: apiError{Status: status, Code: code, Message: message}})
+}
+
+func badRequest(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusBadRequest, "bad_request", message)
+}
+
+func notFound(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusNotFound, "not_found", message)
+}
+
+func forbidden(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusForbidden, "forbidden", message)
+}
+
+func internalError(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusInternalServerError, "internal_error", message)
+}
+
+// decodeBody parses a JSON request body into v, rejecting unknown fields
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		badRequest(w, "Invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// pathID parses the {id} path parameter
+func pathID(w http.ResponseWriter, p pathParams) (int, bool) {
+	id, err := strconv.Atoi(p["id"])
+	if err != nil || id < 1 {
+		badRequest(w, "Invalid ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// requireUser returns the logged-in user's session or writes a 401
+func requireUser(w http.ResponseWriter, r *http.Request) (*login.Session, bool) {
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session == nil || session.IsGuest || session.UserID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Login required")
+		return nil, false
+	}
+	return session, true
+}
+
+// requireAction checks that the user may perform action, writing a 403 if not
+func requireAction(w http.ResponseWriter, session *login.Session, action permissions.Action) bool {
+	allowed, err := permissions.Allowed(session, action)
+	if err != nil {
+		internalError(w, "Error checking permissions: "+err.Error())
+		return false
+	}
+	if !allowed {
+		forbidden(w, permissions.DeniedMessageFor(session))
+		return false
+	}
+	return true
+}
+
+// viewerID returns the logged-in user's ID, or nil for guests
+func viewerID(r *http.Request) *int {
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session == nil || session.IsGuest {
+		return nil
+	}
+	return session.UserID
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"forum/Backend/login"
+	"forum/Backend/ratelimit"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Prefix is the mount point of the versioned API
+const Prefix = "/api/v1"
+
+// Param documents a path or query parameter of a route
+type Param struct {
+	Name        string
+	In          string // "path" or "query"
+	Type        string // "integer", "string" or "boolean"
+	Description string
+}
+
+// route describes one endpoint; the same table drives dispatching and the OpenAPI document
+type route struct {
+	Method   string
+	Path     string // relative to Prefix, e.g. "/posts/{id}"
+	Summary  string
+	Tag      string
+	Auth     bool
+	Scope    string // API token scope required, for Auth routes
+	Limit    string // ratelimit rule throttling the route, if any
+	Params   []Param
+	Body     string // name of the request schema, if any
+	Response string // name of the response schema
+	Handler  func(w http.ResponseWriter, r *http.Request, p pathParams)
+}
+
+// pathParams holds the values of {placeholders} matched in the path
+type pathParams map[string]string
+
+// Handler returns the http.Handler serving every /api/v1 route
+func Handler() http.Handler {
+	return http.HandlerFunc(serveAPI)
+}
+
+func serveAPI(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+	if path == "" {
+		path = "/"
+	}
+
+	pathMatched := false
+	for _, rt := range routes() {
+		params, ok := matchPath(rt.Path, path)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+		if rt.Method != r.Method {
+			continue
+		}
+		if !crossSiteSafe(r) {
+			writeError(w, http.StatusForbidden, "csrf_rejected", "Requests authenticated by cookie must send Content-Type: application/json")
+			return
+		}
+		if rt.Scope != "" {
+			session, err := login.GetSessionFromRequest(r)
+			if err == nil && !session.IsGuest && !session.HasScope(rt.Scope) {
+				writeError(w, http.StatusForbidden, "insufficient_scope", "This API token lacks the \""+rt.Scope+"\" scope")
+				return
+			}
+		}
+		if rt.Limit != "" {
+			if ok, wait := ratelimit.Check(r, rt.Limit); !ok {
+				ratelimit.SetRetryAfter(w, wait)
+				writeError(w, http.StatusTooManyRequests, "rate_limited", "Too many requests, slow down")
+				return
+			}
+		}
+		rt.Handler(w, r, params)
+		return
+	}
+
+	if pathMatched {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+	writeError(w, http.StatusNotFound, "not_found", "No such endpoint")
+}
+
+// crossSiteSafe guards cookie-authenticated writes against forgery. Other
+// sites can only send JSON after a CORS preflight, which this API never
+// allows, so requiring it is enough; bearer tokens are never sent by browsers
+// on their own.
+func crossSiteSafe(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Header.Get("Authorization") != "" {
+		return true
+	}
+	if _, err := r.Cookie("session_token"); err != nil {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// matchPath matches a request path against a pattern like "/posts/{id}/comments"
+func matchPath(pattern, path string) (pathParams, bool) {
+	pp := strings.Split(strings.Trim(pattern, "/"), "/")
+	sp := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pp) != len(sp) {
+		return nil, false
+	}
+
+	params := pathParams{}
+	for i := range pp {
+		if strings.HasPrefix(pp[i], "{") && strings.HasSuffix(pp[i], "}") {
+			if sp[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(pp[i], "{}")] = sp[i]
+			continue
+		}
+		if pp[i] != sp[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+var idParam = Param{Name: "id", In: "path", Type: "integer", Description: "Resource ID"}
+
+var pageParams = []Param{
+	{Name: "before", In: "query", Type: "integer", Description: "Return posts older than this post ID"},
+	{Name: "after", In: "query", Type: "integer", Description: "Return posts newer than this post ID"},
+	{Name: "limit", In: "query", Type: "integer", Description: "Page size (max 100)"},
+}
+
+// routes lists every API endpoint
+func routes() []route {
+	return []route{
+		// Auth
+		{Method: "POST", Path: "/auth/register", Summary: "Create an account", Tag: "auth", Body: "RegisterRequest", Response: "User", Limit: "register", Handler: registerHandler},
+		{Method: "POST", Path: "/auth/login", Summary: "Log in and start a session", Tag: "auth", Body: "LoginRequest", Response: "Session", Limit: "login", Handler: loginHandler},
+		{Method: "POST", Path: "/auth/logout", Summary: "End the current session", Tag: "auth", Auth: true, Scope: login.ScopeRead, Response: "Empty", Handler: logoutHandler},
+		{Method: "GET", Path: "/auth/me", Summary: "Get the logged-in user", Tag: "auth", Auth: true, Scope: login.ScopeRead, Response: "User", Handler: meHandler},
+
+		// Posts
+		{Method: "GET", Path: "/posts", Summary: "List posts, newest first", Tag: "posts",
+			Params:   append([]Param{{Name: "category", In: "query", Type: "string", Description: "Only posts in these categories (repeatable)"}}, pageParams...),
+			Response: "PostList", Handler: listPostsHandler},
+		{Method: "POST", Path: "/posts", Summary: "Create a post", Tag: "posts", Auth: true, Scope: login.ScopePost, Body: "PostRequest", Response: "Post", Limit: "post", Handler: createPostHandler},
+		{Method: "GET", Path: "/posts/{id}", Summary: "Get a post", Tag: "posts", Params: []Param{idParam}, Response: "Post", Handler: getPostHandler},
+		{Method: "PUT", Path: "/posts/{id}", Summary: "Edit your post", Tag: "posts", Auth: true, Scope: login.ScopePost, Params: []Param{idParam}, Body: "PostRequest", Response: "Post", Handler: updatePostHandler},
+		{Method: "DELETE", Path: "/posts/{id}", Summary: "Delete your post", Tag: "posts", Auth: true, Scope: login.ScopePost, Params: []Param{idParam}, Response: "Empty", Handler: deletePostHandler},
+		{Method: "GET", Path: "/posts/{id}/revisions", Summary: "List previous versions of a post", Tag: "posts", Params: []Param{idParam}, Response: "RevisionList", Handler: listRevisionsHandler},
+		{Method: "POST", Path: "/posts/{id}/like", Summary: "Like or dislike a post (toggles)", Tag: "likes", Auth: true, Scope: login.ScopeVote, Params: []Param{idParam}, Body: "LikeRequest", Response: "Post", Limit: "vote", Handler: likePostHandler},
+
+		// Comments
+		{Method: "GET", Path: "/posts/{id}/comments", Summary: "Get the comment tree of a post", Tag: "comments", Params: []Param{idParam}, Response: "CommentList", Handler: listCommentsHandler},
+		{Method: "POST", Path: "/posts/{id}/comments", Summary: "Comment on a post or reply to a comment", Tag: "comments", Auth: true, Scope: login.ScopePost, Params: []Param{idParam}, Body: "CommentRequest", Response: "CommentCreated", Limit: "comment", Handler: createCommentHandler},
+		{Method: "POST", Path: "/posts/{id}/report", Summary: "Report a post to the moderators", Tag: "reports", Auth: true, Scope: login.ScopePost, Params: []Param{idParam}, Body: "ReportRequest", Response: "Empty", Limit: "report", Handler: reportHandler("post")},
+		{Method: "POST", Path: "/comments/{id}/report", Summary: "Report a comment to the moderators", Tag: "reports", Auth: true, Scope: login.ScopePost, Params: []Param{idParam}, Body: "ReportRequest", Response: "Empty", Limit: "report", Handler: reportHandler("comment")},
+		{Method: "DELETE", Path: "/comments/{id}", Summary: "Delete your comment", Tag: "comments", Auth: true, Scope: login.ScopePost, Params: []Param{idParam}, Response: "Empty", Handler: deleteCommentHandler},
+		{Method: "POST", Path: "/comments/{id}/like", Summary: "Like or dislike a comment (toggles)", Tag: "likes", Auth: true, Scope: login.ScopeVote, Params: []Param{idParam}, Body: "LikeRequest", Response: "Empty", Limit: "vote", Handler: likeCommentHandler},
+
+		// Categories, profiles, search
+		{Method: "GET", Path: "/categories", Summary: "List categories", Tag: "categories", Response: "CategoryList", Handler: listCategoriesHandler},
+		{Method: "GET", Path: "/users/{id}", Summary: "Get a user profile", Tag: "users", Params: []Param{idParam}, Response: "Profile", Handler: getProfileHandler},
+		{Method: "GET", Path: "/search", Summary: "Full-text search over posts and comments", Tag: "search",
+			Params: []Param{
+				{Name: "q", In: "query", Type: "string", Description: "Search terms"},
+				{Name: "category", In: "query", Type: "string", Description: "Only results in these categories (repeatable)"},
+			},
+			Response: "SearchResultList", Handler: searchHandler},
+
+		// Documentation
+		{Method: "GET", Path: "/openapi.json", Summary: "This OpenAPI document", Tag: "meta", Response: "Empty", Handler: openAPIHandler},
+	}
+}
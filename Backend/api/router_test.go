@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"forum/Backend/login"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiToken creates a token for the user with the given scopes
+func apiToken(t *testing.T, userID int, scopes ...string) string {
+	t.Helper()
+	token, hash, err := login.GenerateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateAPIToken(db.DB, userID, "script", hash, scopes, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// call sends a JSON request to the API with a bearer token
+func call(method, path, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, Prefix+path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, r)
+	return rec
+}
+
+func TestReportsNeedPostScope(t *testing.T) {
+	dbtest.Use(t)
+	aliceID := dbtest.CreateUser(t, db.DB, "alice")
+	bobID := dbtest.CreateUser(t, db.DB, "bob")
+	postID, err := db.CreatePost(db.DB, aliceID, "Patch notes", "Body", time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	commentID, err := db.AddComment(db.DB, postID, aliceID, "A comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly := apiToken(t, bobID, login.ScopeRead)
+	poster := apiToken(t, bobID, login.ScopeRead, login.ScopePost)
+
+	for _, path := range []string{
+		"/posts/" + strconv.Itoa(postID) + "/report",
+		"/comments/" + strconv.Itoa(commentID) + "/report",
+	} {
+		rec := call(http.MethodPost, path, readOnly, `{"reason":"spam"}`)
+		var body errorEnvelope
+		json.NewDecoder(rec.Body).Decode(&body)
+		if rec.Code != http.StatusForbidden || body.Error.Code != "insufficient_scope" {
+			t.Errorf("%s with a read-only token = %d %q, want 403 insufficient_scope", path, rec.Code, body.Error.Code)
+		}
+
+		if rec := call(http.MethodPost, path, poster, `{"reason":"spam"}`); rec.Code != http.StatusCreated {
+			t.Errorf("%s with the post scope = %d, want 201: %s", path, rec.Code, rec.Body)
+		}
+	}
+
+	var reports int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM reports WHERE reporter_id = ?`, bobID).Scan(&reports); err != nil {
+		t.Fatal(err)
+	}
+	if reports != 2 {
+		t.Errorf("%d reports filed, want 2", reports)
+	}
+}
+
+func TestOpenAPIListsReportScope(t *testing.T) {
+	paths := openAPIDocument()["paths"].(obj)
+	for _, path := range []string{"/posts/{id}/report", "/comments/{id}/report"} {
+		op := paths[path].(obj)["post"].(obj)
+		if scope := op["x-token-scope"]; scope != login.ScopePost {
+			t.Errorf("%s x-token-scope = %v, want %q", path, scope, login.ScopePost)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	db "forum/Backend/DB"
+	"time"
+)
+
+// JSON representations of forum resources. These mirror the db structs but
+// keep the wire format stable and explicit.
+
+type postJSON struct {
+	ID         int        `json:"id"`
+	AuthorID   int        `json:"author_id,omitempty"`
+	Author     string     `json:"author"`
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	Categories []string   `json:"categories"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	Deleted    bool       `json:"deleted"`
+	Hidden     bool       `json:"hidden,omitempty"` // only seen by the author and moderators
+	Held       bool       `json:"held,omitempty"`   // hidden until a moderator approves it
+	Likes      int        `json:"likes"`
+	Dislikes   int        `json:"dislikes"`
+	Comments   int        `json:"comments"`
+	UserLiked  *int       `json:"user_liked,omitempty"` // 1 = liked, 0 = disliked
+}
+
+type commentJSON struct {
+	ID        int           `json:"id"`
+	ParentID  *int          `json:"parent_id,omitempty"`
+	AuthorID  int           `json:"author_id,omitempty"`
+	Author    string        `json:"author"`
+	Content   string        `json:"content"`
+	CreatedAt time.Time     `json:"created_at"`
+	Deleted   bool          `json:"deleted"`
+	Hidden    bool          `json:"hidden,omitempty"`
+	Held      bool          `json:"held,omitempty"`
+	Likes     int           `json:"likes"`
+	Dislikes  int           `json:"dislikes"`
+	Replies   []commentJSON `json:"replies"`
+}
+
+type commentCreatedJSON struct {
+	ID   int  `json:"id"`
+	Held bool `json:"held"` // awaiting moderator review, only visible to the author
+}
+
+type revisionJSON struct {
+	ID         int       `json:"id"`
+	Editor     string    `json:"editor"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Categories []string  `json:"categories"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type userJSON struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"` // only shown to the user themselves
+}
+
+type profileJSON struct {
+	ID           int         `json:"id"`
+	Username     string      `json:"username"`
+	AvatarURL    string      `json:"avatar_url,omitempty"`
+	Profile      *db.Profile `json:"profile"`
+	CreatedPosts []postJSON  `json:"created_posts"`
+	LikedPosts   []postJSON  `json:"liked_posts"`
+}
+
+type sessionJSON struct {
+	Token        string             `json:"token"`
+	ExpiresAt    time.Time          `json:"expires_at"`
+	User         userJSON           `json:"user"`
+	FailedLogins []loginAttemptJSON `json:"failed_logins"` // since the previous login
+}
+
+type loginAttemptJSON struct {
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type searchResultJSON struct {
+	Kind      string    `json:"kind"`
+	PostID    int       `json:"post_id"`
+	CommentID int       `json:"comment_id,omitempty"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"` // matches wrapped in [[ ]]
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+	Rank      float64   `json:"rank"`
+}
+
+type pageJSON struct {
+	HasOlder bool   `json:"has_older"`
+	HasNewer bool   `json:"has_newer"`
+	Older    string `json:"older,omitempty"` // URL of the next (older) page
+	Newer    string `json:"newer,omitempty"` // URL of the previous (newer) page
+}
+
+// Request bodies
+
+type registerRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginRequest struct {
+	Identifier string `json:"identifier"`
+	Password   string `json:"password"`
+	Remember   bool   `json:"remember"`
+	Code       string `json:"code"` // second factor, for accounts that have one
+}
+
+type postRequest struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Categories []string `json:"categories"`
+}
+
+type commentRequest struct {
+	Content  string `json:"content"`
+	ParentID int    `json:"parent_id,omitempty"`
+}
+
+type reportRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
+}
+
+type likeRequest struct {
+	IsLike *bool `json:"is_like"`
+}
+
+func toPostJSON(p db.PostShow) postJSON {
+	categories := p.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	out := postJSON{
+		ID:         p.ID,
+		AuthorID:   p.UserID,
+		Author:     p.Username,
+		Title:      p.Title,
+		Content:    p.Content,
+		Categories: categories,
+		CreatedAt:  p.CreatedAt,
+		UpdatedAt:  p.UpdatedAt,
+		Deleted:    p.Deleted,
+		Hidden:     p.Hidden,
+		Held:       p.Held,
+		Likes:      p.Likes,
+		Dislikes:   p.Dislikes,
+		Comments:   p.Comments,
+		UserLiked:  p.UserLiked,
+	}
+	if p.Deleted {
+		out.AuthorID = 0
+	}
+	return out
+}
+
+func toPostList(ps []db.PostShow) []postJSON {
+	out := make([]postJSON, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, toPostJSON(p))
+	}
+	return out
+}
+
+func toCommentTree(cs []db.Comment) []commentJSON {
+	out := make([]commentJSON, 0, len(cs))
+	for _, c := range cs {
+		cj := commentJSON{
+			ID:        c.ID,
+			ParentID:  c.ParentID,
+			AuthorID:  c.UserID,
+			Author:    c.Username,
+			Content:   c.Content,
+			CreatedAt: c.CreatedAt,
+			Deleted:   c.Deleted,
+			Hidden:    c.Hidden,
+			Held:      c.Held,
+			Likes:     c.Likes,
+			Dislikes:  c.Dislikes,
+			Replies:   toCommentTree(c.Replies),
+		}
+		if c.Deleted {
+			cj.AuthorID = 0
+		}
+		out = append(out, cj)
+	}
+	return out
+}
+
+func toLoginAttemptsJSON(as []db.LoginAttempt) []loginAttemptJSON {
+	out := make([]loginAttemptJSON, 0, len(as))
+	for _, a := range as {
+		out = append(out, loginAttemptJSON{IP: a.IP, CreatedAt: a.CreatedAt})
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/profile"
+	"net/http"
+	"strings"
+)
+
+// listCategoriesHandler handles GET /api/v1/categories
+func listCategoriesHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	names, err := db.GetCategories(db.DB)
+	if err != nil {
+		internalError(w, "Database error: "+err.Error())
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	writeData(w, http.StatusOK, names)
+}
+
+// getProfileHandler handles GET /api/v1/users/{id}
+func getProfileHandler(w http.ResponseWriter, r *http.Request, p pathParams) {
+	userID, ok := pathID(w, p)
+	if !ok {
+		return
+	}
+
+	exists, err := db.UserExists(db.DB, userID)
+	if err != nil {
+		internalError(w, "Database error: "+err.Error())
+		return
+	}
+	if !exists {
+		notFound(w, "User not found")
+		return
+	}
+
+	username, err := db.GetUsernameByID(db.DB, userID)
+	if err != nil {
+		internalError(w, "Database error: "+err.Error())
+		return
+	}
+	viewer := 0
+	if id := viewerID(r); id != nil {
+		viewer = *id
+	}
+	created, err := db.GetUserPosts(db.DB, userID, "created", viewer)
+	if err != nil {
+		internalError(w, "Error fetching created posts: "+err.Error())
+		return
+	}
+	liked, err := db.GetUserPosts(db.DB, userID, "liked", viewer)
+	if err != nil {
+		internalError(w, "Error fetching liked posts: "+err.Error())
+		return
+	}
+	about, err := db.GetProfile(db.DB, userID)
+	if err != nil {
+		internalError(w, "Error fetching profile: "+err.Error())
+		return
+	}
+	avatarVersion, err := db.GetAvatarVersion(db.DB, userID)
+	if err != nil {
+		internalError(w, "Database error: "+err.Error())
+		return
+	}
+
+	writeData(w, http.StatusOK, profileJSON{
+		ID:           userID,
+		Username:     username,
+		AvatarURL:    profile.AvatarURL(userID, avatarVersion),
+		Profile:      about,
+		CreatedPosts: toPostList(created),
+		LikedPosts:   toPostList(liked),
+	})
+}
+
+// searchHandler handles GET /api/v1/search
+func searchHandler(w http.ResponseWriter, r *http.Request, _ pathParams) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		badRequest(w, "q is required")
+		return
+	}
+
+	viewer := 0
+	if id := viewerID(r); id != nil {
+		viewer = *id
+	}
+	hits, err := db.SearchPosts(db.DB, query, r.URL.Query()["category"], viewer, maxPageSize)
+	if err != nil {
+		internalError(w, "Search failed")
+		return
+	}
+
+	out := make([]searchResultJSON, 0, len(hits))
+	for _, h := range hits {
+		var snippet strings.Builder
+		for _, part := range h.Snippet {
+			if part.Match {
+				snippet.WriteString("[[" + part.Text + "]]")
+			} else {
+				snippet.WriteString(part.Text)
+			}
+		}
+		out = append(out, searchResultJSON{
+			Kind:      h.Kind,
+			PostID:    h.PostID,
+			CommentID: h.CommentID,
+			Title:     h.Title,
+			Snippet:   snippet.String(),
+			Author:    h.Username,
+			CreatedAt: h.CreatedAt,
+			Rank:      h.Rank,
+		})
+	}
+	writeData(w, http.StatusOK, out)
+}
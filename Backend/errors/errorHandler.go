@@ -16,6 +16,17 @@ func BadRequest(w http.ResponseWriter, r *http.Request, msg string) {
 	tmpl.Execute(w, map[string]string{"Error": msg})
 }
 
+// 403 Forbidden
+func Forbidden(w http.ResponseWriter, r *http.Request, msg string) {
+	tmpl, err := template.ParseFiles("templates/err/403.html")
+	if err != nil {
+		http.Error(w, "Error loading template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusForbidden)
+	tmpl.Execute(w, map[string]string{"Error": msg})
+}
+
 // 404 Not Found
 func NotFound(w http.ResponseWriter, r *http.Request, msg string) {
 	tmpl, err := template.ParseFiles("templates/err/404.html")
@@ -48,3 +59,14 @@ func InternalServerError(w http.ResponseWriter, r *http.Request, msg string) {
 	w.WriteHeader(http.StatusInternalServerError)
 	tmpl.Execute(w, map[string]string{"Error": msg})
 }
+
+// 429 Too Many Requests; the caller sets Retry-After
+func TooManyRequests(w http.ResponseWriter, r *http.Request, msg string) {
+	tmpl, err := template.ParseFiles("templates/err/429.html")
+	if err != nil {
+		http.Error(w, "Error loading template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusTooManyRequests)
+	tmpl.Execute(w, map[string]string{"Error": msg})
+}
@@ -4,12 +4,20 @@ import (
 	db "forum/Backend/DB"
 	"forum/Backend/errors"
 	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"forum/Backend/profile"
+	"forum/Backend/security"
 	"html/template"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 )
 
+// PostsPerPage is the number of posts shown on one page of a feed
+const PostsPerPage = 20
+
 // Post represents a forum post with author info
 type Post struct {
 	ID            int
@@ -17,6 +25,7 @@ type Post struct {
 	Content       string
 	CreatedAt     string
 	Username      string
+	Avatar        string // empty when the author has none
 	Category      string
 	Categories    []string
 	Likes         int
@@ -30,26 +39,64 @@ type PageData struct {
 	Category           string
 	Posts              []Post
 	UserID             *int
+	IsStaff            bool // shows the moderation link
 	SelectedCategories []string
 	FilterApplied      bool
+	NewerURL           string            // empty when there is no newer page
+	OlderURL           string            // empty when there is no older page
+	FailedLogins       []db.LoginAttempt // shown once, right after logging in
 }
 
 // ---------------- DB Fetching Functions ----------------
 
-func fetchPostsWithUserLikes(category string, userID *int) ([]Post, error) {
-	ps, err := db.FetchPostsByCategory(db.DB, category, userID)
+func fetchPostsWithUserLikes(category string, userID *int, page db.PageRequest) ([]Post, db.PageInfo, error) {
+	ps, info, err := db.FetchPostsByCategory(db.DB, category, userID, page)
 	if err != nil {
-		return nil, err
+		return nil, info, err
 	}
-	return convertPostShow(ps), nil
+	return convertPostShow(ps), info, nil
 }
 
-func fetchFilteredPosts(categories []string, userID *int) ([]Post, error) {
-	ps, err := db.FetchPostsByCategories(db.DB, categories, userID)
+func fetchFilteredPosts(categories []string, userID *int, page db.PageRequest) ([]Post, db.PageInfo, error) {
+	ps, info, err := db.FetchPostsByCategories(db.DB, categories, userID, page)
 	if err != nil {
-		return nil, err
+		return nil, info, err
+	}
+	return convertPostShow(ps), info, nil
+}
+
+// ---------------- Pagination Helpers ----------------
+
+// parsePageRequest reads the ?before= / ?after= cursors from the query string
+func parsePageRequest(r *http.Request) db.PageRequest {
+	page := db.PageRequest{Limit: PostsPerPage}
+	if before, err := strconv.Atoi(r.URL.Query().Get("before")); err == nil && before > 0 {
+		page.Before = before
+	} else if after, err := strconv.Atoi(r.URL.Query().Get("after")); err == nil && after > 0 {
+		page.After = after
+	}
+	return page
+}
+
+// pageURLs builds the newer/older links for a page, keeping any other query parameters
+func pageURLs(r *http.Request, info db.PageInfo) (newer, older string) {
+	build := func(key string, cursor int) string {
+		q := url.Values{}
+		for k, v := range r.URL.Query() {
+			if k != "before" && k != "after" && k != "failed_logins" {
+				q[k] = v
+			}
+		}
+		q.Set(key, strconv.Itoa(cursor))
+		return r.URL.Path + "?" + q.Encode()
+	}
+	if info.HasNewer {
+		newer = build("after", info.NewerCursor)
+	}
+	if info.HasOlder {
+		older = build("before", info.OlderCursor)
 	}
-	return convertPostShow(ps), nil
+	return newer, older
 }
 
 // Convert DB PostShow struct to Post struct
@@ -61,6 +108,7 @@ func convertPostShow(ps []db.PostShow) []Post {
 			Title:         p.Title,
 			Content:       p.Content,
 			Username:      p.Username,
+			Avatar:        profile.AvatarURL(p.UserID, p.AvatarVersion),
 			Category:      strings.Join(p.Categories, ","),
 			Categories:    p.Categories,
 			Likes:         p.Likes,
@@ -116,7 +164,7 @@ func renderTemplate(w http.ResponseWriter, r *http.Request, templatePath string,
 		},
 	}
 
-	tmpl, err := template.New("").Funcs(funcMap).ParseFiles(templatePath)
+	tmpl, err := template.New("").Funcs(funcMap).Funcs(security.Funcs(r)).ParseFiles(templatePath)
 	if err != nil {
 		errors.InternalServerError(w, r, "Template parsing error")
 		return
@@ -145,16 +193,20 @@ func renderPostsWithPageData(w http.ResponseWriter, r *http.Request, templatePat
 		userID = session.UserID
 	}
 
-	posts, err := fetchPostsWithUserLikes(category, userID)
+	posts, info, err := fetchPostsWithUserLikes(category, userID, parsePageRequest(r))
 	if err != nil {
 		errors.InternalServerError(w, r, "Failed to fetch posts")
 		return
 	}
 
+	newerURL, olderURL := pageURLs(r, info)
 	data := PageData{
 		Category: category,
 		Posts:    posts,
 		UserID:   userID,
+		IsStaff:  isStaff(userID),
+		NewerURL: newerURL,
+		OlderURL: olderURL,
 	}
 
 	renderTemplate(w, r, templatePath, data)
@@ -185,32 +237,60 @@ func renderFilteredPosts(w http.ResponseWriter, r *http.Request, templatePath st
 	}
 
 	filterApplied := len(categories) > 0
+	page := parsePageRequest(r)
 	var posts []Post
+	var info db.PageInfo
 	if filterApplied {
-		posts, err = fetchFilteredPosts(categories, userID)
+		posts, info, err = fetchFilteredPosts(categories, userID, page)
 	} else {
-		posts, err = fetchPostsWithUserLikes("", userID)
+		posts, info, err = fetchPostsWithUserLikes("", userID, page)
 	}
 	if err != nil {
 		errors.InternalServerError(w, r, "Failed to load posts")
 		return
 	}
 
+	// The login redirects here when someone failed to log in as this user
+	var failedLogins []db.LoginAttempt
+	if userID != nil && r.URL.Query().Get("failed_logins") == "1" {
+		failedLogins, err = db.GetFailuresBeforeLastLogin(db.DB, *userID, login.MaxFailureNotices)
+		if err != nil {
+			errors.InternalServerError(w, r, "Failed to load login attempts")
+			return
+		}
+	}
+
+	newerURL, olderURL := pageURLs(r, info)
 	data := PageData{
 		Category:           "",
 		Posts:              posts,
 		UserID:             userID,
+		IsStaff:            isStaff(userID),
 		SelectedCategories: categories,
 		FilterApplied:      filterApplied,
+		NewerURL:           newerURL,
+		OlderURL:           olderURL,
+		FailedLogins:       failedLogins,
 	}
 
 	renderTemplate(w, r, templatePath, data)
 }
 
+// isStaff reports whether the viewer can use the moderation dashboard
+func isStaff(userID *int) bool {
+	if userID == nil {
+		return false
+	}
+	staff, err := permissions.IsStaff(*userID)
+	return err == nil && staff
+}
+
 // ---------------- HTTP Handlers ----------------
 
 func AllPosts(w http.ResponseWriter, r *http.Request) {
-	if len(r.URL.Query()) > 0 {
+	if strings.TrimSpace(r.URL.Query().Get("q")) != "" {
+		renderSearchResults(w, r, "templates/search.html")
+	} else if len(r.URL.Query()) > 0 {
 		renderFilteredPosts(w, r, "templates/index.html")
 	} else {
 		renderPostsWithPageData(w, r, "templates/index.html", "")
@@ -0,0 +1,109 @@
+package home
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"html"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxSearchResults caps the number of hits shown for one query
+const maxSearchResults = 50
+
+// SearchResult represents one hit for the search template
+type SearchResult struct {
+	Kind      string
+	Link      string
+	Title     string
+	Snippet   template.HTML // escaped text with <mark> highlighting
+	Username  string
+	CreatedAt string
+}
+
+// SearchPageData holds data for the search template
+type SearchPageData struct {
+	Query              string
+	Results            []SearchResult
+	UserID             *int
+	SelectedCategories []string
+	FilterApplied      bool
+}
+
+// highlightSnippet escapes a snippet and wraps the matched terms in <mark> tags
+func highlightSnippet(parts []db.SnippetPart) template.HTML {
+	var b strings.Builder
+	for _, part := range parts {
+		if part.Match {
+			b.WriteString("<mark>" + html.EscapeString(part.Text) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(part.Text))
+		}
+	}
+	return template.HTML(b.String())
+}
+
+func renderSearchResults(w http.ResponseWriter, r *http.Request, templatePath string) {
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil {
+		session = &login.Session{
+			IsGuest:  true,
+			Username: "Guest",
+		}
+	}
+
+	var userID *int
+	if !session.IsGuest && session.UserID != nil {
+		userID = session.UserID
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	categories := r.URL.Query()["categories"]
+
+	data := SearchPageData{
+		Query:              query,
+		UserID:             userID,
+		SelectedCategories: categories,
+		FilterApplied:      len(categories) > 0,
+	}
+
+	if query != "" {
+		viewerID := 0
+		if userID != nil {
+			viewerID = *userID
+		}
+		hits, err := db.SearchPosts(db.DB, query, categories, viewerID, maxSearchResults)
+		if err != nil {
+			errors.InternalServerError(w, r, "Search failed")
+			return
+		}
+		for _, h := range hits {
+			link := "/post?id=" + strconv.Itoa(h.PostID)
+			if h.Kind == "comment" {
+				link += "#comment-" + strconv.Itoa(h.CommentID)
+			}
+			data.Results = append(data.Results, SearchResult{
+				Kind:      h.Kind,
+				Link:      link,
+				Title:     h.Title,
+				Snippet:   highlightSnippet(h.Snippet),
+				Username:  h.Username,
+				CreatedAt: h.CreatedAt.Format("Jan 02, 2006 3:04 PM"),
+			})
+		}
+	}
+
+	renderTemplate(w, r, templatePath, data)
+}
+
+// SearchPosts handles GET /search?q=...&categories=...
+func SearchPosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	renderSearchResults(w, r, "templates/search.html")
+}
@@ -3,18 +3,38 @@ package login
 import (
 	db "forum/Backend/DB"
 	"net/http"
+	"strings"
 )
 
 // Session represents an app-level user session
 type Session struct {
-	Token    string
-	UserID   *int
-	Username string
-	IsGuest  bool
+	Token     string
+	SessionID int // set when authenticated by the session cookie
+	UserID    *int
+	Username  string
+	IsGuest   bool
+	TokenID   int      // set when authenticated by an API token
+	Scopes    []string // scopes of that token
 }
 
 // GetSessionFromRequest retrieves a session from a request
 func GetSessionFromRequest(r *http.Request) (*Session, error) {
+	// API tokens are only accepted by the JSON API, so token scopes never
+	// need checking in the HTML form handlers
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		if token := bearerToken(r); token != "" {
+			if s := sessionFromAPIToken(token); s != nil {
+				return s, nil
+			}
+			return &Session{
+				Token:    "",
+				UserID:   nil,
+				Username: "Guest",
+				IsGuest:  true,
+			}, nil
+		}
+	}
+
 	cookie, err := r.Cookie("session_token")
 	if err != nil {
 		// No cookie → guest
@@ -48,8 +68,9 @@ func GetSessionFromRequest(r *http.Request) (*Session, error) {
 	}
 
 	s := &Session{
-		Token:   record.Token,
-		IsGuest: false,
+		Token:     record.Token,
+		SessionID: record.ID,
+		IsGuest:   false,
 	}
 
 	if record.UserID.Valid {
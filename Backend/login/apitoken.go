@@ -0,0 +1,73 @@
+package login
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	db "forum/Backend/DB"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API token scopes. Every token may read; post and vote must be granted.
+const (
+	ScopeRead = "read"
+	ScopePost = "post"
+	ScopeVote = "vote"
+)
+
+// Scopes lists the scopes a token can be given, in display order
+var Scopes = []string{ScopeRead, ScopePost, ScopeVote}
+
+// apiTokenPrefix marks forum tokens so they are easy to spot in scripts and logs
+const apiTokenPrefix = "fgt_"
+
+// GenerateAPIToken returns a new random token and the hash to store for it
+func GenerateAPIToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = apiTokenPrefix + hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer ..." header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[7:])
+}
+
+// sessionFromAPIToken authenticates a bearer token and records its use
+func sessionFromAPIToken(token string) *Session {
+	t, err := db.GetAPITokenByHash(db.DB, HashToken(token))
+	if err != nil || t == nil || banned(t.UserID) {
+		return nil
+	}
+	_ = db.TouchAPIToken(db.DB, t.ID, time.Now().UTC())
+
+	uid := t.UserID
+	return &Session{
+		UserID:   &uid,
+		Username: t.Username,
+		TokenID:  t.ID,
+		Scopes:   t.Scopes,
+	}
+}
+
+// HasScope reports whether the session may perform actions of the given scope.
+// Cookie sessions may do anything; API tokens only what they were granted.
+func (s *Session) HasScope(scope string) bool {
+	if s.TokenID == 0 || scope == ScopeRead {
+		return true
+	}
+	for _, granted := range s.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package login
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPITokenRefusedWhileBanned(t *testing.T) {
+	dbtest.Use(t)
+	userID := dbtest.CreateUser(t, db.DB, "alice")
+	modID := dbtest.CreateUser(t, db.DB, "mod")
+	token, hash, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateAPIToken(db.DB, userID, "script", hash, []string{ScopeRead, ScopePost}, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionFor := func() *Session {
+		r := httptest.NewRequest("GET", "/api/v1/me", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		s, err := GetSessionFromRequest(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if s := sessionFor(); s.IsGuest || s.UserID == nil || *s.UserID != userID {
+		t.Fatalf("token before the ban = %+v, want alice's session", s)
+	}
+
+	later := time.Now().UTC().Add(time.Hour)
+	if err := db.CreateBan(db.DB, userID, db.BanSuspension, "spam", &later, modID); err != nil {
+		t.Fatal(err)
+	}
+	if s := sessionFor(); !s.IsGuest || s.UserID != nil {
+		t.Errorf("token while suspended = %+v, want a guest", s)
+	}
+}
@@ -0,0 +1,220 @@
+package login
+
+import (
+	"fmt"
+	db "forum/Backend/DB"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Reasons a login attempt fails
+const (
+	FailInvalid     = "invalid_credentials"
+	FailLocked      = "login_locked"
+	FailBanned      = "account_banned"
+	FailTwoFactor   = "two_factor_required"
+	FailInvalidCode = "invalid_code"
+)
+
+// InvalidCredentialsMessage is shown for a wrong password and for an unknown
+// account alike, so that the form cannot be used to find out who has one
+const InvalidCredentialsMessage = "Invalid username or password"
+
+// LockoutWindow is how far back failed attempts count, and how long a
+// lockout lasts after the last one
+const LockoutWindow = 15 * time.Minute
+
+// AttemptRetention is how long login attempts are kept
+const AttemptRetention = 30 * 24 * time.Hour
+
+// MaxFailureNotices caps the failed attempts listed after a login
+const MaxFailureNotices = 20
+
+// baseDelay is the wait after the first failure past the free ones; it
+// doubles with every further failure until the lockout
+const baseDelay = 2 * time.Second
+
+// lockoutPolicy allows Free failures without delay and locks out for
+// LockoutWindow at Lockout failures
+type lockoutPolicy struct {
+	Free    int
+	Lockout int
+}
+
+// Accounts are throttled tighter than IPs, which can be shared by many people
+var (
+	accountPolicy = lockoutPolicy{Free: 3, Lockout: 8}
+	ipPolicy      = lockoutPolicy{Free: 10, Lockout: 30}
+)
+
+// wait returns how long to wait after failures (newest first) before the next attempt
+func (p lockoutPolicy) wait(failures []time.Time, now time.Time) time.Duration {
+	n := len(failures)
+	if n < p.Free {
+		return 0
+	}
+	delay := LockoutWindow
+	if n < p.Lockout {
+		delay = baseDelay << (n - p.Free)
+	}
+	if w := failures[0].Add(delay).Sub(now); w > 0 {
+		return w
+	}
+	return 0
+}
+
+// LoginResult is the outcome of Authenticate. User is nil on failure, when
+// Reason and Message say why; Wait is set for FailLocked.
+type LoginResult struct {
+	User    *db.User
+	Reason  string
+	Message string
+	Wait    time.Duration
+	// TwoFactor is set when the password was right but the account needs a
+	// second factor; no session may be started until VerifySecondFactor passes
+	TwoFactor bool
+	// Failures are the failed attempts on the account since its previous login
+	Failures []db.LoginAttempt
+}
+
+// Authenticate checks a login from the given IP, throttling repeated
+// failures per account and per IP, and records the attempt
+func Authenticate(identifier, password, ip string) (*LoginResult, error) {
+	identifier = normalizeIdentifier(identifier)
+	user, err := db.GetUserByIdentifier(db.DB, identifier)
+	if err != nil {
+		user = nil
+	}
+	var userID *int
+	if user != nil {
+		userID = &user.ID
+	}
+
+	// Locked-out attempts are refused before the password is looked at
+	if locked, err := checkLockout(userID, identifier, ip); locked != nil || err != nil {
+		return locked, err
+	}
+
+	// Unknown accounts still cost a bcrypt comparison so timing gives nothing away
+	hash := dummyHash()
+	if user != nil {
+		hash = []byte(user.Password)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil {
+		if err := db.RecordLoginAttempt(db.DB, userID, identifier, ip, false); err != nil {
+			return nil, err
+		}
+		return &LoginResult{Reason: FailInvalid, Message: InvalidCredentialsMessage}, nil
+	}
+
+	// Banned and suspended accounts are told why instead of being let in
+	banMsg, err := ActiveBanMessage(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if banMsg != "" {
+		return &LoginResult{Reason: FailBanned, Message: banMsg}, nil
+	}
+
+	// The login only counts once the second factor is checked too
+	enabled, err := db.IsTOTPEnabled(db.DB, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return &LoginResult{User: user, TwoFactor: true, Reason: FailTwoFactor, Message: TwoFactorMessage}, nil
+	}
+
+	return completeLogin(user, identifier, ip)
+}
+
+// checkLockout returns a FailLocked result if the account or the IP has to
+// wait before trying again, nil otherwise
+func checkLockout(userID *int, identifier, ip string) (*LoginResult, error) {
+	now := time.Now().UTC()
+	since := now.Add(-LockoutWindow)
+	accountFailures, err := db.GetAccountFailures(db.DB, userID, identifier, since)
+	if err != nil {
+		return nil, err
+	}
+	ipFailures, err := db.GetIPFailures(db.DB, ip, since)
+	if err != nil {
+		return nil, err
+	}
+	wait := accountPolicy.wait(accountFailures, now)
+	if w := ipPolicy.wait(ipFailures, now); w > wait {
+		wait = w
+	}
+	if wait > 0 {
+		return &LoginResult{
+			Reason:  FailLocked,
+			Message: "Too many failed login attempts. Try again in " + waitText(wait) + ".",
+			Wait:    wait,
+		}, nil
+	}
+	return nil, nil
+}
+
+// completeLogin records a successful login and collects the failures the
+// user hasn't been told about yet
+func completeLogin(user *db.User, identifier, ip string) (*LoginResult, error) {
+	if err := db.RecordLoginAttempt(db.DB, &user.ID, identifier, ip, true); err != nil {
+		return nil, err
+	}
+	failures, err := db.GetFailuresBeforeLastLogin(db.DB, user.ID, MaxFailureNotices)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{User: user, Failures: failures}, nil
+}
+
+func normalizeIdentifier(identifier string) string {
+	return strings.ToLower(strings.TrimSpace(identifier))
+}
+
+var (
+	dummyOnce sync.Once
+	dummy     []byte
+)
+
+// dummyHash is compared against when the account does not exist
+func dummyHash() []byte {
+	dummyOnce.Do(func() {
+		dummy, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	})
+	return dummy
+}
+
+// waitText formats a wait for people, rounding up
+func waitText(d time.Duration) string {
+	n, unit := int(math.Ceil(d.Seconds())), "second"
+	if d > time.Minute {
+		n, unit = int(math.Ceil(d.Minutes())), "minute"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// StartAttemptCleanupJob periodically deletes login attempts older than
+// AttemptRetention, expired two-factor challenges and unfinished OAuth
+// sign-ins. It blocks, so run it in its own goroutine.
+func StartAttemptCleanupJob() {
+	for {
+		if _, err := db.DeleteLoginAttemptsBefore(db.DB, time.Now().UTC().Add(-AttemptRetention)); err != nil {
+			fmt.Println("Login attempt cleanup failed:", err)
+		}
+		if _, err := db.DeleteExpiredLoginChallenges(db.DB, time.Now().UTC()); err != nil {
+			fmt.Println("Login challenge cleanup failed:", err)
+		}
+		if _, err := db.DeleteExpiredOAuthStates(db.DB, time.Now().UTC()); err != nil {
+			fmt.Println("OAuth state cleanup failed:", err)
+		}
+		time.Sleep(time.Hour)
+	}
+}
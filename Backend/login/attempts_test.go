@@ -0,0 +1,112 @@
+package login
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLockoutPolicyWait(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := lockoutPolicy{Free: 3, Lockout: 8}
+
+	// failures returns n failures, the newest ago before now
+	failures := func(n int, ago time.Duration) []time.Time {
+		times := make([]time.Time, n)
+		for i := range times {
+			times[i] = now.Add(-ago - time.Duration(i)*time.Second)
+		}
+		return times
+	}
+
+	tests := []struct {
+		name     string
+		failures []time.Time
+		want     time.Duration
+	}{
+		{"no failures", nil, 0},
+		{"free failures", failures(2, 0), 0},
+		{"first delayed failure", failures(3, 0), 2 * time.Second},
+		{"delay doubles", failures(4, 0), 4 * time.Second},
+		{"last delay before the lockout", failures(7, 0), 32 * time.Second},
+		{"delay partly over", failures(5, 3*time.Second), 5 * time.Second},
+		{"delay over", failures(5, 10*time.Second), 0},
+		{"locked out", failures(8, 0), LockoutWindow},
+		{"more failures stay locked out", failures(12, 0), LockoutWindow},
+		{"lockout partly over", failures(8, 10*time.Minute), 5 * time.Minute},
+		{"lockout over", failures(8, LockoutWindow), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.wait(tt.failures, now); got != tt.want {
+				t.Errorf("wait = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockoutResetsAfterLogin(t *testing.T) {
+	dbtest.Use(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := dbtest.CreateUser(t, db.DB, "alice")
+	if _, err := db.DB.Exec(`UPDATE users SET password = ? WHERE id = ?`, string(hash), userID); err != nil {
+		t.Fatal(err)
+	}
+
+	// attempt records a login attempt that happened ago
+	attempt := func(success bool, ago time.Duration) {
+		t.Helper()
+		if _, err := db.DB.Exec(`INSERT INTO login_attempts (user_id, identifier, ip, success, created_at) VALUES (?, 'alice', ?, ?, ?)`,
+			userID, "192.0.2.1", success, time.Now().UTC().Add(-ago)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locked := func() bool {
+		t.Helper()
+		result, err := checkLockout(&userID, "alice", "198.51.100.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result != nil
+	}
+
+	for i := 0; i < accountPolicy.Lockout; i++ {
+		attempt(false, time.Minute)
+	}
+	if !locked() {
+		t.Fatal("not locked out after too many failures")
+	}
+	result, err := Authenticate("alice", "secret123", "198.51.100.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reason != FailLocked {
+		t.Fatalf("login while locked out: reason %q, want %q", result.Reason, FailLocked)
+	}
+
+	// A successful login wipes the slate: without it, these failures would
+	// still throttle for another 12 seconds
+	if _, err := db.DB.Exec(`DELETE FROM login_attempts`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < accountPolicy.Lockout-1; i++ {
+		attempt(false, 20*time.Second)
+	}
+	if !locked() {
+		t.Fatal("not throttled before the successful login")
+	}
+	attempt(true, 10*time.Second)
+	if locked() {
+		t.Error("still throttled after a successful login")
+	}
+	attempt(false, 0)
+	if locked() {
+		t.Error("one failure after a successful login is throttled")
+	}
+}
@@ -0,0 +1,31 @@
+package login
+
+import (
+	db "forum/Backend/DB"
+)
+
+// BanMessage explains to a banned or suspended user why they are locked out
+func BanMessage(b *db.Ban) string {
+	if b.ExpiresAt == nil {
+		return "Your account has been banned. Reason: " + b.Reason
+	}
+	until := b.ExpiresAt.UTC().Format("Jan 02, 2006 3:04 PM MST")
+	return "Your account is suspended until " + until + ". Reason: " + b.Reason
+}
+
+// ActiveBanMessage returns BanMessage for the user's active ban, or "" if
+// they may use the forum
+func ActiveBanMessage(userID int) (string, error) {
+	b, err := db.GetActiveBan(db.DB, userID)
+	if err != nil || b == nil {
+		return "", err
+	}
+	return BanMessage(b), nil
+}
+
+// banned reports whether the user is locked out; errors count as not banned
+// so a database hiccup does not log everyone out
+func banned(userID int) bool {
+	b, err := db.GetActiveBan(db.DB, userID)
+	return err == nil && b != nil
+}
@@ -3,18 +3,19 @@ package login
 import (
 	db "forum/Backend/DB"
 	"forum/Backend/errors"
+	"forum/Backend/oauth"
+	"forum/Backend/security"
 	"html/template"
+	"math"
 	"net/http"
-	"time"
-
-	"golang.org/x/crypto/bcrypt"
+	"strconv"
 )
 
-const sessionDuration = 24 * time.Hour
-
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	dbConn := db.DB
-	tmpl, err := template.ParseFiles("templates/login.html")
+	tmpl, err := template.New("login.html").Funcs(security.Funcs(r)).Funcs(template.FuncMap{
+		"providers": oauth.Providers,
+	}).ParseFiles("templates/login.html")
 	if err != nil {
 		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
 		return
@@ -31,7 +32,24 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 2️⃣ Serve login page on GET
 	if r.Method == http.MethodGet {
-		tmpl.Execute(w, nil)
+		data := map[string]string{}
+		switch {
+		case r.URL.Query().Get("reset") == "1":
+			data["Notice"] = "Your password was changed. Log in with the new one."
+		case r.URL.Query().Get("registered") == "1":
+			data["Notice"] = "Account created. We've emailed you a link to verify your address."
+		case r.URL.Query().Get("deleted") == "1":
+			data["Notice"] = "Your account was deleted. Your posts and comments now show as " + db.DeletedUsername + "."
+		case r.URL.Query().Get("expired") == "1":
+			data["Error"] = "Your login timed out or had too many wrong codes. Enter your password again."
+		case r.URL.Query().Get("oauth") == "no_email":
+			data["Error"] = "The provider didn't share a verified email address, so we can't tell which account is yours."
+		case r.URL.Query().Get("oauth") == "unverified":
+			data["Error"] = "An account with that email exists but hasn't confirmed its address. Log in with your password, then link the provider from your profile."
+		case r.URL.Query().Get("oauth") != "":
+			data["Error"] = "Signing in with the provider didn't work. Try again, or log in with your password."
+		}
+		tmpl.Execute(w, data)
 		return
 	}
 
@@ -50,61 +68,50 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 4️⃣ Fetch user by username/email
-	user, err := db.GetUserByIdentifier(dbConn, identifier)
+	// 4️⃣ Check the credentials, unless too many attempts failed recently
+	result, err := Authenticate(identifier, password, ClientIP(r))
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]string{"Error": "User does not exist"})
+		errors.InternalServerError(w, r, "Error checking credentials: "+err.Error())
 		return
 	}
-
-	// 5️⃣ Verify password
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]string{"Error": "Invalid password"})
+	if result.User == nil {
+		// 5️⃣ Wrong credentials, lockouts and bans each get their own status
+		switch result.Reason {
+		case FailLocked:
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.Wait.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+		case FailBanned:
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		tmpl.Execute(w, map[string]string{"Error": result.Message})
 		return
 	}
+	user := result.User
+	remember := r.FormValue("remember") == "on"
 
-	// 6️⃣ Try to delete any existing sessions for this user, but continue even if it fails
-	err = db.DeleteUserSessions(dbConn, user.ID)
-	if err != nil {
-		// Just log the error but continue with login process
-		// This ensures we don't show 500 errors to users
-		// and allows a new session to be created
-		// The old session will eventually expire anyway
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_token",
-			Value:    "",
-			Path:     "/",
-			HttpOnly: true,
-			MaxAge:   -1, // expire immediately
-		})
-	}
-
-	// 7️⃣ Create a new session token
-	token, err := GenerateToken()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		tmpl.Execute(w, map[string]string{"Error": "Token generation failed: " + err.Error()})
+	// Accounts with two-factor authentication continue at /login/2fa
+	if result.TwoFactor {
+		if err := startChallenge(w, r, user, identifier, remember); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			tmpl.Execute(w, map[string]string{"Error": "Failed to start two-factor login: " + err.Error()})
+		}
 		return
 	}
 
-	expiration := time.Now().Add(sessionDuration)
-	if err := db.CreateSession(dbConn, user.ID, token, expiration); err != nil {
+	// 6️⃣ Create a new session and 7️⃣ set the session cookie. Sessions on other
+	// devices stay logged in; users manage them at /profile/sessions
+	if _, _, err := StartSession(w, r, user.ID, remember); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		tmpl.Execute(w, map[string]string{"Error": "Failed to create session: " + err.Error()})
 		return
 	}
 
-	// 8️⃣ Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  expiration,
-	})
-
-	// 9️⃣ Redirect to home page
+	// 8️⃣ Redirect to home page, which lists failed attempts since the last login
+	if len(result.Failures) > 0 {
+		http.Redirect(w, r, "/homePage?failed_logins=1", http.StatusSeeOther)
+		return
+	}
 	http.Redirect(w, r, "/homePage", http.StatusSeeOther)
 }
@@ -8,6 +8,12 @@ import (
 
 // LogoutHandler logs the user out by deleting the session and expiring the cookie
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	// Only POST, so that links and images on other sites cannot log people out
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
 	// Get the current session
 	session, err := GetSessionFromRequest(r)
 	if err != nil {
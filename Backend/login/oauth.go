@@ -0,0 +1,314 @@
+package login
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/mailer"
+	"forum/Backend/oauth"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OAuthStateLifetime is how long a sign-in at a provider may take
+const OAuthStateLifetime = 10 * time.Minute
+
+// oauthStateCookie ties a sign-in to the browser that started it
+const oauthStateCookie = "oauth_state"
+
+// OAuthStartHandler sends the browser to a provider to log in (GET) or, for
+// a logged-in user, to link the provider to their account (POST with link=1)
+func OAuthStartHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+	provider := oauth.Get(r.FormValue("provider"))
+	if provider == nil {
+		errors.NotFound(w, r, "Unknown login provider")
+		return
+	}
+
+	state := db.OAuthState{Provider: provider.Name()}
+	// Linking changes an account, so it must come from a form on the site;
+	// the CSRF middleware only checks POSTs
+	if r.Method == http.MethodPost && r.FormValue("link") == "1" {
+		session, err := GetSessionFromRequest(r)
+		if err != nil || session.IsGuest || session.UserID == nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		state.LinkUserID = session.UserID
+	}
+
+	req := oauth.AuthRequest{RedirectURI: mailer.Link("/oauth/callback")}
+	for _, value := range []*string{&req.State, &req.Nonce, &req.Verifier} {
+		token, err := randomToken()
+		if err != nil {
+			errors.InternalServerError(w, r, "Error starting sign-in: "+err.Error())
+			return
+		}
+		*value = token
+	}
+	state.Nonce, state.Verifier = req.Nonce, req.Verifier
+	expiration := time.Now().UTC().Add(OAuthStateLifetime)
+	if err := db.CreateOAuthState(db.DB, HashToken(req.State), state, expiration); err != nil {
+		errors.InternalServerError(w, r, "Error starting sign-in: "+err.Error())
+		return
+	}
+
+	// Lax, so the cookie comes along when the provider redirects back
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    req.State,
+		Path:     "/oauth",
+		Expires:  expiration,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, provider.AuthURL(req), http.StatusSeeOther)
+}
+
+// OAuthCallbackHandler is where providers send the browser back. It logs in
+// the account linked to the identity, links the identity to an account with
+// the same verified email, or creates a new account.
+func OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	// The state must be the one this browser started with
+	query := r.URL.Query()
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		http.Redirect(w, r, "/login?oauth=failed", http.StatusSeeOther)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Value: "", Path: "/oauth", HttpOnly: true, MaxAge: -1})
+
+	state, err := db.TakeOAuthState(db.DB, HashToken(cookie.Value))
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	failed := "/login?oauth=failed"
+	if state != nil && state.LinkUserID != nil {
+		failed = "/profile/connections?error=failed"
+	}
+	var provider oauth.Provider
+	if state != nil {
+		provider = oauth.Get(state.Provider)
+	}
+	// Declined at the provider, expired or the provider was removed
+	if provider == nil || query.Get("error") != "" || query.Get("code") == "" {
+		http.Redirect(w, r, failed, http.StatusSeeOther)
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), oauth.AuthRequest{
+		State:       cookie.Value,
+		Nonce:       state.Nonce,
+		Verifier:    state.Verifier,
+		RedirectURI: mailer.Link("/oauth/callback"),
+	})
+	if err != nil {
+		fmt.Printf("OAuth sign-in with %s failed: %v\n", provider.Name(), err)
+		http.Redirect(w, r, failed, http.StatusSeeOther)
+		return
+	}
+
+	if state.LinkUserID != nil {
+		linkIdentity(w, r, *state.LinkUserID, provider.Name(), identity)
+		return
+	}
+
+	user, problem, err := userForIdentity(provider.Name(), identity)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error signing in: "+err.Error())
+		return
+	}
+	if user == nil {
+		http.Redirect(w, r, "/login?oauth="+problem, http.StatusSeeOther)
+		return
+	}
+	finishExternalLogin(w, r, user)
+}
+
+// userForIdentity finds or creates the account for a provider identity. If
+// there is none and one can't be created, problem says why.
+func userForIdentity(provider string, identity *oauth.Identity) (user *db.User, problem string, err error) {
+	linked, err := db.GetIdentity(db.DB, provider, identity.Subject)
+	if err != nil {
+		return nil, "", err
+	}
+	if linked != nil {
+		if err := db.TouchIdentity(db.DB, linked.ID, identity.Email); err != nil {
+			return nil, "", err
+		}
+		user, err := db.GetUserByID(db.DB, linked.UserID)
+		return user, "", err
+	}
+
+	// Only a verified email proves the identity belongs to the account's owner
+	if !identity.EmailVerified {
+		return nil, "no_email", nil
+	}
+
+	existing, err := db.GetUserByEmail(db.DB, identity.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, "", err
+	}
+	if existing != nil {
+		// Whoever registered the address first might not own it, unless
+		// they confirmed it too
+		if !existing.EmailVerified {
+			return nil, "unverified", nil
+		}
+		if err := db.CreateIdentity(db.DB, existing.ID, provider, identity.Subject, identity.Email); err != nil {
+			return nil, "", err
+		}
+		return existing, "", nil
+	}
+
+	username, err := availableUsername(identity)
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := db.CreateUserWithIdentity(db.DB, username, identity.Email, true, provider, identity.Subject)
+	if err != nil {
+		return nil, "", err
+	}
+	user, err = db.GetUserByID(db.DB, id)
+	return user, "", err
+}
+
+// linkIdentity adds a provider identity to the logged-in user's account
+func linkIdentity(w http.ResponseWriter, r *http.Request, userID int, provider string, identity *oauth.Identity) {
+	session, err := GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil || *session.UserID != userID {
+		errors.Forbidden(w, r, "Log in to the account you want to link first")
+		return
+	}
+
+	linked, err := db.GetIdentity(db.DB, provider, identity.Subject)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	switch {
+	case linked != nil && linked.UserID != userID:
+		http.Redirect(w, r, "/profile/connections?error=taken", http.StatusSeeOther)
+		return
+	case linked == nil:
+		if err := db.CreateIdentity(db.DB, userID, provider, identity.Subject, identity.Email); err != nil {
+			errors.InternalServerError(w, r, "Error linking account: "+err.Error())
+			return
+		}
+	}
+	http.Redirect(w, r, "/profile/connections?linked=1", http.StatusSeeOther)
+}
+
+// finishExternalLogin logs in a user whose identity a provider confirmed.
+// Bans and two-factor authentication apply as for password logins.
+func finishExternalLogin(w http.ResponseWriter, r *http.Request, user *db.User) {
+	banMsg, err := ActiveBanMessage(user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking bans: "+err.Error())
+		return
+	}
+	if banMsg != "" {
+		errors.Forbidden(w, r, banMsg)
+		return
+	}
+
+	identifier := normalizeIdentifier(user.Username)
+	enabled, err := db.IsTOTPEnabled(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if enabled {
+		if err := startChallenge(w, r, user, identifier, false); err != nil {
+			errors.InternalServerError(w, r, "Failed to start two-factor login: "+err.Error())
+		}
+		return
+	}
+
+	result, err := completeLogin(user, identifier, ClientIP(r))
+	if err != nil {
+		errors.InternalServerError(w, r, "Error recording login: "+err.Error())
+		return
+	}
+	if _, _, err := StartSession(w, r, user.ID, false); err != nil {
+		errors.InternalServerError(w, r, "Failed to create session: "+err.Error())
+		return
+	}
+	if len(result.Failures) > 0 {
+		http.Redirect(w, r, "/homePage?failed_logins=1", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/homePage", http.StatusSeeOther)
+}
+
+// availableUsername picks a free username for a new account from the name
+// or email the provider shared, within the rules of the registration form
+func availableUsername(identity *oauth.Identity) (string, error) {
+	base := usernameFrom(identity.Name)
+	if len(base) < 3 {
+		base = usernameFrom(strings.SplitN(identity.Email, "@", 2)[0])
+	}
+	if len(base) < 3 {
+		base = "player"
+	}
+
+	candidate := base
+	for i := 2; i < 100; i++ {
+		taken, err := db.UsernameExists(db.DB, candidate)
+		if err != nil || !taken {
+			return candidate, err
+		}
+		candidate = base + strconv.Itoa(i)
+	}
+	suffix, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	return base + suffix[:4], nil
+}
+
+// usernameFrom keeps the ASCII letters, digits, dots and underscores of s,
+// at most 16 of them, so a numeric suffix still fits in 20 characters
+func usernameFrom(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c > unicode.MaxASCII:
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_':
+			b.WriteRune(c)
+		case c == '.' && !strings.HasSuffix(b.String(), "."):
+			b.WriteRune(c)
+		}
+	}
+	name := strings.Trim(b.String(), "._")
+	if len(name) > 16 {
+		name = name[:16]
+	}
+	return strings.Trim(name, "._")
+}
+
+// randomToken returns 32 random bytes in hex
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
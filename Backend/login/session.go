@@ -0,0 +1,127 @@
+package login
+
+import (
+	"fmt"
+	db "forum/Backend/DB"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sessions expire after this long without activity. Remember-me sessions
+// also survive closing the browser.
+const (
+	sessionDuration  = 24 * time.Hour
+	rememberDuration = 30 * 24 * time.Hour
+)
+
+// maxUserAgentLength bounds the user agent stored with a session
+const maxUserAgentLength = 255
+
+// sessionTouchInterval limits how often activity is written to a session
+const sessionTouchInterval = 5 * time.Minute
+
+// sweepInterval is how often expired sessions are deleted
+const sweepInterval = time.Hour
+
+// StartSession creates a session for the user on the requesting device and
+// sets the session cookie. Sessions on other devices are left alone.
+func StartSession(w http.ResponseWriter, r *http.Request, userID int, remember bool) (string, time.Time, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiration := time.Now().UTC().Add(idleTimeout(remember))
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	if err := db.CreateSession(db.DB, userID, token, expiration, remember, userAgent, ClientIP(r)); err != nil {
+		return "", time.Time{}, err
+	}
+
+	setSessionCookie(w, token, expiration, remember)
+	return token, expiration, nil
+}
+
+// SlideSessions wraps the site so that every request on a session pushes its
+// expiry back. The write happens at most every sessionTouchInterval per
+// session; static files are skipped.
+func SlideSessions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session_token"); err == nil && !isAsset(r.URL.Path) {
+			record, err := db.GetSessionByToken(db.DB, cookie.Value)
+			if err == nil && time.Since(record.LastSeen) > sessionTouchInterval {
+				now := time.Now().UTC()
+				expiration := now.Add(idleTimeout(record.Remember))
+				if db.TouchSession(db.DB, record.ID, ClientIP(r), now, expiration) == nil {
+					setSessionCookie(w, record.Token, expiration, record.Remember)
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StartSessionSweeper periodically deletes expired sessions.
+// It blocks, so run it in its own goroutine.
+func StartSessionSweeper() {
+	for {
+		if n, err := db.DeleteExpiredSessions(db.DB, time.Now().UTC()); err != nil {
+			fmt.Println("Session sweep failed:", err)
+		} else if n > 0 {
+			fmt.Printf("Deleted %d expired session(s)\n", n)
+		}
+		time.Sleep(sweepInterval)
+	}
+}
+
+func idleTimeout(remember bool) time.Duration {
+	if remember {
+		return rememberDuration
+	}
+	return sessionDuration
+}
+
+// setSessionCookie sets the session cookie. Only remember-me cookies are
+// persistent; the others end with the browser.
+func setSessionCookie(w http.ResponseWriter, token string, expiration time.Time, remember bool) {
+	cookie := &http.Cookie{
+		Name:     "session_token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if remember {
+		cookie.Expires = expiration
+	}
+	http.SetCookie(w, cookie)
+}
+
+func isAsset(path string) bool {
+	return strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/backgrounds/")
+}
+
+// ClearSessionCookie expires the session cookie in the browser
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   -1, // expire immediately
+	})
+}
+
+// ClientIP is the address the request came from. Forwarding headers are
+// ignored since they can be forged.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,6 +1,9 @@
 package login
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+
 	"github.com/google/uuid"
 )
 
@@ -11,3 +14,11 @@ func GenerateToken() (string, error) {
 	}
 	return token.String(), nil
 }
+
+// HashToken hashes a random secret, such as an API token, a reset link or a
+// recovery code, for storage and lookup. The secrets are random enough that
+// a fast hash is sufficient.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,164 @@
+package login
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of RFC 6238 Appendix B, "12345678901234567890"
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// enrolUser registers an account with two-factor authentication enabled at
+// the given step, and returns its ID
+func enrolUser(t *testing.T, username string, step int64, recoveryHashes []string) int {
+	t.Helper()
+	id := dbtest.CreateUser(t, db.DB, username)
+	if err := db.SetPendingTOTP(db.DB, id, rfcSecret); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, err := db.EnableTOTP(db.DB, id, step, recoveryHashes); err != nil || !enabled {
+		t.Fatalf("EnableTOTP = %v, %v", enabled, err)
+	}
+	return id
+}
+
+func TestMatchTOTPRFCVectors(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step, ok := MatchTOTP(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("MatchTOTP(%q) at %d rejected", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / 30; step != want {
+			t.Errorf("MatchTOTP(%q) at %d = step %d, want %d", tt.code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestMatchTOTPSkew(t *testing.T) {
+	key := []byte("12345678901234567890")
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / 30
+
+	tests := []struct {
+		name   string
+		offset int64
+		want   bool
+	}{
+		{"two steps early", -2, false},
+		{"one step early", -1, true},
+		{"current step", 0, true},
+		{"one step late", 1, true},
+		{"two steps late", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := MatchTOTP(rfcSecret, totpCode(key, current+tt.offset), now)
+			if ok != tt.want {
+				t.Fatalf("accepted = %v, want %v", ok, tt.want)
+			}
+			if ok && step != current+tt.offset {
+				t.Errorf("step = %d, want %d", step, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestMatchTOTPRejectsMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "94287082"} {
+		if _, ok := MatchTOTP(rfcSecret, code, now); ok {
+			t.Errorf("MatchTOTP(%q) accepted", code)
+		}
+	}
+	if _, ok := MatchTOTP("not base32!", "287082", now); ok {
+		t.Error("MatchTOTP accepted a malformed secret")
+	}
+}
+
+func TestUseTOTPStepRejectsReuse(t *testing.T) {
+	dbtest.Use(t)
+	current := time.Now().Unix() / 30
+	userID := enrolUser(t, "alice", current-2, nil)
+
+	tests := []struct {
+		name string
+		step int64
+		want bool
+	}{
+		{"newer step", current, true},
+		{"same step again", current, false},
+		{"older step", current - 1, false},
+		{"next step", current + 1, true},
+	}
+	for _, tt := range tests {
+		used, err := db.UseTOTPStep(db.DB, userID, tt.step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != tt.want {
+			t.Errorf("%s: UseTOTPStep = %v, want %v", tt.name, used, tt.want)
+		}
+	}
+
+	// A code replayed within its window is turned down the second time
+	code := totpCode([]byte("12345678901234567890"), current+1)
+	if ok, err := CheckSecondFactor(userID, code); err != nil || ok {
+		t.Errorf("CheckSecondFactor with a used step = %v, %v; want false", ok, err)
+	}
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	dbtest.Use(t)
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	userID := enrolUser(t, "bob", 0, hashes)
+
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"as shown", codes[0], true},
+		{"used again", codes[0], false},
+		{"in capitals without the dash", " " + strings.ToUpper(strings.Replace(codes[1], "-", "", 1)) + " ", true},
+		{"unknown code", "aaaaa-bbbbb", false},
+	}
+	for _, tt := range tests {
+		ok, err := CheckSecondFactor(userID, tt.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("%s: CheckSecondFactor = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+
+	left, err := db.CountRecoveryCodes(db.DB, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := RecoveryCodeCount - 2; left != want {
+		t.Errorf("%d recovery codes left, want %d", left, want)
+	}
+}
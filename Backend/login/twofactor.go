@@ -0,0 +1,241 @@
+package login
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/security"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TwoFactorMessage asks for the second factor after a correct password
+const TwoFactorMessage = "Enter the code from your authenticator app, or one of your recovery codes"
+
+// InvalidCodeMessage is shown for a wrong, reused or expired code
+const InvalidCodeMessage = "Invalid authentication code"
+
+// ChallengeLifetime is how long the second login step waits for a code
+const ChallengeLifetime = 5 * time.Minute
+
+// maxChallengeAttempts is how many codes one challenge accepts before the
+// password has to be entered again. Failed codes also count towards the
+// account lockout.
+const maxChallengeAttempts = 5
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time
+const RecoveryCodeCount = 10
+
+// challengeCookie carries the pending second login step
+const challengeCookie = "login_challenge"
+
+// VerifySecondFactor finishes a login that needs two factors, once
+// Authenticate accepted the password. code is a TOTP code or a recovery
+// code. Failed codes count as failed logins, so lockouts apply to them too.
+func VerifySecondFactor(user *db.User, identifier, code, ip string) (*LoginResult, error) {
+	identifier = normalizeIdentifier(identifier)
+	if locked, err := checkLockout(&user.ID, identifier, ip); locked != nil || err != nil {
+		return locked, err
+	}
+
+	ok, err := CheckSecondFactor(user.ID, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := db.RecordLoginAttempt(db.DB, &user.ID, identifier, ip, false); err != nil {
+			return nil, err
+		}
+		return &LoginResult{Reason: FailInvalidCode, Message: InvalidCodeMessage}, nil
+	}
+
+	// A ban may have started since the password was checked
+	banMsg, err := ActiveBanMessage(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if banMsg != "" {
+		return &LoginResult{Reason: FailBanned, Message: banMsg}, nil
+	}
+
+	return completeLogin(user, identifier, ip)
+}
+
+// CheckSecondFactor checks a TOTP code or a recovery code for a user with
+// two-factor authentication enabled. Accepted codes are used up.
+func CheckSecondFactor(userID int, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, nil
+	}
+
+	if isDigits(code) {
+		totp, err := db.GetTOTP(db.DB, userID)
+		if err != nil || totp == nil || !totp.Enabled {
+			return false, err
+		}
+		step, ok := MatchTOTP(totp.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return db.UseTOTPStep(db.DB, userID, step)
+	}
+
+	return db.UseRecoveryCode(db.DB, userID, HashToken(normalizeRecoveryCode(code)))
+}
+
+// NewRecoveryCodes returns a fresh set of recovery codes to show the user
+// once, and the hashes to store for them
+func NewRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes typed without the dash or in capitals
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// startChallenge remembers a login that passed the password check and sends
+// the browser to the second step
+func startChallenge(w http.ResponseWriter, r *http.Request, user *db.User, identifier string, remember bool) error {
+	token, err := GenerateToken()
+	if err != nil {
+		return err
+	}
+	expiration := time.Now().UTC().Add(ChallengeLifetime)
+	if err := db.CreateLoginChallenge(db.DB, HashToken(token), user.ID, normalizeIdentifier(identifier), remember, expiration); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     challengeCookie,
+		Value:    token,
+		Path:     "/login",
+		Expires:  expiration,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+	return nil
+}
+
+// clearChallengeCookie expires the challenge cookie in the browser
+func clearChallengeCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     challengeCookie,
+		Value:    "",
+		Path:     "/login",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+}
+
+// TwoFactorLoginHandler is the second login step for accounts with
+// two-factor authentication. The session is only started once a code from
+// the authenticator app or a recovery code is accepted.
+func TwoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+	tmpl, err := template.New("twofactor_login.html").Funcs(security.Funcs(r)).ParseFiles("templates/twofactor_login.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+
+	// Without a pending challenge the password has to be entered (again)
+	var challenge *db.LoginChallenge
+	if cookie, err := r.Cookie(challengeCookie); err == nil {
+		challenge, err = db.GetLoginChallenge(db.DB, HashToken(cookie.Value))
+		if err != nil {
+			errors.InternalServerError(w, r, "Database error: "+err.Error())
+			return
+		}
+	}
+	if challenge == nil {
+		clearChallengeCookie(w)
+		http.Redirect(w, r, "/login?expired=1", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		tmpl.Execute(w, map[string]string{})
+		return
+	}
+
+	if err := db.AddLoginChallengeAttempt(db.DB, challenge.ID); err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if challenge.Attempts+1 > maxChallengeAttempts {
+		db.DeleteLoginChallenge(db.DB, challenge.ID)
+		clearChallengeCookie(w)
+		http.Redirect(w, r, "/login?expired=1", http.StatusSeeOther)
+		return
+	}
+
+	user, err := db.GetUserByID(db.DB, challenge.UserID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	result, err := VerifySecondFactor(user, challenge.Identifier, r.FormValue("code"), ClientIP(r))
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking code: "+err.Error())
+		return
+	}
+	if result.User == nil {
+		switch result.Reason {
+		case FailLocked:
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.Wait.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+		case FailBanned:
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		tmpl.Execute(w, map[string]string{"Error": result.Message})
+		return
+	}
+
+	if err := db.DeleteLoginChallenge(db.DB, challenge.ID); err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	clearChallengeCookie(w)
+	if _, _, err := StartSession(w, r, user.ID, challenge.Remember); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		tmpl.Execute(w, map[string]string{"Error": "Failed to create session: " + err.Error()})
+		return
+	}
+
+	if len(result.Failures) > 0 {
+		http.Redirect(w, r, "/homePage?failed_logins=1", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/homePage", http.StatusSeeOther)
+}
@@ -0,0 +1,26 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer stands in for SMTP during development: each message is written
+// to its own .eml file in Dir and announced on stdout
+type FileMailer struct {
+	Dir string
+}
+
+func (m FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	name := filepath.Join(m.Dir, fmt.Sprintf("%d.eml", time.Now().UnixNano()))
+	if err := os.WriteFile(name, format("forum@localhost", msg), 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("Mail to %s (%q) written to %s\n", msg.To, msg.Subject, name)
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package mailer sends the forum's emails. Production uses SMTP; during
+// development messages are written to files instead.
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default delivers everything sent with Send. Configure replaces it.
+var Default Mailer = FileMailer{Dir: "mail"}
+
+// BaseURL is the public address of the site, used for links in emails.
+// It is configured rather than taken from requests, whose Host header an
+// attacker controls.
+var BaseURL = "http://localhost:8888"
+
+// Configure picks the mailer from the environment: SMTP when
+// FORUM_SMTP_ADDR is set, files in FORUM_MAIL_DIR (default "mail")
+// otherwise. FORUM_BASE_URL overrides BaseURL.
+func Configure() {
+	if base := os.Getenv("FORUM_BASE_URL"); base != "" {
+		BaseURL = strings.TrimSuffix(base, "/")
+	}
+	if addr := os.Getenv("FORUM_SMTP_ADDR"); addr != "" {
+		Default = SMTPMailer{
+			Addr:     addr,
+			From:     os.Getenv("FORUM_SMTP_FROM"),
+			Username: os.Getenv("FORUM_SMTP_USER"),
+			Password: os.Getenv("FORUM_SMTP_PASSWORD"),
+		}
+		return
+	}
+	if dir := os.Getenv("FORUM_MAIL_DIR"); dir != "" {
+		Default = FileMailer{Dir: dir}
+	}
+}
+
+// Send delivers a message with the Default mailer
+func Send(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("mailer: header contains a line break")
+	}
+	return Default.Send(msg)
+}
+
+// Link returns the absolute URL of a path on the site
+func Link(path string) string {
+	return BaseURL + path
+}
@@ -0,0 +1,301 @@
+package oauth_test
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"forum/Backend/login"
+	"forum/Backend/mailer"
+	"forum/Backend/oauth"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newForum gives the test an empty, migrated database and registers a mock
+// provider to log in with
+func newForum(t *testing.T) *oauth.MockServer {
+	t.Helper()
+	dbtest.Use(t)
+	mailer.BaseURL = "http://forum.test"
+
+	m := startMock(t)
+	oauth.Register(m.Provider())
+	return m
+}
+
+// browser keeps the forum's cookies between requests, like a browser would
+type browser struct {
+	cookies map[string]*http.Cookie
+}
+
+func newBrowser() *browser {
+	return &browser{cookies: map[string]*http.Cookie{}}
+}
+
+// do sends a request to a forum handler and stores the cookies it sets
+func (b *browser) do(handler http.HandlerFunc, req *http.Request) *http.Response {
+	for _, c := range b.cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	resp := rec.Result()
+	for _, c := range resp.Cookies() {
+		if c.MaxAge < 0 {
+			delete(b.cookies, c.Name)
+		} else {
+			b.cookies[c.Name] = c
+		}
+	}
+	return resp
+}
+
+// start begins a sign-in at the mock provider and returns its URL. With
+// link, the logged-in user links the provider instead of logging in.
+func (b *browser) start(t *testing.T, link bool) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/oauth/start?provider=mock", nil)
+	if link {
+		req = httptest.NewRequest(http.MethodPost, "/oauth/start", strings.NewReader("provider=mock&link=1"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp := b.do(login.OAuthStartHandler, req)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("start answered %s", resp.Status)
+	}
+	return resp.Header.Get("Location")
+}
+
+// callback returns to the forum from the provider and returns where the
+// forum sends the browser next
+func (b *browser) callback(t *testing.T, back *url.URL) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/oauth/callback?"+back.RawQuery, nil)
+	resp := b.do(login.OAuthCallbackHandler, req)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("callback answered %s", resp.Status)
+	}
+	return resp.Header.Get("Location")
+}
+
+// signIn goes through the whole sign-in as the person described by claims
+func (b *browser) signIn(t *testing.T, link bool, claims url.Values) string {
+	t.Helper()
+	return b.callback(t, signInAt(t, b.start(t, link), claims))
+}
+
+// loggedInAs returns the user ID of the browser's session, 0 for guests
+func (b *browser) loggedInAs(t *testing.T) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range b.cookies {
+		req.AddCookie(c)
+	}
+	session, err := login.GetSessionFromRequest(req)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		return 0
+	}
+	return *session.UserID
+}
+
+// createUser registers a password account, with its email confirmed if verified
+func createUser(t *testing.T, username, email string, verified bool) int {
+	t.Helper()
+	id := dbtest.CreateUser(t, db.DB, username)
+	if _, err := db.DB.Exec(`UPDATE users SET email = ? WHERE id = ?`, email, id); err != nil {
+		t.Fatal(err)
+	}
+	if verified {
+		if _, err := db.DB.Exec(`UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id
+}
+
+// logIn gives the browser a session for the user, as a password login would
+func (b *browser) logIn(t *testing.T, userID int) {
+	t.Helper()
+	cookie := dbtest.SessionCookie(t, db.DB, userID)
+	b.cookies[cookie.Name] = cookie
+}
+
+// linkedUser returns who the mock provider's subject is linked to, 0 for no one
+func linkedUser(t *testing.T, subject string) int {
+	t.Helper()
+	identity, err := db.GetIdentity(db.DB, "mock", subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity == nil {
+		return 0
+	}
+	return identity.UserID
+}
+
+func TestLoginCreatesAccount(t *testing.T) {
+	newForum(t)
+	b := newBrowser()
+
+	if next := b.signIn(t, false, player("sub-1", "player@example.com")); next != "/homePage" {
+		t.Fatalf("redirected to %q, want /homePage", next)
+	}
+	userID := b.loggedInAs(t)
+	if userID == 0 {
+		t.Fatal("not logged in after signing in")
+	}
+	if linked := linkedUser(t, "sub-1"); linked != userID {
+		t.Errorf("identity linked to user %d, want %d", linked, userID)
+	}
+	user, err := db.GetUserByID(db.DB, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "MockPlayer" || user.Email != "player@example.com" || !user.EmailVerified {
+		t.Errorf("new user = %q <%s> verified=%v", user.Username, user.Email, user.EmailVerified)
+	}
+
+	// Signing in again logs in to the same account
+	again := newBrowser()
+	again.signIn(t, false, player("sub-1", "player@example.com"))
+	if got := again.loggedInAs(t); got != userID {
+		t.Errorf("second sign-in logged in as user %d, want %d", got, userID)
+	}
+}
+
+func TestLoginLinksAccountWithVerifiedEmail(t *testing.T) {
+	newForum(t)
+	userID := createUser(t, "alice", "alice@example.com", true)
+	b := newBrowser()
+
+	b.signIn(t, false, player("sub-1", "alice@example.com"))
+	if got := b.loggedInAs(t); got != userID {
+		t.Fatalf("logged in as user %d, want %d", got, userID)
+	}
+	if linked := linkedUser(t, "sub-1"); linked != userID {
+		t.Errorf("identity linked to user %d, want %d", linked, userID)
+	}
+}
+
+func TestLoginDoesNotLinkUnverifiedEmail(t *testing.T) {
+	newForum(t)
+
+	// The account's owner never confirmed the address
+	createUser(t, "alice", "alice@example.com", false)
+	b := newBrowser()
+	if next := b.signIn(t, false, player("sub-1", "alice@example.com")); next != "/login?oauth=unverified" {
+		t.Errorf("redirected to %q, want /login?oauth=unverified", next)
+	}
+	if b.loggedInAs(t) != 0 || linkedUser(t, "sub-1") != 0 {
+		t.Error("signed in to an account whose email is unverified")
+	}
+
+	// The provider doesn't vouch for the address
+	createUser(t, "bob", "bob@example.com", true)
+	claims := player("sub-2", "bob@example.com")
+	claims.Del("email_verified")
+	b = newBrowser()
+	if next := b.signIn(t, false, claims); next != "/login?oauth=no_email" {
+		t.Errorf("redirected to %q, want /login?oauth=no_email", next)
+	}
+	if b.loggedInAs(t) != 0 || linkedUser(t, "sub-2") != 0 {
+		t.Error("signed in with an email the provider did not verify")
+	}
+}
+
+func TestLinkProviderToAccount(t *testing.T) {
+	newForum(t)
+	aliceID := createUser(t, "alice", "alice@example.com", true)
+	b := newBrowser()
+	b.logIn(t, aliceID)
+
+	// The provider account's email doesn't need to match
+	if next := b.signIn(t, true, player("sub-1", "gamer@example.com")); next != "/profile/connections?linked=1" {
+		t.Fatalf("redirected to %q, want /profile/connections?linked=1", next)
+	}
+	if linked := linkedUser(t, "sub-1"); linked != aliceID {
+		t.Fatalf("identity linked to user %d, want %d", linked, aliceID)
+	}
+
+	// The linked provider now logs in to alice's account
+	other := newBrowser()
+	other.signIn(t, false, player("sub-1", "gamer@example.com"))
+	if got := other.loggedInAs(t); got != aliceID {
+		t.Errorf("logged in as user %d, want %d", got, aliceID)
+	}
+
+	// Nobody else can link it
+	bobID := createUser(t, "bob", "bob@example.com", true)
+	bob := newBrowser()
+	bob.logIn(t, bobID)
+	if next := bob.signIn(t, true, player("sub-1", "gamer@example.com")); next != "/profile/connections?error=taken" {
+		t.Errorf("redirected to %q, want /profile/connections?error=taken", next)
+	}
+	if linked := linkedUser(t, "sub-1"); linked != aliceID {
+		t.Errorf("identity moved to user %d, want %d", linked, aliceID)
+	}
+}
+
+func TestCallbackRejectsBadState(t *testing.T) {
+	newForum(t)
+	b := newBrowser()
+	back := signInAt(t, b.start(t, false), player("sub-1", "player@example.com"))
+
+	// A state that isn't the one this browser started with
+	forged := *back
+	q := forged.Query()
+	q.Set("state", "forged")
+	forged.RawQuery = q.Encode()
+	if next := b.callback(t, &forged); next != "/login?oauth=failed" {
+		t.Errorf("forged state: redirected to %q, want /login?oauth=failed", next)
+	}
+
+	// A callback in a browser that never started the sign-in
+	if next := newBrowser().callback(t, back); next != "/login?oauth=failed" {
+		t.Errorf("other browser: redirected to %q, want /login?oauth=failed", next)
+	}
+	if b.loggedInAs(t) != 0 || linkedUser(t, "sub-1") != 0 {
+		t.Error("signed in without the right state")
+	}
+}
+
+func TestCallbackRejectsReusedState(t *testing.T) {
+	newForum(t)
+	b := newBrowser()
+	start := b.start(t, false)
+	stateCookie := b.cookies["oauth_state"]
+	back := signInAt(t, start, player("sub-1", "player@example.com"))
+	b.callback(t, back)
+
+	// Replaying the callback with the same state cookie fails
+	replay := newBrowser()
+	replay.cookies["oauth_state"] = stateCookie
+	if next := replay.callback(t, back); next != "/login?oauth=failed" {
+		t.Errorf("redirected to %q, want /login?oauth=failed", next)
+	}
+	if replay.loggedInAs(t) != 0 {
+		t.Error("replayed callback logged in")
+	}
+}
+
+func TestCallbackRejectsBadNonce(t *testing.T) {
+	newForum(t)
+	b := newBrowser()
+	start, err := url.Parse(b.start(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider returns an ID token issued for another sign-in
+	q := start.Query()
+	q.Set("nonce", "nonce-of-another-sign-in")
+	start.RawQuery = q.Encode()
+	if next := b.callback(t, signInAt(t, start.String(), player("sub-1", "player@example.com"))); next != "/login?oauth=failed" {
+		t.Errorf("redirected to %q, want /login?oauth=failed", next)
+	}
+	if b.loggedInAs(t) != 0 || linkedUser(t, "sub-1") != 0 {
+		t.Error("signed in with an ID token for another sign-in")
+	}
+}
@@ -0,0 +1,267 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mock client credentials, registered together with the mock server
+const (
+	MockClientID     = "forum"
+	MockClientSecret = "mock-secret"
+)
+
+// mockCodeLifetime is how long an authorization code from the mock server works
+const mockCodeLifetime = time.Minute
+
+// MockServer is a minimal OpenID Connect provider for development and for
+// trying the login flow without registering an app anywhere. Its sign-in
+// page lets you claim any email address, so never run it in production.
+type MockServer struct {
+	Issuer string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+// mockGrant is an issued authorization code and what it is for
+type mockGrant struct {
+	Claims        map[string]interface{}
+	RedirectURI   string
+	CodeChallenge string
+	Expires       time.Time
+}
+
+var mockSignInPage = template.Must(template.New("mock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>Mock OpenID Provider</title></head>
+<body style="font-family: sans-serif; max-width: 420px; margin: 40px auto">
+  <h2>Mock OpenID Provider</h2>
+  <p>Development only. Sign in as anyone:</p>
+  <form method="POST">
+    {{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">{{end}}
+    <p><label>Subject <input name="sub" value="mock-user-1" required></label></p>
+    <p><label>Email <input name="email" value="player@example.com"></label></p>
+    <p><label><input type="checkbox" name="email_verified" checked> Email is verified</label></p>
+    <p><label>Name <input name="name" value="Mock Player"></label></p>
+    <button type="submit">Sign in</button>
+  </form>
+</body>
+</html>
+`))
+
+// NewMockServer creates a mock provider that will be reachable at issuer
+func NewMockServer(issuer string) (*MockServer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &MockServer{
+		Issuer: strings.TrimSuffix(issuer, "/"),
+		key:    key,
+		codes:  map[string]mockGrant{},
+	}, nil
+}
+
+// Provider returns the client side of the mock server, to Register it
+func (m *MockServer) Provider() *OIDCProvider {
+	return &OIDCProvider{
+		ID:           "mock",
+		Title:        "Mock OpenID (development)",
+		Issuer:       m.Issuer,
+		ClientID:     MockClientID,
+		ClientSecret: MockClientSecret,
+	}
+}
+
+// StartMockServer registers the mock provider and serves it on addr
+// (host:port), e.g. FORUM_OAUTH_MOCK_ADDR=localhost:8889, in the background
+func StartMockServer(addr string) error {
+	m, err := NewMockServer("http://" + addr)
+	if err != nil {
+		return err
+	}
+	Register(m.Provider())
+	fmt.Println("⚠️  Mock OpenID provider on", m.Issuer, "- anyone can sign in as anyone; development only")
+	go func() {
+		if err := http.ListenAndServe(addr, m); err != nil {
+			fmt.Println("Mock OpenID provider stopped:", err)
+		}
+	}()
+	return nil
+}
+
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                m.Issuer,
+			"authorization_endpoint":                m.Issuer + "/authorize",
+			"token_endpoint":                        m.Issuer + "/token",
+			"jwks_uri":                              m.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorize shows the sign-in form on GET and redirects back with a code on POST
+func (m *MockServer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if r.Method == http.MethodPost {
+		r.ParseForm()
+		q = r.PostForm
+	}
+	if q.Get("client_id") != MockClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "unknown client, or not a code flow with PKCE S256", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		params := map[string]string{}
+		for _, k := range []string{"client_id", "response_type", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			params[k] = q.Get(k)
+		}
+		mockSignInPage.Execute(w, map[string]interface{}{"Params": params})
+		return
+	}
+
+	code := randomHex(16)
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		Claims: map[string]interface{}{
+			"sub":            q.Get("sub"),
+			"email":          q.Get("email"),
+			"email_verified": q.Get("email_verified") == "on",
+			"name":           q.Get("name"),
+			"nonce":          q.Get("nonce"),
+		},
+		RedirectURI:   q.Get("redirect_uri"),
+		CodeChallenge: q.Get("code_challenge"),
+		Expires:       time.Now().Add(mockCodeLifetime),
+	}
+	m.mu.Unlock()
+
+	back := redirectURI.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirectURI.RawQuery = back.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token redeems a code once, checking the client, redirect URI and PKCE verifier
+func (m *MockServer) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+	if id != MockClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(MockClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.FormValue("code")
+	m.mu.Lock()
+	grant, found := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+	switch {
+	case r.FormValue("grant_type") != "authorization_code":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	case !found || time.Now().After(grant.Expires) || grant.RedirectURI != r.FormValue("redirect_uri"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case codeChallenge(r.FormValue("code_verifier")) != grant.CodeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := grant.Claims
+	claims["iss"] = m.Issuer
+	claims["aud"] = MockClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	idToken, err := m.sign(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomHex(16),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// sign makes an RS256 JWT
+func (m *MockServer) sign(claims map[string]interface{}) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "mock"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,350 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is how far the provider's clock may be off from ours
+const clockSkew = time.Minute
+
+// OIDCProvider is an OpenID Connect provider found through its discovery
+// document, such as Google, GitLab or Keycloak. Sign-in uses the
+// authorization code flow with PKCE, and the ID token's signature and
+// claims are checked.
+type OIDCProvider struct {
+	ID           string
+	Title        string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Client makes the requests to the provider; nil means a client with a 10s timeout
+	Client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]*rsa.PublicKey
+}
+
+// discovery is the part of /.well-known/openid-configuration we use
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims are the ID token claims we check or use
+type idTokenClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	Expiry            int64    `json:"exp"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+func (p *OIDCProvider) Name() string  { return p.ID }
+func (p *OIDCProvider) Label() string { return p.Title }
+
+// AuthURL sends the browser to the provider's authorization endpoint. If
+// the discovery document can't be loaded, it points at the issuer, whose
+// error page is the most useful thing to show.
+func (p *OIDCProvider) AuthURL(req AuthRequest) string {
+	d, err := p.getDiscovery(context.Background())
+	if err != nil {
+		fmt.Printf("OAuth provider %s: %v\n", p.ID, err)
+		return p.Issuer
+	}
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {req.RedirectURI},
+		"scope":                 {"openid email profile"},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {codeChallenge(req.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode()
+}
+
+// Exchange redeems the code at the token endpoint and checks the ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, req AuthRequest) (*Identity, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {req.RedirectURI},
+		"code_verifier": {req.Verifier},
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.doJSON(httpReq, &token); err != nil {
+		if token.Error != "" {
+			return nil, fmt.Errorf("token endpoint: %s %s", token.Error, token.ErrorDescription)
+		}
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, token.IDToken, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	name := claims.PreferredUsername
+	if name == "" {
+		name = claims.Name
+	}
+	return &Identity{
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: bool(claims.EmailVerified) && claims.Email != "",
+		Name:          name,
+	}, nil
+}
+
+// verifyIDToken checks the signature of an RS256 ID token and that it was
+// issued by this provider, for us, for this sign-in and is still valid
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, token, nonce string) (*idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id_token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("id_token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("id_token algorithm %q is not supported", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("id_token signature: %w", err)
+	}
+	key, err := p.getKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.New("id_token signature is invalid")
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("id_token claims: %w", err)
+	}
+	switch {
+	case claims.Issuer != p.Issuer:
+		return nil, fmt.Errorf("id_token issuer %q is not %q", claims.Issuer, p.Issuer)
+	case !claims.Audience.contains(p.ClientID):
+		return nil, errors.New("id_token is for another client")
+	case time.Now().After(time.Unix(claims.Expiry, 0).Add(clockSkew)):
+		return nil, errors.New("id_token has expired")
+	case claims.Nonce != nonce:
+		return nil, errors.New("id_token nonce does not match")
+	case claims.Subject == "":
+		return nil, errors.New("id_token has no subject")
+	}
+	return &claims, nil
+}
+
+// getDiscovery loads the discovery document once
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var d discovery
+	if err := p.doJSON(req, &d); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if d.Issuer != p.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q is not %q", d.Issuer, p.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery: endpoints are missing")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// getKey returns the signing key with the given ID. The key set is fetched
+// again when an unknown key shows up, since providers rotate their keys.
+func (p *OIDCProvider) getKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if pub, err := k.rsaKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("jwks: no key %q", kid)
+}
+
+// doJSON sends a request and decodes the JSON answer into v. v is also
+// filled for error statuses, which carry details in the body.
+func (p *OIDCProvider) doJSON(req *http.Request, v interface{}) error {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	jsonErr := json.Unmarshal(body, v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return jsonErr
+}
+
+// jwk is an RSA key from a JSON Web Key Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("key type %q", k.Kty)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(e)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("bad exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// audience is the "aud" claim, which is either a string or a list
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexBool accepts true and "true"; some providers send email_verified as a string
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	*b = flexBool(string(data) == "true" || string(data) == `"true"`)
+	return nil
+}
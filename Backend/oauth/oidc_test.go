@@ -0,0 +1,255 @@
+package oauth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"forum/Backend/oauth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testRedirectURI = "http://forum.test/oauth/callback"
+
+// startMock serves a mock provider for the length of the test
+func startMock(t *testing.T) *oauth.MockServer {
+	t.Helper()
+	var m *oauth.MockServer
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	m, err := oauth.NewMockServer(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// noRedirects is a client that returns redirects instead of following them
+var noRedirects = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// signInAt submits the mock's sign-in form for authURL as the person
+// described by claims and returns where the browser is sent back to
+func signInAt(t *testing.T, authURL string, claims url.Values) *url.URL {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := u.Query()
+	for k, v := range claims {
+		form[k] = v
+	}
+	u.RawQuery = ""
+
+	resp, err := noRedirects.PostForm(u.String(), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("mock sign-in answered %s", resp.Status)
+	}
+	back, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return back
+}
+
+func player(sub, email string) url.Values {
+	return url.Values{"sub": {sub}, "email": {email}, "email_verified": {"on"}, "name": {"Mock Player"}}
+}
+
+func newAuthRequest() oauth.AuthRequest {
+	return oauth.AuthRequest{State: "state-1", Nonce: "nonce-1", Verifier: "verifier-1", RedirectURI: testRedirectURI}
+}
+
+func TestExchangeReturnsIdentity(t *testing.T) {
+	p := startMock(t).Provider()
+	req := newAuthRequest()
+
+	back := signInAt(t, p.AuthURL(req), player("sub-1", "Player@Example.com"))
+	if got := back.Query().Get("state"); got != req.State {
+		t.Fatalf("state = %q, want %q", got, req.State)
+	}
+	identity, err := p.Exchange(context.Background(), back.Query().Get("code"), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := oauth.Identity{Subject: "sub-1", Email: "player@example.com", EmailVerified: true, Name: "Mock Player"}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestExchangeRejectsUnverifiedEmail(t *testing.T) {
+	p := startMock(t).Provider()
+	req := newAuthRequest()
+
+	claims := player("sub-1", "player@example.com")
+	claims.Del("email_verified")
+	back := signInAt(t, p.AuthURL(req), claims)
+	identity, err := p.Exchange(context.Background(), back.Query().Get("code"), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.EmailVerified {
+		t.Error("email is verified, want unverified")
+	}
+}
+
+func TestExchangeRejectsWrongNonce(t *testing.T) {
+	p := startMock(t).Provider()
+	req := newAuthRequest()
+
+	back := signInAt(t, p.AuthURL(req), player("sub-1", "player@example.com"))
+	req.Nonce = "nonce-of-another-sign-in"
+	_, err := p.Exchange(context.Background(), back.Query().Get("code"), req)
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("err = %v, want a nonce mismatch", err)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	p := startMock(t).Provider()
+	req := newAuthRequest()
+
+	back := signInAt(t, p.AuthURL(req), player("sub-1", "player@example.com"))
+	req.Verifier = "verifier-of-another-sign-in"
+	if _, err := p.Exchange(context.Background(), back.Query().Get("code"), req); err == nil {
+		t.Fatal("exchange succeeded with the wrong PKCE verifier")
+	}
+}
+
+func TestExchangeRejectsReusedCode(t *testing.T) {
+	p := startMock(t).Provider()
+	req := newAuthRequest()
+
+	back := signInAt(t, p.AuthURL(req), player("sub-1", "player@example.com"))
+	code := back.Query().Get("code")
+	if _, err := p.Exchange(context.Background(), code, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Exchange(context.Background(), code, req); err == nil {
+		t.Fatal("exchange succeeded twice with the same code")
+	}
+}
+
+// tamperingTransport rewrites the ID token of token responses
+type tamperingTransport struct {
+	tamper func(idToken string) string
+}
+
+func (tt tamperingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || !strings.HasSuffix(req.URL.Path, "/token") {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if token, ok := body["id_token"].(string); ok {
+		body["id_token"] = tt.tamper(token)
+	}
+	data, _ := json.Marshal(body)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+func TestExchangeRejectsBadSignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(parts []string) []string
+	}{
+		{"changed claims", func(parts []string) []string {
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			claims = bytes.Replace(claims, []byte(`"sub-1"`), []byte(`"sub-2"`), 1)
+			parts[1] = base64.RawURLEncoding.EncodeToString(claims)
+			return parts
+		}},
+		{"changed signature", func(parts []string) []string {
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			sig[0] ^= 0xff
+			parts[2] = base64.RawURLEncoding.EncodeToString(sig)
+			return parts
+		}},
+		{"no signature", func(parts []string) []string {
+			parts[2] = ""
+			return parts
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := startMock(t).Provider()
+			p.Client = &http.Client{Transport: tamperingTransport{func(token string) string {
+				return strings.Join(tt.tamper(strings.Split(token, ".")), ".")
+			}}}
+			req := newAuthRequest()
+
+			back := signInAt(t, p.AuthURL(req), player("sub-1", "player@example.com"))
+			_, err := p.Exchange(context.Background(), back.Query().Get("code"), req)
+			if err == nil || !strings.Contains(err.Error(), "signature") {
+				t.Fatalf("err = %v, want an invalid signature", err)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsTokenSignedByAnotherKey(t *testing.T) {
+	m := startMock(t)
+	p := m.Provider()
+	// Another provider claiming the same issuer signs with its own key
+	impostor, err := oauth.NewMockServer(m.Issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostorSrv := httptest.NewServer(impostor)
+	t.Cleanup(impostorSrv.Close)
+	req := newAuthRequest()
+
+	authURL := strings.Replace(p.AuthURL(req), m.Issuer, impostorSrv.URL, 1)
+	back := signInAt(t, authURL, player("sub-1", "player@example.com"))
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {back.Query().Get("code")},
+		"redirect_uri":  {req.RedirectURI},
+		"code_verifier": {req.Verifier},
+		"client_id":     {oauth.MockClientID},
+		"client_secret": {oauth.MockClientSecret},
+	}
+	resp, err := http.PostForm(impostorSrv.URL+"/token", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&token)
+	resp.Body.Close()
+	if token.IDToken == "" {
+		t.Fatalf("impostor answered %s without an ID token", resp.Status)
+	}
+
+	// The real provider's code is swapped for the impostor's token in transit
+	p.Client = &http.Client{Transport: tamperingTransport{func(string) string { return token.IDToken }}}
+	back = signInAt(t, p.AuthURL(req), player("sub-1", "player@example.com"))
+	_, err = p.Exchange(context.Background(), back.Query().Get("code"), req)
+	if err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("err = %v, want an invalid signature", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Package oauth lets people log in with accounts they have elsewhere,
+// through OAuth 2.0 / OpenID Connect providers. It only talks to the
+// providers; the login package turns the result into a forum session.
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Identity is what a provider says about the person who signed in
+type Identity struct {
+	// Subject is the provider's stable ID for the account
+	Subject string
+	Email   string
+	// EmailVerified is true only if the provider vouches for the address
+	EmailVerified bool
+	// Name is a suggestion for the username of a new account
+	Name string
+}
+
+// AuthRequest is one sign-in at a provider. The same values are needed to
+// send the user there and to finish the sign-in when they come back.
+type AuthRequest struct {
+	State       string
+	Nonce       string
+	Verifier    string // PKCE code verifier
+	RedirectURI string
+}
+
+// Provider is an identity provider people can log in with
+type Provider interface {
+	// Name identifies the provider in URLs and linked identities. It must
+	// not change once accounts are linked.
+	Name() string
+	// Label is shown on the login button
+	Label() string
+	// AuthURL is where to send the browser to sign in
+	AuthURL(req AuthRequest) string
+	// Exchange trades the code the provider sent back for the identity
+	Exchange(ctx context.Context, code string, req AuthRequest) (*Identity, error)
+}
+
+var providers = map[string]Provider{}
+
+// Register makes a provider available on the login page, replacing one
+// with the same name
+func Register(p Provider) {
+	providers[p.Name()] = p
+}
+
+// Get returns the provider with the given name, or nil
+func Get(name string) Provider {
+	return providers[name]
+}
+
+// Providers lists the registered providers by name
+func Providers() []Provider {
+	list := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// Configure registers the OpenID Connect providers listed in
+// FORUM_OAUTH_PROVIDERS (comma-separated names). Each name is configured by
+// FORUM_OAUTH_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and optionally
+// _LABEL. Providers with missing settings are skipped with a warning.
+func Configure() {
+	for _, name := range strings.Split(os.Getenv("FORUM_OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "FORUM_OAUTH_" + strings.ToUpper(name) + "_"
+		p := &OIDCProvider{
+			ID:           name,
+			Title:        os.Getenv(prefix + "LABEL"),
+			Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			fmt.Printf("OAuth provider %q needs %sISSUER and %sCLIENT_ID, skipping it\n", name, prefix, prefix)
+			continue
+		}
+		if p.Title == "" {
+			p.Title = strings.ToUpper(name[:1]) + name[1:]
+		}
+		Register(p)
+	}
+}
+
+// codeChallenge is the PKCE S256 challenge for a verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,33 @@
+package permissions
+
+import (
+	"database/sql"
+	"fmt"
+	db "forum/Backend/DB"
+)
+
+const usage = "usage: forum role <username> user|moderator|admin"
+
+// RunCommand handles the "forum role ..." subcommand, used to appoint the
+// first admin before anyone can reach the admin page
+func RunCommand(conn *sql.DB, args []string) error {
+	if len(args) != 2 || !ValidRole(args[1]) {
+		return fmt.Errorf(usage)
+	}
+
+	userID, err := db.GetUserIDByUsername(conn, args[0])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no user named %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	// Actor 0 marks the command line in the moderation log
+	entry := db.AuditEntry{ActorID: 0, Action: "user.role", TargetType: "user", TargetID: userID, Reason: "set from the command line"}
+	if err := db.Audited(conn, entry, func(q db.Querier) error { return db.SetUserRole(q, userID, args[1]) }); err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", args[0], args[1])
+	return nil
+}
@@ -0,0 +1,194 @@
+package permissions
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/login"
+)
+
+// Site-wide roles
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every role, lowest first
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// Action is something a handler asks permission for
+type Action string
+
+const (
+	CreatePost    Action = "create_post"
+	Comment       Action = "comment"
+	Vote          Action = "vote"
+	EditPost      Action = "edit_post"      // edit a post written by someone else
+	DeletePost    Action = "delete_post"    // delete a post written by someone else
+	DeleteComment Action = "delete_comment" // delete a comment written by someone else
+	HideContent   Action = "hide_content"   // hide posts and comments and see hidden ones
+	ReviewReports Action = "review_reports"
+	ManageRoles   Action = "manage_roles"
+	BanUsers      Action = "ban_users" // ban, suspend and shadow-ban accounts
+	ViewAuditLog  Action = "view_audit_log"
+	ManageFilters Action = "manage_filters" // edit the blocked word list
+)
+
+// roleActions lists what each site-wide role may do anywhere on the forum
+var roleActions = map[string][]Action{
+	RoleUser:      {CreatePost, Comment, Vote},
+	RoleModerator: {CreatePost, Comment, Vote, DeletePost, DeleteComment, HideContent, ReviewReports, BanUsers, ViewAuditLog},
+	RoleAdmin:     {CreatePost, Comment, Vote, DeletePost, DeleteComment, HideContent, ReviewReports, BanUsers, ViewAuditLog, EditPost, ManageRoles, ManageFilters},
+}
+
+// verifiedActions need a confirmed email address, so throwaway accounts
+// cannot post
+var verifiedActions = []Action{CreatePost, Comment}
+
+// categoryActions are granted to category moderators on content in their categories
+var categoryActions = []Action{DeletePost, DeleteComment, HideContent, ReviewReports}
+
+// ValidRole reports whether role is one of Roles
+func ValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the user may perform the action. categories are those of
+// the content being acted on, so category moderators get their rights there.
+// Banned and suspended users may do nothing, and unverified ones may not post.
+func Can(userID int, action Action, categories ...string) (bool, error) {
+	if ok, err := notBanned(userID); !ok {
+		return false, err
+	}
+	if hasAction(verifiedActions, action) {
+		verified, err := db.IsEmailVerified(db.DB, userID)
+		if err != nil || !verified {
+			return false, err
+		}
+	}
+
+	role, err := db.GetUserRole(db.DB, userID)
+	if err != nil {
+		return false, err
+	}
+	if hasAction(roleActions[role], action) {
+		return true, nil
+	}
+
+	if len(categories) == 0 || !hasAction(categoryActions, action) {
+		return false, nil
+	}
+	return db.IsCategoryModerator(db.DB, userID, categories)
+}
+
+// CanModify reports whether the user may apply action to content owned by
+// ownerID. Authors can change their own content unless they are banned or
+// suspended.
+func CanModify(userID, ownerID int, action Action, categories ...string) (bool, error) {
+	if userID == ownerID {
+		return notBanned(userID)
+	}
+	return Can(userID, action, categories...)
+}
+
+// CanModifyOnPost is CanModify for a post, or a comment on it, using the
+// post's categories
+func CanModifyOnPost(userID, ownerID, postID int, action Action) (bool, error) {
+	if userID == ownerID {
+		return notBanned(userID)
+	}
+	categories, err := db.GetPostCategories(db.DB, postID)
+	if err != nil {
+		return false, err
+	}
+	return Can(userID, action, categories...)
+}
+
+// Scope returns where the user may perform a category-scoped action: all
+// categories, or only the ones they moderate (possibly none)
+func Scope(userID int, action Action) (all bool, categories []string, err error) {
+	all, err = Can(userID, action)
+	if err != nil || all {
+		return all, nil, err
+	}
+	if !hasAction(categoryActions, action) {
+		return false, []string{}, nil
+	}
+	categories, err = db.GetModeratedCategories(db.DB, userID)
+	if categories == nil {
+		categories = []string{}
+	}
+	return false, categories, err
+}
+
+// IsStaff reports whether the user moderates anything, i.e. may use the
+// moderation dashboard
+func IsStaff(userID int) (bool, error) {
+	all, categories, err := Scope(userID, ReviewReports)
+	return all || len(categories) > 0, err
+}
+
+// Allowed is Can for a request session; guests are never allowed
+func Allowed(session *login.Session, action Action, categories ...string) (bool, error) {
+	if session == nil || session.IsGuest || session.UserID == nil {
+		return false, nil
+	}
+	return Can(*session.UserID, action, categories...)
+}
+
+// DeniedMessage explains why the user was refused an action, giving the ban
+// reason when there is one
+func DeniedMessage(userID int) string {
+	if msg, err := login.ActiveBanMessage(userID); err == nil && msg != "" {
+		return msg
+	}
+	if verified, err := db.IsEmailVerified(db.DB, userID); err == nil && !verified {
+		return "Verify your email address to post and comment. We can send the link again from your profile page."
+	}
+	return "You are not allowed to do that"
+}
+
+// Outranks reports whether actor may ban target: moderators can only
+// restrict regular users, admins anyone but other admins
+func Outranks(actorID, targetID int) (bool, error) {
+	if actorID == targetID {
+		return false, nil
+	}
+	actor, err := db.GetUserRole(db.DB, actorID)
+	if err != nil {
+		return false, err
+	}
+	target, err := db.GetUserRole(db.DB, targetID)
+	if err != nil {
+		return false, err
+	}
+	return target == RoleUser || (actor == RoleAdmin && target != RoleAdmin), nil
+}
+
+// DeniedMessageFor is DeniedMessage for a request session
+func DeniedMessageFor(session *login.Session) string {
+	if session == nil || session.IsGuest || session.UserID == nil {
+		return "You are not allowed to do that"
+	}
+	return DeniedMessage(*session.UserID)
+}
+
+// notBanned reports whether the user is free of bans and suspensions.
+// Shadow bans don't count: those users must not notice anything.
+func notBanned(userID int) (bool, error) {
+	ban, err := db.GetActiveBan(db.DB, userID)
+	return err == nil && ban == nil, err
+}
+
+func hasAction(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package permissions
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"testing"
+	"time"
+)
+
+func TestCanModifyRefusesBannedAuthors(t *testing.T) {
+	dbtest.Use(t)
+	modID := dbtest.CreateUser(t, db.DB, "mod")
+	later := time.Now().UTC().Add(time.Hour)
+	earlier := time.Now().UTC().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		kind    string
+		expires *time.Time
+		want    bool
+	}{
+		{"not restricted", "", nil, true},
+		{"banned", db.BanPermanent, nil, false},
+		{"suspended", db.BanSuspension, &later, false},
+		{"suspension over", db.BanSuspension, &earlier, true},
+		{"shadow-banned", db.BanShadow, nil, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := dbtest.CreateUser(t, db.DB, "author"+string(rune('a'+i)))
+			if tt.kind != "" {
+				if err := db.CreateBan(db.DB, userID, tt.kind, "spam", tt.expires, modID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			allowed, err := CanModify(userID, userID, EditPost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed != tt.want {
+				t.Errorf("CanModify own content = %v, want %v", allowed, tt.want)
+			}
+			allowed, err = CanModifyOnPost(userID, userID, 0, DeleteComment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed != tt.want {
+				t.Errorf("CanModifyOnPost own content = %v, want %v", allowed, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package posts
 
 import (
+	"fmt"
 	db "forum/Backend/DB"
 	"forum/Backend/errors"
 	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"forum/Backend/security"
 	"html/template"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,7 +18,7 @@ var categories = []string{"minecraft", "online games", "souls games", "general",
 
 // PostHandler handles GET and POST requests for creating posts
 func PostHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/createpost.html")
+	tmpl, err := template.New("createpost.html").Funcs(security.Funcs(r)).ParseFiles("templates/createpost.html")
 	if err != nil {
 		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
 		return
@@ -25,6 +29,9 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
+	if !requireAction(w, r, session, permissions.CreatePost) {
+		return
+	}
 
 	if r.Method == http.MethodGet {
 		tmpl.Execute(w, map[string]interface{}{"Categories": categories})
@@ -41,29 +48,87 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := strings.ReplaceAll(r.FormValue("title"), "\n", " ")
-	content := r.FormValue("content")
-	categoriesSelected := r.Form["category[]"]
-
-	if title == "" || content == "" || len(categoriesSelected) == 0 {
+	title, content, categoriesSelected, errMsg := validatePostForm(r)
+	if errMsg != "" {
 		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]interface{}{"Error": "Title, content, and at least one category required", "Categories": categories})
+		tmpl.Execute(w, map[string]interface{}{"Error": errMsg, "Categories": categories})
 		return
 	}
 
-	if len(title) > 50 || len(content) > 1000 {
+	userID, err := db.GetUserIDByUsername(db.DB, session.Username)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error finding user ID: "+err.Error())
+		return
+	}
+
+	postID, held, errMsg, err := PublishPost(userID, title, content, categoriesSelected)
+	if err != nil {
+		errors.InternalServerError(w, r, err.Error())
+		return
+	}
+	if errMsg != "" {
 		w.WriteHeader(http.StatusBadRequest)
-		tmpl.Execute(w, map[string]interface{}{"Error": "Too long title or content", "Categories": categories})
+		tmpl.Execute(w, map[string]interface{}{"Error": errMsg, "Categories": categories})
 		return
 	}
 
+	// Held posts are only visible to their author, so show it to them
+	if held {
+		http.Redirect(w, r, "/post?id="+strconv.Itoa(postID)+"&held=1", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/homePage", http.StatusSeeOther)
+}
+
+// savePost stores a validated post and links it to its categories
+func savePost(q db.Querier, userID int, title, content string, categoriesSelected []string) (int, error) {
+	postID, err := db.CreatePost(q, userID, title, content, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("Error saving post: %w", err)
+	}
+
+	for _, c := range categoriesSelected {
+		if err := db.EnsureCategory(q, c); err != nil {
+			return 0, fmt.Errorf("Error saving category: %w", err)
+		}
+		categoryID, err := db.GetCategoryID(q, c)
+		if err != nil {
+			return 0, fmt.Errorf("Error fetching category ID: %w", err)
+		}
+		if err := db.LinkPostToCategory(q, postID, categoryID); err != nil {
+			return 0, fmt.Errorf("Error linking post to category: %w", err)
+		}
+	}
+	return postID, nil
+}
+
+// validatePostForm reads and validates the title, content and categories of a post form.
+// A non-empty message is returned when the submission is invalid.
+func validatePostForm(r *http.Request) (string, string, []string, string) {
+	return ValidatePost(r.FormValue("title"), r.FormValue("content"), r.Form["category[]"])
+}
+
+// ValidatePost checks and normalizes a post's title, content and categories.
+// A non-empty message is returned when the post is invalid.
+func ValidatePost(title, content string, categoriesSelected []string) (string, string, []string, string) {
+	title = strings.ReplaceAll(title, "\n", " ")
+
+	if title == "" || content == "" || len(categoriesSelected) == 0 {
+		return "", "", nil, "Title, content, and at least one category required"
+	}
+
+	if len(title) > 50 || len(content) > 1000 {
+		return "", "", nil, "Too long title or content"
+	}
+
 	// Normalize categories
+	normalized := make([]string, len(categoriesSelected))
 	for i, c := range categoriesSelected {
-		categoriesSelected[i] = strings.ToLower(strings.TrimSpace(c))
+		normalized[i] = strings.ToLower(strings.TrimSpace(c))
 	}
 
 	// Validate categories
-	for _, c := range categoriesSelected {
+	for _, c := range normalized {
 		valid := false
 		for _, cat := range categories {
 			if c == cat {
@@ -72,39 +137,14 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if !valid {
-			w.WriteHeader(http.StatusBadRequest)
-			tmpl.Execute(w, map[string]interface{}{"Error": "Invalid category: " + c, "Categories": categories})
-			return
+			return "", "", nil, "Invalid category: " + c
 		}
 	}
 
-	userID, err := db.GetUserIDByUsername(db.DB, session.Username)
-	if err != nil {
-		errors.InternalServerError(w, r, "Error finding user ID: "+err.Error())
-		return
-	}
-
-	postID, err := db.CreatePost(db.DB, userID, title, content, time.Now())
-	if err != nil {
-		errors.InternalServerError(w, r, "Error saving post: "+err.Error())
-		return
-	}
-
-	for _, c := range categoriesSelected {
-		if err := db.EnsureCategory(db.DB, c); err != nil {
-			errors.InternalServerError(w, r, "Error saving category: "+err.Error())
-			return
-		}
-		categoryID, err := db.GetCategoryID(db.DB, c)
-		if err != nil {
-			errors.InternalServerError(w, r, "Error fetching category ID: "+err.Error())
-			return
-		}
-		if err := db.LinkPostToCategory(db.DB, postID, categoryID); err != nil {
-			errors.InternalServerError(w, r, "Error linking post to category: "+err.Error())
-			return
-		}
-	}
+	return title, content, normalized, ""
+}
 
-	http.Redirect(w, r, "/homePage", http.StatusSeeOther)
+// Categories returns the categories a post can be filed under
+func Categories() []string {
+	return append([]string(nil), categories...)
 }
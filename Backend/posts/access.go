@@ -0,0 +1,42 @@
+package posts
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"net/http"
+)
+
+// requireAction checks that the logged-in user may perform action, writing
+// a 403 page if not
+func requireAction(w http.ResponseWriter, r *http.Request, session *login.Session, action permissions.Action) bool {
+	allowed, err := permissions.Allowed(session, action)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+		return false
+	}
+	if !allowed {
+		errors.Forbidden(w, r, permissions.DeniedMessageFor(session))
+		return false
+	}
+	return true
+}
+
+// hiddenFrom reports whether p must not be shown to the viewer. Hidden posts
+// and those of shadow-banned users are only shown to their author and to
+// moderators who can hide content.
+func hiddenFrom(p *db.PostShow, viewerID int, canHide bool) bool {
+	return (p.Hidden || p.ShadowBanned) && !p.Deleted && !canHide && viewerID != p.UserID
+}
+
+// AuditedChange runs change on content owned by ownerID in a transaction.
+// When someone else makes the change it is a moderator action and goes to
+// the moderation log, in the same transaction.
+func AuditedChange(actorID, ownerID int, e db.AuditEntry, change func(q db.Querier) error) error {
+	if actorID == ownerID {
+		return db.Transaction(db.DB, change)
+	}
+	e.ActorID = actorID
+	return db.Audited(db.DB, e, change)
+}
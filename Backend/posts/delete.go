@@ -0,0 +1,110 @@
+package posts
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeletePostHandler handles POST /post/delete, soft-deleting a post for its author or a moderator
+func DeletePostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.FormValue("post_id"))
+	if err != nil || postID < 1 {
+		errors.BadRequest(w, r, "Invalid Post ID")
+		return
+	}
+
+	authorID, err := db.GetPostAuthorID(db.DB, postID)
+	if err == sql.ErrNoRows {
+		errors.NotFound(w, r, "Post not found")
+		return
+	}
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+	allowed, err := permissions.CanModifyOnPost(*session.UserID, authorID, postID, permissions.DeletePost)
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+	if !allowed {
+		errors.Forbidden(w, r, "You can only delete your own posts")
+		return
+	}
+
+	err = AuditedChange(*session.UserID, authorID, db.AuditEntry{Action: "post.delete", TargetType: "post", TargetID: postID}, func(q db.Querier) error {
+		return db.SoftDeletePost(q, postID, time.Now().UTC())
+	})
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error deleting post: "+err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/homePage", http.StatusSeeOther)
+}
+
+// DeleteCommentHandler handles POST /comment/delete, soft-deleting a comment for its author or a moderator
+func DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	commentID, err := strconv.Atoi(r.FormValue("comment_id"))
+	if err != nil || commentID < 1 {
+		errors.BadRequest(w, r, "Invalid Comment ID")
+		return
+	}
+
+	authorID, postID, err := db.GetCommentAuthor(db.DB, commentID)
+	if err == sql.ErrNoRows {
+		errors.NotFound(w, r, "Comment not found")
+		return
+	}
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+	allowed, err := permissions.CanModifyOnPost(*session.UserID, authorID, postID, permissions.DeleteComment)
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+	if !allowed {
+		errors.Forbidden(w, r, "You can only delete your own comments")
+		return
+	}
+
+	err = AuditedChange(*session.UserID, authorID, db.AuditEntry{Action: "comment.delete", TargetType: "comment", TargetID: commentID}, func(q db.Querier) error {
+		return db.SoftDeleteComment(q, commentID, time.Now().UTC())
+	})
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error deleting comment: "+err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/post?id="+strconv.Itoa(postID), http.StatusSeeOther)
+}
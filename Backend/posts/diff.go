@@ -0,0 +1,64 @@
+package posts
+
+import "strings"
+
+// DiffPart is a run of words that was kept, added or removed between two versions
+type DiffPart struct {
+	Text string
+	Op   string // "same", "add" or "del"
+}
+
+// diffWords computes a word-level diff of two texts using the longest common subsequence
+func diffWords(oldText, newText string) []DiffPart {
+	a := strings.Fields(oldText)
+	b := strings.Fields(newText)
+
+	// lcs[i][j] = length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var parts []DiffPart
+	push := func(op, word string) {
+		if n := len(parts); n > 0 && parts[n-1].Op == op {
+			parts[n-1].Text += " " + word
+			return
+		}
+		parts = append(parts, DiffPart{Text: word, Op: op})
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			push("same", a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			push("del", a[i])
+			i++
+		default:
+			push("add", b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		push("del", a[i])
+	}
+	for ; j < len(b); j++ {
+		push("add", b[j])
+	}
+	return parts
+}
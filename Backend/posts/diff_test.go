@@ -0,0 +1,35 @@
+package posts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []DiffPart
+	}{
+		{"unchanged", "the quick fox", "the quick fox", []DiffPart{{"the quick fox", "same"}}},
+		{"both empty", "", "", nil},
+		{"all added", "", "new text", []DiffPart{{"new text", "add"}}},
+		{"all removed", "old text", "", []DiffPart{{"old text", "del"}}},
+		{"word replaced", "the quick fox", "the slow fox", []DiffPart{
+			{"the", "same"}, {"quick", "del"}, {"slow", "add"}, {"fox", "same"},
+		}},
+		{"words inserted", "a d", "a b c d", []DiffPart{{"a", "same"}, {"b c", "add"}, {"d", "same"}}},
+		{"words removed at the end", "a b c", "a", []DiffPart{{"a", "same"}, {"b c", "del"}}},
+		{"whitespace ignored", "a  b\nc", "a b c", []DiffPart{{"a b c", "same"}}},
+		{"case matters", "Hello world", "hello world", []DiffPart{
+			{"Hello", "del"}, {"hello", "add"}, {"world", "same"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffWords(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffWords(%q, %q) = %v, want %v", tt.old, tt.new, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package posts
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// EditPostHandler handles GET and POST /post/edit?id=ID for the post author or an admin
+func EditPostHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.New("editpost.html").Funcs(security.Funcs(r)).ParseFiles("templates/editpost.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+
+	session, _ := login.GetSessionFromRequest(r)
+	if session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || postID < 1 {
+		errors.BadRequest(w, r, "Invalid Post ID")
+		return
+	}
+
+	post, err := db.GetPostWithCategories(db.DB, postID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			errors.NotFound(w, r, "Post not found")
+			return
+		}
+		errors.InternalServerError(w, r, "Error fetching post: "+err.Error())
+		return
+	}
+	if post.Deleted {
+		errors.NotFound(w, r, "Post not found")
+		return
+	}
+
+	allowed, err := permissions.CanModify(*session.UserID, post.UserID, permissions.EditPost, post.Categories...)
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+	if !allowed {
+		errors.Forbidden(w, r, "You can only edit your own posts")
+		return
+	}
+
+	selected := make(map[string]bool)
+	for _, c := range post.Categories {
+		selected[c] = true
+	}
+	data := map[string]interface{}{
+		"Post":       post,
+		"Categories": categories,
+		"Selected":   selected,
+	}
+
+	if r.Method == http.MethodGet {
+		tmpl.Execute(w, data)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		errors.InternalServerError(w, r, "Error parsing form: "+err.Error())
+		return
+	}
+
+	title, content, categoriesSelected, errMsg := validatePostForm(r)
+	if errMsg != "" {
+		data["Error"] = errMsg
+		w.WriteHeader(http.StatusBadRequest)
+		tmpl.Execute(w, data)
+		return
+	}
+
+	held, errMsg, err := RevisePost(*session.UserID, post, title, content, categoriesSelected)
+	if err != nil {
+		errors.InternalServerError(w, r, err.Error())
+		return
+	}
+	if errMsg != "" {
+		data["Error"] = errMsg
+		w.WriteHeader(http.StatusBadRequest)
+		tmpl.Execute(w, data)
+		return
+	}
+
+	if held {
+		http.Redirect(w, r, "/post?id="+strconv.Itoa(postID)+"&held=1", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/post?id="+strconv.Itoa(postID), http.StatusSeeOther)
+}
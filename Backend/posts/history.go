@@ -0,0 +1,150 @@
+package posts
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PostVersion is one version of a post as shown on the history page
+type PostVersion struct {
+	Number     int
+	Title      string
+	Content    string
+	Categories []string
+	EditedBy   string
+	EditedAt   string
+	Current    bool
+}
+
+// PostHistoryHandler handles GET /post/history?id=ID[&v=N[&compare=M]]
+func PostHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+
+	postID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || postID < 1 {
+		errors.BadRequest(w, r, "Invalid Post ID")
+		return
+	}
+
+	tmpl, err := template.ParseFiles("templates/history.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+
+	post, err := db.GetPostWithCategories(db.DB, postID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			errors.NotFound(w, r, "Post not found")
+			return
+		}
+		errors.InternalServerError(w, r, "Error fetching post: "+err.Error())
+		return
+	}
+	if post.Deleted {
+		errors.NotFound(w, r, "Post not found")
+		return
+	}
+	session, _ := login.GetSessionFromRequest(r)
+	viewerID := 0
+	if session != nil && session.UserID != nil {
+		viewerID = *session.UserID
+	}
+	canHide := false
+	if viewerID != 0 {
+		if canHide, err = permissions.Can(viewerID, permissions.HideContent, post.Categories...); err != nil {
+			errors.InternalServerError(w, r, "Error checking permissions: "+err.Error())
+			return
+		}
+	}
+	// Older versions are as private as the post itself
+	if hiddenFrom(post, viewerID, canHide) {
+		errors.NotFound(w, r, "Post not found")
+		return
+	}
+
+	revisions, err := db.GetPostRevisions(db.DB, postID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching revisions: "+err.Error())
+		return
+	}
+
+	versions := buildVersions(post, revisions)
+	data := map[string]interface{}{
+		"Post":     post,
+		"Versions": versions,
+	}
+
+	// Optional: show one version diffed against another (default: the one before it, 0 = no diff)
+	if vStr := r.URL.Query().Get("v"); vStr != "" {
+		v, err := strconv.Atoi(vStr)
+		if err != nil || v < 1 || v > len(versions) {
+			errors.BadRequest(w, r, "Invalid version")
+			return
+		}
+		compare := v - 1
+		if cStr := r.URL.Query().Get("compare"); cStr != "" {
+			compare, err = strconv.Atoi(cStr)
+			if err != nil || compare < 0 || compare > len(versions) {
+				errors.BadRequest(w, r, "Invalid version to compare")
+				return
+			}
+		}
+
+		selected := versions[v-1]
+		data["Selected"] = selected
+		if compare >= 1 {
+			base := versions[compare-1]
+			data["Compare"] = base
+			data["TitleDiff"] = diffWords(base.Title, selected.Title)
+			data["ContentDiff"] = diffWords(base.Content, selected.Content)
+			data["CategoryDiff"] = diffWords(strings.Join(base.Categories, " | "), strings.Join(selected.Categories, " | "))
+		}
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		errors.InternalServerError(w, r, "Error rendering template: "+err.Error())
+	}
+}
+
+// buildVersions turns stored revisions plus the live post into a numbered list, newest last
+func buildVersions(post *db.PostShow, revisions []db.PostRevision) []PostVersion {
+	versions := make([]PostVersion, 0, len(revisions)+1)
+	editedBy := post.Username
+	editedAt := post.CreatedAt.In(displayZone).Format("Jan 02, 2006 3:04 PM")
+
+	for i, rev := range revisions {
+		versions = append(versions, PostVersion{
+			Number:     i + 1,
+			Title:      rev.Title,
+			Content:    rev.Content,
+			Categories: rev.Categories,
+			EditedBy:   editedBy,
+			EditedAt:   editedAt,
+		})
+		// The edit that replaced this version produced the next one
+		editedBy = rev.EditorName
+		editedAt = rev.CreatedAt.In(displayZone).Format("Jan 02, 2006 3:04 PM")
+	}
+
+	versions = append(versions, PostVersion{
+		Number:     len(revisions) + 1,
+		Title:      post.Title,
+		Content:    post.Content,
+		Categories: post.Categories,
+		EditedBy:   editedBy,
+		EditedAt:   editedAt,
+		Current:    true,
+	})
+	return versions
+}
@@ -4,11 +4,16 @@ import (
 	"forum/Backend/DB"
 	"forum/Backend/errors"
 	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"forum/Backend/security"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
+// MaxCommentLength is the longest comment or reply accepted, in bytes
+const MaxCommentLength = 100
+
 // LikePostHandler handles likes/dislikes for posts and comments.
 func LikePostHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -21,6 +26,9 @@ func LikePostHandler(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
+	if !requireAction(w, r, session, permissions.Vote) {
+		return
+	}
 
 	postIDStr := r.FormValue("post_id")
 	commentIDStr := r.FormValue("comment_id")
@@ -65,7 +73,12 @@ func LikePostHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+	// Back to the page the vote was cast on, as long as it is on this site
+	fallback := "/homePage"
+	if postIDStr != "" {
+		fallback = "/post?id=" + postIDStr
+	}
+	http.Redirect(w, r, security.SafeRedirect(r, r.Header.Get("Referer"), fallback), http.StatusSeeOther)
 }
 
 // CommentOnPostHandler handles comments on posts.
@@ -79,6 +92,9 @@ func CommentOnPostHandler(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
+	if !requireAction(w, r, session, permissions.Comment) {
+		return
+	}
 
 	postIDStr := r.FormValue("post_id")
 	content := strings.TrimSpace(r.FormValue("content"))
@@ -88,7 +104,7 @@ func CommentOnPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(content) > 100 {
+	if len(content) > MaxCommentLength {
 		errors.BadRequest(w, r, "Comment too long (max 100 characters)")
 		return
 	}
@@ -100,10 +116,80 @@ func CommentOnPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := db.AddComment(db.DB, postID, *session.UserID, content); err != nil {
-		errors.InternalServerError(w, r, "DB error adding comment: "+err.Error())
+	commentID, held, errMsg, err := PublishComment(*session.UserID, postID, 0, content)
+	if err != nil {
+		errors.InternalServerError(w, r, err.Error())
 		return
 	}
+	if errMsg != "" {
+		errors.BadRequest(w, r, errMsg)
+		return
+	}
+
+	http.Redirect(w, r, commentRedirect(postID, commentID, held), http.StatusSeeOther)
+}
+
+// ReplyToCommentHandler handles replies to an existing comment.
+func ReplyToCommentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session == nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !requireAction(w, r, session, permissions.Comment) {
+		return
+	}
+
+	parentIDStr := r.FormValue("comment_id")
+	content := strings.TrimSpace(r.FormValue("content"))
 
-	http.Redirect(w, r, "/post?id="+strconv.Itoa(postID), http.StatusSeeOther)
+	if parentIDStr == "" || content == "" {
+		errors.BadRequest(w, r, "Comment ID and content are required")
+		return
+	}
+
+	if len(content) > MaxCommentLength {
+		errors.BadRequest(w, r, "Comment too long (max 100 characters)")
+		return
+	}
+
+	parentID, _ := strconv.Atoi(parentIDStr)
+	postID, err := db.GetReplyParent(db.DB, parentID)
+	if err != nil {
+		errors.BadRequest(w, r, "Comment does not exist")
+		return
+	}
+
+	ok, err := db.CheckPostExists(db.DB, postID)
+	if err != nil || !ok {
+		errors.BadRequest(w, r, "Post does not exist")
+		return
+	}
+
+	commentID, held, errMsg, err := PublishComment(*session.UserID, postID, parentID, content)
+	if err != nil {
+		errors.InternalServerError(w, r, err.Error())
+		return
+	}
+	if errMsg != "" {
+		errors.BadRequest(w, r, errMsg)
+		return
+	}
+
+	http.Redirect(w, r, commentRedirect(postID, commentID, held), http.StatusSeeOther)
+}
+
+// commentRedirect links to a new comment, telling its author when it was
+// held for review
+func commentRedirect(postID, commentID int, held bool) string {
+	url := "/post?id=" + strconv.Itoa(postID)
+	if held {
+		url += "&held=1"
+	}
+	return url + "#comment-" + strconv.Itoa(commentID)
 }
@@ -0,0 +1,103 @@
+package posts
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inRepoRoot runs the test from the repository root, where the error page
+// templates are
+func inRepoRoot(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestReplyRefusesHiddenParents(t *testing.T) {
+	inRepoRoot(t)
+	dbtest.Use(t)
+	aliceID := dbtest.CreateUser(t, db.DB, "alice")
+	if _, err := db.DB.Exec(`UPDATE users SET email_verified_at = ? WHERE id = ?`, time.Now().UTC(), aliceID); err != nil {
+		t.Fatal(err)
+	}
+	cookie := dbtest.SessionCookie(t, db.DB, aliceID)
+
+	postID, err := db.CreatePost(db.DB, aliceID, "Patch notes", "Body", time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := func(setup string) int {
+		id, err := db.AddComment(db.DB, postID, aliceID, "Parent")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if setup != "" {
+			if _, err := db.DB.Exec(`UPDATE comments SET `+setup+` WHERE id = ?`, time.Now().UTC(), id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return id
+	}
+
+	tests := []struct {
+		name     string
+		parentID int
+		want     int
+	}{
+		{"visible parent", comment(""), http.StatusSeeOther},
+		{"hidden parent", comment("hidden_at = ?"), http.StatusBadRequest},
+		{"held parent", comment("held = 1, hidden_at = ?"), http.StatusBadRequest},
+		{"deleted parent", comment("deleted_at = ?"), http.StatusBadRequest},
+		{"unknown parent", 9999, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"comment_id": {strconv.Itoa(tt.parentID)}, "content": {"A reply"}}
+			r := httptest.NewRequest(http.MethodPost, "/comment/reply", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			ReplyToCommentHandler(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureCommentDepth(t *testing.T) {
+	saved := MaxCommentDepth
+	t.Cleanup(func() { MaxCommentDepth = saved })
+
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 5},
+		{"8", 8},
+		{"0", 5},
+		{"deep", 5},
+	}
+	for _, tt := range tests {
+		MaxCommentDepth = 5
+		t.Setenv("FORUM_MAX_COMMENT_DEPTH", tt.value)
+		Configure()
+		if MaxCommentDepth != tt.want {
+			t.Errorf("FORUM_MAX_COMMENT_DEPTH=%q: depth = %d, want %d", tt.value, MaxCommentDepth, tt.want)
+		}
+	}
+}
@@ -5,33 +5,79 @@ import (
 	"fmt"
 	db "forum/Backend/DB"
 	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/permissions"
+	"forum/Backend/profile"
+	"forum/Backend/security"
 	"html/template"
 	"net/http"
+	"os"
 	"sort"
 	"strconv"
 	"time"
 )
 
+// displayZone is the timezone used when formatting dates for templates
+var displayZone = time.FixedZone("UTC+3", 3*3600)
+
+// MaxCommentDepth is the number of reply levels shown before a thread is
+// flattened. Configure reads it from FORUM_MAX_COMMENT_DEPTH.
+var MaxCommentDepth = 5
+
+// Configure applies FORUM_MAX_COMMENT_DEPTH, keeping the default when it is
+// unset or not a positive number
+func Configure() {
+	value := os.Getenv("FORUM_MAX_COMMENT_DEPTH")
+	if value == "" {
+		return
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 1 {
+		fmt.Printf("FORUM_MAX_COMMENT_DEPTH must be a positive number, keeping %d\n", MaxCommentDepth)
+		return
+	}
+	MaxCommentDepth = depth
+}
+
 // PostShow struct for template rendering
 type PostShow struct {
 	ID         int
 	Username   string
+	Avatar     string // empty when the author has none
 	Title      string
 	Content    string
 	Categories []string
 	CreatedAt  string
+	EditedAt   string // empty if the post was never edited
 	Likes      int
 	Dislikes   int
+	Deleted    bool
+	Hidden     bool
+	Held       bool // hidden by the content filter until approved
+	// ShadowBanned is only set for moderators; the author must not notice
+	ShadowBanned bool
 }
 
 // Comment struct for template rendering
 type Comment struct {
-	ID        int
-	Username  string
-	Content   string
-	CreatedAt string
-	Likes     int
-	Dislikes  int
+	ID           int
+	UserID       int
+	Username     string
+	Avatar       string
+	Content      string
+	CreatedAt    string
+	Likes        int
+	Dislikes     int
+	Deleted      bool
+	Hidden       bool // only set for viewers allowed to see hidden comments
+	Held         bool
+	ShadowBanned bool // only set for moderators
+	Depth        int
+	Replies      []Comment
+	CanDelete    bool
+	CanReply     bool
+	CanReport    bool
+	CanUnhide    bool
 }
 
 // PostShowHandler handles GET /post?id=ID
@@ -64,13 +110,13 @@ func ShowPost(w http.ResponseWriter, r *http.Request, postID int) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles("templates/post.html")
+	tmpl, err := template.New("post.html").Funcs(security.Funcs(r)).ParseFiles("templates/post.html")
 	if err != nil {
 		errors.InternalServerError(w, r, fmt.Sprintf("Template parsing error: %v", err))
 		return
 	}
 
-	loc := time.FixedZone("UTC+3", 3*3600)
+	loc := displayZone
 
 	// Fetch post using DB layer
 	p, err := db.GetPostWithCategories(conn, postID)
@@ -86,38 +132,80 @@ func ShowPost(w http.ResponseWriter, r *http.Request, postID int) {
 	post := PostShow{
 		ID:         p.ID,
 		Username:   p.Username,
+		Avatar:     profile.AvatarURL(p.UserID, p.AvatarVersion),
 		Title:      p.Title,
 		Content:    p.Content,
 		Categories: p.Categories,
 		CreatedAt:  p.CreatedAt.In(loc).Format("Jan 02, 2006 3:04 PM"),
 		Likes:      p.Likes,
 		Dislikes:   p.Dislikes,
+		Deleted:    p.Deleted,
+		Hidden:     p.Hidden,
+		Held:       p.Held,
+	}
+	if p.UpdatedAt != nil && !p.Deleted {
+		post.EditedAt = p.UpdatedAt.In(loc).Format("Jan 02, 2006 3:04 PM")
+	}
+
+	session, _ := login.GetSessionFromRequest(r)
+	viewerID := 0
+	if session != nil && session.UserID != nil {
+		viewerID = *session.UserID
+	}
+	var canEdit, canDelete, canModerate bool
+	if !p.Deleted && viewerID != 0 {
+		if canEdit, err = permissions.CanModify(viewerID, p.UserID, permissions.EditPost, p.Categories...); err != nil {
+			errors.InternalServerError(w, r, fmt.Sprintf("Error checking permissions: %v", err))
+			return
+		}
+		if canDelete, err = permissions.CanModify(viewerID, p.UserID, permissions.DeletePost, p.Categories...); err != nil {
+			errors.InternalServerError(w, r, fmt.Sprintf("Error checking permissions: %v", err))
+			return
+		}
+	}
+	var canHide bool
+	if viewerID != 0 {
+		if canModerate, err = permissions.Can(viewerID, permissions.DeleteComment, p.Categories...); err != nil {
+			errors.InternalServerError(w, r, fmt.Sprintf("Error checking permissions: %v", err))
+			return
+		}
+		if canHide, err = permissions.Can(viewerID, permissions.HideContent, p.Categories...); err != nil {
+			errors.InternalServerError(w, r, fmt.Sprintf("Error checking permissions: %v", err))
+			return
+		}
+	}
+
+	if hiddenFrom(p, viewerID, canHide) {
+		errors.NotFound(w, r, "Post not found")
+		return
 	}
 
 	// Fetch comments using DB layer
-	commentsRaw, err := db.GetCommentsForPost(conn, postID)
+	commentsRaw, err := db.GetCommentTree(conn, postID, MaxCommentDepth)
 	if err != nil {
 		errors.InternalServerError(w, r, fmt.Sprintf("Error fetching comments: %v", err))
 		return
 	}
 
-	var comments []Comment
-	for _, c := range commentsRaw {
-		comments = append(comments, Comment{
-			ID:        c.ID,
-			Username:  c.Username,
-			Content:   c.Content,
-			CreatedAt: c.CreatedAt.In(loc).Format("Jan 02, 2006 3:04 PM"),
-			Likes:     c.Likes,
-			Dislikes:  c.Dislikes,
-		})
+	post.ShadowBanned = p.ShadowBanned && canHide && !p.Deleted
+	if !canHide {
+		db.MaskHiddenComments(commentsRaw, viewerID)
+		commentsRaw = db.RemoveShadowBanned(commentsRaw, viewerID)
 	}
 
+	comments := convertComments(commentsRaw, viewerID, canModerate, canHide, !p.Deleted && viewerID != 0)
 	sortCommentsByLikes(comments)
 
 	err = tmpl.Execute(w, map[string]interface{}{
-		"Post":     post,
-		"Comments": comments,
+		"Post":         post,
+		"Comments":     comments,
+		"CommentCount": countComments(comments),
+		"CanEdit":      canEdit,
+		"CanDelete":    canDelete,
+		"CanHide":      canHide,
+		"CanReport":    !p.Deleted && viewerID != 0 && viewerID != p.UserID,
+		"Reported":     r.URL.Query().Get("reported") == "1",
+		"JustHeld":     r.URL.Query().Get("held") == "1",
 	})
 	if err != nil {
 		errors.InternalServerError(w, r, fmt.Sprintf("Error rendering template: %v", err))
@@ -125,7 +213,47 @@ func ShowPost(w http.ResponseWriter, r *http.Request, postID int) {
 	}
 }
 
-// sortCommentsByLikes sorts comments by likes (most liked first)
+// convertComments maps the DB comment tree to template comments.
+// canModerate lets the viewer delete comments by other users; canHide lets
+// them see hidden comments.
+func convertComments(raw []db.Comment, viewerID int, canModerate, canHide, canReply bool) []Comment {
+	var comments []Comment
+	for _, c := range raw {
+		comments = append(comments, Comment{
+			ID:           c.ID,
+			UserID:       c.UserID,
+			Username:     c.Username,
+			Avatar:       profile.AvatarURL(c.UserID, c.AvatarVersion),
+			Content:      c.Content,
+			CreatedAt:    c.CreatedAt.In(displayZone).Format("Jan 02, 2006 3:04 PM"),
+			Likes:        c.Likes,
+			Dislikes:     c.Dislikes,
+			Deleted:      c.Deleted,
+			Hidden:       c.Hidden && (canHide || c.UserID == viewerID),
+			Held:         c.Held && (canHide || c.UserID == viewerID),
+			ShadowBanned: c.ShadowBanned && canHide,
+			Depth:        c.Depth,
+			Replies:      convertComments(c.Replies, viewerID, canModerate, canHide, canReply),
+			CanDelete:    !c.Deleted && viewerID != 0 && (c.UserID == viewerID || canModerate),
+			CanReply:     canReply && !c.Deleted && !c.Hidden,
+			CanReport:    viewerID != 0 && !c.Deleted && !c.Hidden && c.UserID != viewerID,
+			CanUnhide:    canHide && c.Hidden && !c.Held && !c.Deleted,
+		})
+	}
+	return comments
+}
+
+// countComments counts comments including all nested replies
+func countComments(comments []Comment) int {
+	n := len(comments)
+	for _, c := range comments {
+		n += countComments(c.Replies)
+	}
+	return n
+}
+
+// sortCommentsByLikes sorts comments by likes (most liked first).
+// Only top-level comments are sorted; replies stay in conversation order.
 func sortCommentsByLikes(comments []Comment) {
 	sort.SliceStable(comments, func(i, j int) bool {
 		return comments[i].Likes > comments[j].Likes
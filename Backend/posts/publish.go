@@ -0,0 +1,104 @@
+package posts
+
+import (
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/screening"
+	"time"
+)
+
+// PublishPost screens a validated post and saves it. A non-empty message
+// means the post was rejected. Held posts are saved hidden and queued for
+// moderator review.
+func PublishPost(userID int, title, content string, categoriesSelected []string) (postID int, held bool, errMsg string, err error) {
+	res, err := screening.Screen(screening.Content{UserID: userID, Kind: "post", Title: title, Body: content})
+	if err != nil {
+		return 0, false, "", fmt.Errorf("Error screening post: %w", err)
+	}
+	if res.Verdict == screening.Reject {
+		return 0, false, res.Message(), nil
+	}
+
+	// The post is held in the transaction that saves it, so it is never public
+	err = db.Transaction(db.DB, func(q db.Querier) error {
+		var err error
+		postID, err = savePost(q, userID, res.Title, res.Body, categoriesSelected)
+		if err != nil {
+			return err
+		}
+		if res.Verdict == screening.Hold {
+			if err := db.HoldContent(q, "post", postID, postID, res.Reasons); err != nil {
+				return fmt.Errorf("Error holding post: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, "", err
+	}
+	return postID, res.Verdict == screening.Hold, "", nil
+}
+
+// RevisePost screens an edit of a validated post by editorID and saves it
+// like PublishPost: a non-empty message means the edit was rejected and the
+// post is unchanged, and held edits hide the post until a moderator reviews it.
+func RevisePost(editorID int, post *db.PostShow, title, content string, categoriesSelected []string) (held bool, errMsg string, err error) {
+	res, err := screening.Screen(screening.Content{UserID: post.UserID, Kind: "post", Title: title, Body: content, PostID: post.ID})
+	if err != nil {
+		return false, "", fmt.Errorf("Error screening post: %w", err)
+	}
+	if res.Verdict == screening.Reject {
+		return false, res.Message(), nil
+	}
+
+	entry := db.AuditEntry{Action: "post.edit", TargetType: "post", TargetID: post.ID}
+	err = AuditedChange(editorID, post.UserID, entry, func(q db.Querier) error {
+		if err := db.UpdatePost(q, post.ID, editorID, res.Title, res.Body, categoriesSelected, time.Now().UTC()); err != nil {
+			return fmt.Errorf("Error saving post: %w", err)
+		}
+		if res.Verdict == screening.Hold {
+			if err := db.HoldContent(q, "post", post.ID, post.ID, res.Reasons); err != nil {
+				return fmt.Errorf("Error holding post: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, "", err
+	}
+	return res.Verdict == screening.Hold, "", nil
+}
+
+// PublishComment screens a validated comment and saves it, as a reply when
+// parentID is non-zero. It behaves like PublishPost.
+func PublishComment(userID, postID, parentID int, content string) (commentID int, held bool, errMsg string, err error) {
+	res, err := screening.Screen(screening.Content{UserID: userID, Kind: "comment", Body: content})
+	if err != nil {
+		return 0, false, "", fmt.Errorf("Error screening comment: %w", err)
+	}
+	if res.Verdict == screening.Reject {
+		return 0, false, res.Message(), nil
+	}
+
+	err = db.Transaction(db.DB, func(q db.Querier) error {
+		var err error
+		if parentID != 0 {
+			commentID, err = db.AddReply(q, postID, parentID, userID, res.Body)
+		} else {
+			commentID, err = db.AddComment(q, postID, userID, res.Body)
+		}
+		if err != nil {
+			return fmt.Errorf("DB error adding comment: %w", err)
+		}
+		if res.Verdict == screening.Hold {
+			if err := db.HoldContent(q, "comment", commentID, postID, res.Reasons); err != nil {
+				return fmt.Errorf("Error holding comment: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, "", err
+	}
+	return commentID, res.Verdict == screening.Hold, "", nil
+}
@@ -0,0 +1,28 @@
+package posts
+
+import (
+	"fmt"
+	db "forum/Backend/DB"
+	"time"
+)
+
+// DeletedRetention is how long soft-deleted posts and comments are kept before purging
+const DeletedRetention = 30 * 24 * time.Hour
+
+// purgeInterval is how often the purge job runs
+const purgeInterval = time.Hour
+
+// StartPurgeJob periodically hard-deletes content soft-deleted longer than DeletedRetention.
+// It blocks, so run it in its own goroutine.
+func StartPurgeJob() {
+	for {
+		cutoff := time.Now().UTC().Add(-DeletedRetention)
+		posts, comments, err := db.PurgeDeleted(db.DB, cutoff)
+		if err != nil {
+			fmt.Println("Purge job failed:", err)
+		} else if posts > 0 || comments > 0 {
+			fmt.Printf("Purged %d deleted post(s) and %d deleted comment(s)\n", posts, comments)
+		}
+		time.Sleep(purgeInterval)
+	}
+}
@@ -0,0 +1,164 @@
+package posts
+
+import (
+	"database/sql"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ReportReason is a reason code users pick when reporting content
+type ReportReason struct {
+	Code  string
+	Label string
+}
+
+// ReportReasons lists the accepted reason codes, in display order
+var ReportReasons = []ReportReason{
+	{"spam", "Spam or advertising"},
+	{"harassment", "Harassment or bullying"},
+	{"hate", "Hate speech"},
+	{"nsfw", "Sexual or graphic content"},
+	{"off_topic", "Off-topic"},
+	{"other", "Something else"},
+}
+
+// MaxReportDetailsLength bounds the optional free-text part of a report
+const MaxReportDetailsLength = 500
+
+// ValidReportReason reports whether code is one of ReportReasons
+func ValidReportReason(code string) bool {
+	for _, r := range ReportReasons {
+		if r.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// FileReport validates and stores a report of a post or comment. It returns
+// the reported post's ID, or a user-facing message and HTTP status on failure.
+func FileReport(reporterID int, targetType string, targetID int, reason, details string) (int, string, int) {
+	details = strings.TrimSpace(details)
+	if !ValidReportReason(reason) {
+		return 0, "Please choose a reason", http.StatusBadRequest
+	}
+	if len(details) > MaxReportDetailsLength {
+		return 0, "Details too long (max 500 characters)", http.StatusBadRequest
+	}
+
+	var authorID, postID int
+	switch targetType {
+	case "post":
+		exists, err := db.CheckPostExists(db.DB, targetID)
+		if err != nil || !exists {
+			return 0, "Post does not exist", http.StatusNotFound
+		}
+		postID = targetID
+		authorID, err = db.GetPostAuthorID(db.DB, targetID)
+		if err != nil {
+			return 0, "DB error: " + err.Error(), http.StatusInternalServerError
+		}
+	case "comment":
+		exists, err := db.CheckCommentExists(db.DB, targetID)
+		if err != nil || !exists {
+			return 0, "Comment does not exist", http.StatusNotFound
+		}
+		authorID, postID, err = db.GetCommentAuthor(db.DB, targetID)
+		if err != nil {
+			return 0, "DB error: " + err.Error(), http.StatusInternalServerError
+		}
+	default:
+		return 0, "Invalid report target", http.StatusBadRequest
+	}
+
+	if authorID == reporterID {
+		return 0, "You can't report your own " + targetType, http.StatusBadRequest
+	}
+
+	if _, err := db.CreateReport(db.DB, reporterID, targetType, targetID, postID, reason, details); err != nil {
+		return 0, "DB error saving report: " + err.Error(), http.StatusInternalServerError
+	}
+	// A repeated report by the same user is silently merged into the open one
+	return postID, "", http.StatusOK
+}
+
+// ReportHandler handles GET and POST /report?target_type=post|comment&target_id=ID
+func ReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	targetType := r.FormValue("target_type")
+	if targetType != "post" && targetType != "comment" {
+		errors.BadRequest(w, r, "Invalid report target")
+		return
+	}
+	targetID, err := strconv.Atoi(r.FormValue("target_id"))
+	if err != nil || targetID < 1 {
+		errors.BadRequest(w, r, "Invalid ID")
+		return
+	}
+
+	target, err := db.GetReportTarget(db.DB, targetType, targetID)
+	if err == sql.ErrNoRows || (err == nil && (target.Deleted || target.Hidden)) {
+		errors.NotFound(w, r, "Nothing to report here")
+		return
+	}
+	if err != nil {
+		errors.InternalServerError(w, r, "DB error: "+err.Error())
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderReportForm(w, r, target, "")
+		return
+	}
+
+	postID, msg, status := FileReport(*session.UserID, targetType, targetID, r.FormValue("reason"), r.FormValue("details"))
+	switch status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		errors.NotFound(w, r, msg)
+		return
+	case http.StatusInternalServerError:
+		errors.InternalServerError(w, r, msg)
+		return
+	default:
+		w.WriteHeader(status)
+		renderReportForm(w, r, target, msg)
+		return
+	}
+
+	http.Redirect(w, r, "/post?id="+strconv.Itoa(postID)+"&reported=1", http.StatusSeeOther)
+}
+
+func renderReportForm(w http.ResponseWriter, r *http.Request, target *db.ReportedItem, errMsg string) {
+	tmpl, err := template.New("report.html").Funcs(security.Funcs(r)).ParseFiles("templates/report.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	tmpl.Execute(w, map[string]interface{}{
+		"TargetType": target.TargetType,
+		"TargetID":   target.TargetID,
+		"PostID":     target.PostID,
+		"PostTitle":  target.PostTitle,
+		"Author":     target.Author,
+		"Content":    target.Content,
+		"Reasons":    ReportReasons,
+		"Error":      errMsg,
+	})
+}
@@ -0,0 +1,219 @@
+package profile
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"  // registers the GIF decoder
+	_ "image/jpeg" // registers the JPEG decoder
+	"image/png"
+	"net/http"
+	"strconv"
+)
+
+// MaxAvatarBytes is the largest avatar file accepted for upload
+const MaxAvatarBytes = 2 << 20
+
+// maxAvatarPixels caps the dimensions of an uploaded image, so a small file
+// can't decode into gigabytes of pixels
+const maxAvatarPixels = 4096
+
+// AvatarSize is the width and height avatars are stored at
+const AvatarSize = 256
+
+// avatarTypes are the image formats accepted for avatars, by sniffed content type
+var avatarTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// AvatarURL is where the avatar with the given version is served; empty
+// when the user has none
+func AvatarURL(userID int, version int64) string {
+	if version == 0 {
+		return ""
+	}
+	return "/avatar?id=" + strconv.Itoa(userID) + "&v=" + strconv.FormatInt(version, 10)
+}
+
+// AvatarHandler serves a user's avatar. URLs carry the avatar version, so
+// the image can be cached for good; a new upload gets a new URL.
+func AvatarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	userID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || userID < 1 {
+		errors.BadRequest(w, r, "Invalid User ID")
+		return
+	}
+
+	img, version, err := db.GetAvatar(db.DB, userID)
+	if err == sql.ErrNoRows {
+		errors.NotFound(w, r, "No avatar")
+		return
+	}
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.URL.Query().Get("v") == strconv.FormatInt(version, 10) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+	if r.Header.Get("If-None-Match") == fmt.Sprintf(`"%d"`, version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(img)))
+	w.Write(img)
+}
+
+// UploadAvatarHandler replaces the logged-in user's avatar with an uploaded
+// PNG, JPEG or GIF image, cropped to a square and resized
+func UploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		renderEditProfile(w, r, user, nil, map[string]interface{}{"AvatarError": msg})
+	}
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		fail("Choose an image to upload")
+		return
+	}
+	defer file.Close()
+	if header.Size > MaxAvatarBytes {
+		fail(fmt.Sprintf("The image is too large. Avatars can be up to %d MB.", MaxAvatarBytes>>20))
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(file); err != nil {
+		errors.InternalServerError(w, r, "Error reading upload: "+err.Error())
+		return
+	}
+
+	resized, msg := processAvatar(buf.Bytes())
+	if msg != "" {
+		fail(msg)
+		return
+	}
+	if err := db.SetAvatar(db.DB, user.ID, resized); err != nil {
+		errors.InternalServerError(w, r, "Error saving avatar: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/profile/edit?done=avatar", http.StatusSeeOther)
+}
+
+// RemoveAvatarHandler deletes the logged-in user's avatar
+func RemoveAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if err := db.DeleteAvatar(db.DB, user.ID); err != nil {
+		errors.InternalServerError(w, r, "Error removing avatar: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/profile/edit?done=avatar_removed", http.StatusSeeOther)
+}
+
+// processAvatar checks an uploaded image and turns it into the PNG that is
+// stored. If the image is refused, msg says why.
+func processAvatar(data []byte) (out []byte, msg string) {
+	if !avatarTypes[http.DetectContentType(data)] {
+		return nil, "Use a PNG, JPEG or GIF image"
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "The image couldn't be read"
+	}
+	if config.Width > maxAvatarPixels || config.Height > maxAvatarPixels {
+		return nil, fmt.Sprintf("The image is too big. Use one at most %d×%d pixels.", maxAvatarPixels, maxAvatarPixels)
+	}
+	// GIFs are decoded to their first frame
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "The image couldn't be read"
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, squareAvatar(img, AvatarSize)); err != nil {
+		return nil, "The image couldn't be converted"
+	}
+	return buf.Bytes(), ""
+}
+
+// squareAvatar crops the middle square out of img and scales it down to at
+// most size×size, averaging the source pixels that fall on each target pixel.
+// Smaller images keep their size.
+func squareAvatar(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	// Work on a copy in a known format, so pixels are cheap to read
+	src := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(src, src.Bounds(), img, image.Pt(x0, y0), draw.Src)
+	if side <= size {
+		return src
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0, sy1 := y*side/size, (y+1)*side/size
+		for x := 0; x < size; x++ {
+			sx0, sx1 := x*side/size, (x+1)*side/size
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					c := src.NRGBAAt(sx, sy)
+					// Weigh colours by opacity, so transparent pixels don't darken edges
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					bl += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / a),
+				G: uint8(g / a),
+				B: uint8(bl / a),
+				A: uint8(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,133 @@
+package profile
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/oauth"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// connectionMessages are the outcomes ConnectionsHandler reports from its query string
+var connectionMessages = map[string]string{
+	"linked":   "The account was linked. You can log in with it now.",
+	"unlinked": "The account was unlinked.",
+	"taken":    "That account is already linked to another forum account.",
+	"failed":   "Linking didn't work. Try again.",
+	"last":     "This is the only way to log in to your account. Set a password with \"Forgot your password?\" on the login page before unlinking it.",
+}
+
+// ConnectionsHandler lists the provider accounts the logged-in user can log
+// in with, and the providers they can still link
+func ConnectionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	identities, err := db.GetUserIdentities(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching linked accounts: "+err.Error())
+		return
+	}
+	linked := map[string]bool{}
+	for _, identity := range identities {
+		linked[identity.Provider] = true
+	}
+	var available []oauth.Provider
+	for _, p := range oauth.Providers() {
+		if !linked[p.Name()] {
+			available = append(available, p)
+		}
+	}
+
+	data := map[string]interface{}{
+		"UserID":     user.ID,
+		"Identities": identities,
+		"Available":  available,
+	}
+	q := r.URL.Query()
+	if q.Get("linked") == "1" {
+		data["Notice"] = connectionMessages["linked"]
+	}
+	if q.Get("unlinked") == "1" {
+		data["Notice"] = connectionMessages["unlinked"]
+	}
+	if msg, ok := connectionMessages[q.Get("error")]; ok {
+		data["Error"] = msg
+	}
+
+	tmpl, err := template.New("connections.html").Funcs(security.Funcs(r)).Funcs(template.FuncMap{
+		"providerLabel": providerLabel,
+	}).ParseFiles("templates/connections.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		errors.InternalServerError(w, r, "Template execution error: "+err.Error())
+	}
+}
+
+// UnlinkIdentityHandler removes a linked provider account, unless it is the
+// only way left to log in
+func UnlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	user, err := db.GetUserByID(db.DB, *session.UserID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching user: "+err.Error())
+		return
+	}
+
+	identityID, err := strconv.Atoi(r.FormValue("identity_id"))
+	if err != nil || identityID < 1 {
+		errors.BadRequest(w, r, "Invalid identity ID")
+		return
+	}
+
+	// Accounts created through a provider have no password until one is set
+	identities, err := db.GetUserIdentities(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching linked accounts: "+err.Error())
+		return
+	}
+	if user.Password == "" && len(identities) <= 1 {
+		http.Redirect(w, r, "/profile/connections?error=last", http.StatusSeeOther)
+		return
+	}
+
+	deleted, err := db.DeleteUserIdentity(db.DB, identityID, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Failed to unlink account: "+err.Error())
+		return
+	}
+	if !deleted {
+		errors.NotFound(w, r, "Linked account not found")
+		return
+	}
+	http.Redirect(w, r, "/profile/connections?unlinked=1", http.StatusSeeOther)
+}
+
+// providerLabel names a provider for display, falling back to its ID when
+// it is no longer configured
+func providerLabel(name string) string {
+	if p := oauth.Get(name); p != nil {
+		return p.Label()
+	}
+	return name
+}
@@ -0,0 +1,207 @@
+package profile
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/screening"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Length limits of the free-text profile fields, in characters
+const (
+	MaxBioLength   = 500
+	MaxGamesLength = 200
+)
+
+// platformHandle is a gamer tag field on the profile and the rules the
+// platform has for it
+type platformHandle struct {
+	Field   string // form field
+	Label   string
+	Pattern *regexp.Regexp
+	Rule    string // shown when Pattern doesn't match
+}
+
+// platformHandles are the gamer tags a profile can show, in display order
+var platformHandles = []platformHandle{
+	{"steam", "Steam", regexp.MustCompile(`^[A-Za-z0-9_-]{2,32}$`),
+		"Steam IDs are 2 to 32 letters, digits, dashes or underscores"},
+	{"psn", "PlayStation Network", regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{2,15}$`),
+		"PSN online IDs are 3 to 16 letters, digits, dashes or underscores, starting with a letter"},
+	{"xbox", "Xbox", regexp.MustCompile(`^[A-Za-z][A-Za-z0-9 ]{0,14}$`),
+		"Xbox gamertags are up to 15 letters, digits or spaces, starting with a letter"},
+	{"minecraft", "Minecraft", regexp.MustCompile(`^[A-Za-z0-9_]{3,16}$`),
+		"Minecraft usernames are 3 to 16 letters, digits or underscores"},
+}
+
+// editNotices are shown on the edit page after a change, by ?done=
+var editNotices = map[string]string{
+	"profile":        "Your profile was saved.",
+	"avatar":         "Your avatar was updated.",
+	"avatar_removed": "Your avatar was removed.",
+}
+
+// EditProfileHandler shows (GET) and saves (POST) the logged-in user's bio,
+// favourite games and gamer tags
+func EditProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Method not allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		renderEditProfile(w, r, user, nil, map[string]interface{}{
+			"Notice": editNotices[r.URL.Query().Get("done")],
+		})
+		return
+	}
+
+	profile := db.Profile{
+		Bio:            strings.TrimSpace(strings.ReplaceAll(r.FormValue("bio"), "\r\n", "\n")),
+		FavouriteGames: cleanGames(r.FormValue("favourite_games")),
+		Steam:          strings.TrimSpace(r.FormValue("steam")),
+		PSN:            strings.TrimSpace(r.FormValue("psn")),
+		Xbox:           strings.TrimSpace(r.FormValue("xbox")),
+		Minecraft:      strings.TrimSpace(r.FormValue("minecraft")),
+	}
+	if msg, err := validateProfile(user.ID, &profile); err != nil {
+		errors.InternalServerError(w, r, "Error checking profile: "+err.Error())
+		return
+	} else if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		renderEditProfile(w, r, user, &profile, map[string]interface{}{"Error": msg})
+		return
+	}
+
+	if err := db.SaveProfile(db.DB, user.ID, profile); err != nil {
+		errors.InternalServerError(w, r, "Error saving profile: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/profile/edit?done=profile", http.StatusSeeOther)
+}
+
+// validateProfile checks the fields of a profile and runs the free text
+// through the word filter, masking words in place. If the profile is
+// refused, msg says why.
+func validateProfile(userID int, p *db.Profile) (msg string, err error) {
+	if utf8.RuneCountInString(p.Bio) > MaxBioLength {
+		return "Your bio can be up to 500 characters", nil
+	}
+	if utf8.RuneCountInString(p.FavouriteGames) > MaxGamesLength {
+		return "Your favourite games can be up to 200 characters", nil
+	}
+	for _, h := range platformHandles {
+		if value := handleValue(p, h.Field); value != "" && !h.Pattern.MatchString(value) {
+			return h.Rule, nil
+		}
+	}
+
+	// Profiles are public, so the admin's blocked words apply as for posts
+	content := screening.Content{UserID: userID, Kind: "profile", Title: p.FavouriteGames, Body: p.Bio}
+	verdict, _, err := screening.WordFilter{}.Screen(&content)
+	if err != nil {
+		return "", err
+	}
+	if verdict == screening.Reject {
+		return "Your profile contains a blocked word", nil
+	}
+	p.FavouriteGames, p.Bio = content.Title, content.Body
+	return "", nil
+}
+
+// handleValue returns the gamer tag of a profile for a form field
+func handleValue(p *db.Profile, field string) string {
+	switch field {
+	case "steam":
+		return p.Steam
+	case "psn":
+		return p.PSN
+	case "xbox":
+		return p.Xbox
+	case "minecraft":
+		return p.Minecraft
+	}
+	return ""
+}
+
+// gamerTag is a filled-in gamer tag as shown on a profile
+type gamerTag struct {
+	Label string
+	Value string
+}
+
+// gamerTags lists the gamer tags the profile has, in display order
+func gamerTags(p *db.Profile) []gamerTag {
+	var tags []gamerTag
+	for _, h := range platformHandles {
+		if value := handleValue(p, h.Field); value != "" {
+			tags = append(tags, gamerTag{h.Label, value})
+		}
+	}
+	return tags
+}
+
+// cleanGames tidies a comma-separated list of games: no blanks, no
+// duplicates, single spaces
+func cleanGames(s string) string {
+	seen := map[string]bool{}
+	var games []string
+	for _, game := range strings.Split(s, ",") {
+		game = strings.Join(strings.Fields(game), " ")
+		if game == "" || seen[strings.ToLower(game)] {
+			continue
+		}
+		seen[strings.ToLower(game)] = true
+		games = append(games, game)
+	}
+	return strings.Join(games, ", ")
+}
+
+// splitGames turns the stored list of favourite games into its entries
+func splitGames(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ", ")
+}
+
+// renderEditProfile renders the edit page with profile, or the saved one
+// when profile is nil, plus whatever the handler adds to data
+func renderEditProfile(w http.ResponseWriter, r *http.Request, user *db.User, profile *db.Profile, data map[string]interface{}) {
+	if profile == nil {
+		saved, err := db.GetProfile(db.DB, user.ID)
+		if err != nil {
+			errors.InternalServerError(w, r, "Error fetching profile: "+err.Error())
+			return
+		}
+		profile = saved
+	}
+	version, err := db.GetAvatarVersion(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	data["UserID"] = user.ID
+	data["Username"] = user.Username
+	data["Profile"] = profile
+	data["Avatar"] = AvatarURL(user.ID, version)
+	data["MaxAvatarMB"] = MaxAvatarBytes >> 20
+	data["AvatarSize"] = AvatarSize
+
+	tmpl, err := template.New("profile_edit.html").Funcs(security.Funcs(r)).ParseFiles("templates/profile_edit.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		errors.InternalServerError(w, r, "Template execution error: "+err.Error())
+	}
+}
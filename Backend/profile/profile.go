@@ -1,11 +1,14 @@
 package profile
 
 import (
+	"database/sql"
 	db "forum/Backend/DB"
 	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/security"
+	"html/template"
 	"net/http"
 	"strconv"
-	"text/template"
 )
 
 func ProfileHandler(w http.ResponseWriter, r *http.Request) {
@@ -14,14 +17,12 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles("templates/profile.html")
-	if err != nil {
-		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
-		return
-	}
-
 	userIDStr := r.URL.Query().Get("id")
 	if userIDStr == "" {
+		if username := r.URL.Query().Get("user"); username != "" {
+			redirectToProfile(w, r, username)
+			return
+		}
 		errors.BadRequest(w, r, "User ID is required")
 		return
 	}
@@ -32,6 +33,42 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	renderProfile(w, r, userID, "")
+}
+
+// redirectToProfile sends /profile?user=NAME to the profile of whoever is
+// called NAME, or was until they renamed
+func redirectToProfile(w http.ResponseWriter, r *http.Request, username string) {
+	userID, err := db.GetUserIDByUsername(db.DB, username)
+	if err == nil {
+		http.Redirect(w, r, "/profile?id="+strconv.Itoa(userID), http.StatusFound)
+		return
+	}
+	if err != sql.ErrNoRows {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	userID, err = db.GetUserIDByOldUsername(db.DB, username)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if userID == 0 {
+		errors.NotFound(w, r, "User not found")
+		return
+	}
+	http.Redirect(w, r, "/profile?id="+strconv.Itoa(userID), http.StatusMovedPermanently)
+}
+
+// renderProfile shows a user's profile. newToken is a freshly created API
+// token to display once to its owner.
+func renderProfile(w http.ResponseWriter, r *http.Request, userID int, newToken string) {
+	tmpl, err := template.New("profile.html").Funcs(security.Funcs(r)).ParseFiles("templates/profile.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+
 	dbConn := db.DB
 	if dbConn == nil {
 		errors.InternalServerError(w, r, "Database connection not available")
@@ -54,26 +91,76 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 		errors.InternalServerError(w, r, "Database error: "+err.Error())
 		return
 	}
+	// Content of deleted accounts is anonymous, so it isn't gathered on a profile
+	if username == db.DeletedUsername {
+		errors.NotFound(w, r, "This account was deleted")
+		return
+	}
+
+	session, _ := login.GetSessionFromRequest(r)
+	viewerID := 0
+	if session != nil && session.UserID != nil {
+		viewerID = *session.UserID
+	}
 
 	// Get created posts
-	createdPosts, err := db.GetUserPosts(dbConn, userID, "created")
+	createdPosts, err := db.GetUserPosts(dbConn, userID, "created", viewerID)
 	if err != nil {
 		errors.InternalServerError(w, r, "Error fetching created posts: "+err.Error())
 		return
 	}
 
 	// Get liked posts
-	likedPosts, err := db.GetUserPosts(dbConn, userID, "liked")
+	likedPosts, err := db.GetUserPosts(dbConn, userID, "liked", viewerID)
 	if err != nil {
 		errors.InternalServerError(w, r, "Error fetching liked posts: "+err.Error())
 		return
 	}
 
+	// API tokens and the verification state are only visible to their owner
+	isOwner := viewerID == userID
+	var tokens []db.APIToken
+	verified := true
+	if isOwner {
+		tokens, err = db.GetAPITokens(dbConn, userID)
+		if err != nil {
+			errors.InternalServerError(w, r, "Error fetching API tokens: "+err.Error())
+			return
+		}
+		verified, err = db.IsEmailVerified(dbConn, userID)
+		if err != nil {
+			errors.InternalServerError(w, r, "Database error: "+err.Error())
+			return
+		}
+	}
+
+	profile, err := db.GetProfile(dbConn, userID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching profile: "+err.Error())
+		return
+	}
+	avatarVersion, err := db.GetAvatarVersion(dbConn, userID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+
 	// Render template
 	err = tmpl.Execute(w, map[string]interface{}{
+		"UserID":       userID,
 		"Username":     username,
+		"Avatar":       AvatarURL(userID, avatarVersion),
+		"Bio":          profile.Bio,
+		"Games":        splitGames(profile.FavouriteGames),
+		"GamerTags":    gamerTags(profile),
 		"CreatedPosts": createdPosts,
 		"LikedPosts":   likedPosts,
+		"IsOwner":      isOwner,
+		"Tokens":       tokens,
+		"Scopes":       login.Scopes,
+		"NewToken":     newToken,
+		"Unverified":   !verified,
+		"VerifySent":   r.URL.Query().Get("verification") == "sent",
 	})
 	if err != nil {
 		errors.InternalServerError(w, r, "Template execution error: "+err.Error())
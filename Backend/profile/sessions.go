@@ -0,0 +1,156 @@
+package profile
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SessionsHandler lists the devices the logged-in user is signed in on
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	sessions, err := db.GetUserSessions(db.DB, *session.UserID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching sessions: "+err.Error())
+		return
+	}
+
+	tmpl, err := template.New("sessions.html").Funcs(security.Funcs(r)).Funcs(template.FuncMap{
+		"device": describeDevice,
+	}).ParseFiles("templates/sessions.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	// ?revoked=N reports how many sessions were just signed out
+	revoked, err := strconv.Atoi(r.URL.Query().Get("revoked"))
+	err = tmpl.Execute(w, map[string]interface{}{
+		"UserID":      *session.UserID,
+		"Username":    session.Username,
+		"Sessions":    sessions,
+		"CurrentID":   session.SessionID,
+		"ShowRevoked": err == nil && revoked >= 0,
+		"Revoked":     revoked,
+	})
+	if err != nil {
+		errors.InternalServerError(w, r, "Template execution error: "+err.Error())
+	}
+}
+
+// RevokeSessionHandler signs the logged-in user out of one device. Revoking
+// the current device is the same as logging out.
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	sessionID, err := strconv.Atoi(r.FormValue("session_id"))
+	if err != nil || sessionID < 1 {
+		errors.BadRequest(w, r, "Invalid session ID")
+		return
+	}
+
+	deleted, err := db.DeleteUserSession(db.DB, sessionID, *session.UserID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Failed to revoke session: "+err.Error())
+		return
+	}
+	if !deleted {
+		errors.NotFound(w, r, "Session not found")
+		return
+	}
+
+	if sessionID == session.SessionID {
+		login.ClearSessionCookie(w)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile/sessions?revoked=1", http.StatusSeeOther)
+}
+
+// RevokeOtherSessionsHandler signs the logged-in user out everywhere except
+// the device making the request
+func RevokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	n, err := db.DeleteOtherUserSessions(db.DB, *session.UserID, session.Token)
+	if err != nil {
+		errors.InternalServerError(w, r, "Failed to revoke sessions: "+err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/profile/sessions?revoked="+strconv.FormatInt(n, 10), http.StatusSeeOther)
+}
+
+// describeDevice turns a user agent into something like "Firefox on Windows".
+// It only knows the common cases; anything else shows as the raw string.
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	systems := []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+
+	browser := ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	if browser == "" {
+		return userAgent
+	}
+	for _, s := range systems {
+		if strings.Contains(userAgent, s.token) {
+			return browser + " on " + s.name
+		}
+	}
+	return browser
+}
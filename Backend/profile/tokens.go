@@ -0,0 +1,108 @@
+package profile
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTokenNameLength bounds the label users give their API tokens
+const maxTokenNameLength = 50
+
+// CreateTokenHandler mints a new API token for the logged-in user and shows it once
+func CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	userID := *session.UserID
+
+	if err := r.ParseForm(); err != nil {
+		errors.BadRequest(w, r, "Invalid form data")
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || len(name) > maxTokenNameLength {
+		errors.BadRequest(w, r, "Token name must be 1-50 characters")
+		return
+	}
+
+	scopes := []string{login.ScopeRead}
+	for _, s := range r.Form["scopes"] {
+		switch s {
+		case login.ScopeRead:
+		case login.ScopePost, login.ScopeVote:
+			if !contains(scopes, s) {
+				scopes = append(scopes, s)
+			}
+		default:
+			errors.BadRequest(w, r, "Unknown scope: "+s)
+			return
+		}
+	}
+
+	token, hash, err := login.GenerateAPIToken()
+	if err != nil {
+		errors.InternalServerError(w, r, "Failed to generate token")
+		return
+	}
+	if _, err := db.CreateAPIToken(db.DB, userID, name, hash, scopes, time.Now().UTC()); err != nil {
+		errors.InternalServerError(w, r, "Failed to save token: "+err.Error())
+		return
+	}
+
+	renderProfile(w, r, userID, token)
+}
+
+// RevokeTokenHandler deletes one of the logged-in user's API tokens
+func RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	userID := *session.UserID
+
+	tokenID, err := strconv.Atoi(r.FormValue("token_id"))
+	if err != nil || tokenID < 1 {
+		errors.BadRequest(w, r, "Invalid token ID")
+		return
+	}
+
+	deleted, err := db.DeleteAPIToken(db.DB, tokenID, userID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Failed to revoke token: "+err.Error())
+		return
+	}
+	if !deleted {
+		errors.NotFound(w, r, "Token not found")
+		return
+	}
+
+	http.Redirect(w, r, "/profile?id="+strconv.Itoa(userID)+"#tokens", http.StatusSeeOther)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
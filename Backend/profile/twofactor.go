@@ -0,0 +1,269 @@
+package profile
+
+import (
+	"encoding/base64"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"forum/Backend/security"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"rsc.io/qr"
+)
+
+// TwoFactorHandler shows the logged-in user's two-factor status and, during
+// enrolment, the QR code to scan with an authenticator app
+func TwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.MethodNotAllowed(w, r, "Only GET requests are allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	data := map[string]interface{}{}
+	switch r.URL.Query().Get("done") {
+	case "disabled":
+		data["Notice"] = "Two-factor authentication is off. Logging in only needs your password now."
+	case "unchanged":
+		data["Notice"] = "Two-factor authentication was not turned on. It may already be on; otherwise start the setup again."
+	}
+	renderTwoFactor(w, r, user, data)
+}
+
+// SetupTwoFactorHandler starts an enrolment with a new secret. It only
+// takes effect once a code from the app is confirmed.
+func SetupTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := login.NewTOTPSecret()
+	if err != nil {
+		errors.InternalServerError(w, r, "Error generating secret: "+err.Error())
+		return
+	}
+	if err := db.SetPendingTOTP(db.DB, user.ID, secret); err != nil {
+		errors.InternalServerError(w, r, "Error saving secret: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+}
+
+// EnableTwoFactorHandler confirms an enrolment with a first code from the
+// app and shows the recovery codes, once
+func EnableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	totp, err := db.GetTOTP(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if totp == nil || totp.Enabled {
+		http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+		return
+	}
+
+	step, ok := login.MatchTOTP(totp.Secret, strings.TrimSpace(r.FormValue("code")), time.Now())
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		renderTwoFactor(w, r, user, map[string]interface{}{
+			"Error": "That code didn't match. Check that your device's clock is right and try the current code.",
+		})
+		return
+	}
+
+	codes, hashes, err := login.NewRecoveryCodes()
+	if err != nil {
+		errors.InternalServerError(w, r, "Error generating recovery codes: "+err.Error())
+		return
+	}
+	enabled, err := db.EnableTOTP(db.DB, user.ID, step, hashes)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error enabling two-factor authentication: "+err.Error())
+		return
+	}
+	// Already enabled, by a double submit for example: the codes were not
+	// stored, so they must not be shown
+	if !enabled {
+		http.Redirect(w, r, "/profile/2fa?done=unchanged", http.StatusSeeOther)
+		return
+	}
+	renderTwoFactor(w, r, user, map[string]interface{}{
+		"Notice":        "Two-factor authentication is on.",
+		"RecoveryCodes": codes,
+	})
+}
+
+// RecoveryCodesHandler replaces the recovery codes after the password is
+// entered again
+func RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	enabled, err := db.IsTOTPEnabled(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if !enabled {
+		http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(r.FormValue("password"))) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		renderTwoFactor(w, r, user, map[string]interface{}{"Error": "Wrong password"})
+		return
+	}
+
+	codes, hashes, err := login.NewRecoveryCodes()
+	if err != nil {
+		errors.InternalServerError(w, r, "Error generating recovery codes: "+err.Error())
+		return
+	}
+	if err := db.ReplaceRecoveryCodes(db.DB, user.ID, hashes); err != nil {
+		errors.InternalServerError(w, r, "Error saving recovery codes: "+err.Error())
+		return
+	}
+	renderTwoFactor(w, r, user, map[string]interface{}{
+		"Notice":        "New recovery codes were generated. The old ones no longer work.",
+		"RecoveryCodes": codes,
+	})
+}
+
+// DisableTwoFactorHandler turns two-factor authentication off. The user
+// has to enter their password and a current code, so a session left open
+// somewhere is not enough.
+func DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.MethodNotAllowed(w, r, "Only POST requests are allowed")
+		return
+	}
+	user, ok := twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	// A pending enrolment can be cancelled without re-authenticating
+	totp, err := db.GetTOTP(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	if totp != nil && totp.Enabled {
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(r.FormValue("password"))) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			renderTwoFactor(w, r, user, map[string]interface{}{"Error": "Wrong password"})
+			return
+		}
+		valid, err := login.CheckSecondFactor(user.ID, r.FormValue("code"))
+		if err != nil {
+			errors.InternalServerError(w, r, "Error checking code: "+err.Error())
+			return
+		}
+		if !valid {
+			w.WriteHeader(http.StatusBadRequest)
+			renderTwoFactor(w, r, user, map[string]interface{}{"Error": login.InvalidCodeMessage})
+			return
+		}
+	}
+
+	if err := db.DisableTOTP(db.DB, user.ID); err != nil {
+		errors.InternalServerError(w, r, "Error disabling two-factor authentication: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/profile/2fa?done=disabled", http.StatusSeeOther)
+}
+
+// twoFactorUser loads the logged-in user, redirecting guests to the login page
+func twoFactorUser(w http.ResponseWriter, r *http.Request) (*db.User, bool) {
+	session, err := login.GetSessionFromRequest(r)
+	if err != nil || session.IsGuest || session.UserID == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil, false
+	}
+	user, err := db.GetUserByID(db.DB, *session.UserID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Error fetching user: "+err.Error())
+		return nil, false
+	}
+	return user, true
+}
+
+// renderTwoFactor renders the two-factor page for the user's current state
+// plus whatever the handler adds to data
+func renderTwoFactor(w http.ResponseWriter, r *http.Request, user *db.User, data map[string]interface{}) {
+	totp, err := db.GetTOTP(db.DB, user.ID)
+	if err != nil {
+		errors.InternalServerError(w, r, "Database error: "+err.Error())
+		return
+	}
+	data["UserID"] = user.ID
+
+	switch {
+	case totp != nil && totp.Enabled:
+		left, err := db.CountRecoveryCodes(db.DB, user.ID)
+		if err != nil {
+			errors.InternalServerError(w, r, "Database error: "+err.Error())
+			return
+		}
+		data["Enabled"] = true
+		data["EnabledAt"] = totp.EnabledAt
+		data["CodesLeft"] = left
+	case totp != nil:
+		uri := login.TOTPURI(totp.Secret, user.Username)
+		code, err := qr.Encode(uri, qr.M)
+		if err != nil {
+			errors.InternalServerError(w, r, "Error drawing QR code: "+err.Error())
+			return
+		}
+		data["Pending"] = true
+		data["URI"] = template.URL(uri)
+		data["QR"] = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()))
+		data["Secret"] = groupSecret(totp.Secret)
+	}
+
+	tmpl, err := template.New("twofactor.html").Funcs(security.Funcs(r)).ParseFiles("templates/twofactor.html")
+	if err != nil {
+		errors.InternalServerError(w, r, "Error loading template: "+err.Error())
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		errors.InternalServerError(w, r, "Template execution error: "+err.Error())
+	}
+}
+
+// groupSecret splits a secret into groups of four for typing it in by hand
+func groupSecret(secret string) string {
+	var groups []string
+	for len(secret) > 4 {
+		groups = append(groups, secret[:4])
+		secret = secret[4:]
+	}
+	return strings.Join(append(groups, secret), " ")
+}
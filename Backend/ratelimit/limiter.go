@@ -0,0 +1,114 @@
+// Package ratelimit throttles write endpoints with token buckets keyed by
+// user ID and client IP. Buckets live in memory and can be persisted to
+// SQLite so limits survive restarts.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Rule allows Burst requests at once, refilling Burst tokens every Per
+type Rule struct {
+	Burst int
+	Per   time.Duration
+}
+
+// rate is the refill speed in tokens per second
+func (r Rule) rate() float64 {
+	return float64(r.Burst) / r.Per.Seconds()
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Store persists buckets. Errors are not fatal: the limiter keeps working
+// from memory.
+type Store interface {
+	Load(key string) (tokens float64, updated time.Time, found bool, err error)
+	Save(key string, tokens float64, updated time.Time) error
+	DeleteBefore(cutoff time.Time) error
+}
+
+// Limiter holds the token buckets
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	store   Store            // nil = memory only
+	now     func() time.Time // the clock, replaced in tests
+}
+
+// NewLimiter returns a limiter persisting to store, which may be nil
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), store: store, now: time.Now}
+}
+
+// Allow takes one token from the bucket of every key under rule. Tokens are
+// only taken when all buckets have one; otherwise it returns how long to wait.
+func (l *Limiter) Allow(rule Rule, keys ...string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now().UTC()
+	buckets := make([]*bucket, len(keys))
+	var wait time.Duration
+	for i, key := range keys {
+		b := l.get(key, rule, now)
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+now.Sub(b.updated).Seconds()*rule.rate())
+		b.updated = now
+		buckets[i] = b
+		if b.tokens < 1 {
+			if w := time.Duration((1 - b.tokens) / rule.rate() * float64(time.Second)); w > wait {
+				wait = w
+			}
+		}
+	}
+
+	if wait == 0 {
+		for _, b := range buckets {
+			b.tokens--
+		}
+	}
+	if l.store != nil {
+		for i, key := range keys {
+			_ = l.store.Save(key, buckets[i].tokens, now)
+		}
+	}
+	return wait == 0, wait
+}
+
+// get returns the bucket for key, loading it from the store or starting full
+func (l *Limiter) get(key string, rule Rule, now time.Time) *bucket {
+	if b, ok := l.buckets[key]; ok {
+		return b
+	}
+	b := &bucket{tokens: float64(rule.Burst), updated: now}
+	if l.store != nil {
+		if tokens, updated, found, err := l.store.Load(key); err == nil && found {
+			b.tokens, b.updated = tokens, updated
+		}
+	}
+	l.buckets[key] = b
+	return b
+}
+
+// Prune forgets buckets untouched for longer than maxIdle. Any rule's bucket
+// is full again after its Per, so pass the longest Per.
+func (l *Limiter) Prune(maxIdle time.Duration) {
+	cutoff := l.now().UTC().Add(-maxIdle)
+
+	l.mu.Lock()
+	for key, b := range l.buckets {
+		if b.updated.Before(cutoff) {
+			delete(l.buckets, key)
+		}
+	}
+	l.mu.Unlock()
+
+	if l.store != nil {
+		_ = l.store.DeleteBefore(cutoff)
+	}
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"forum/Backend/DB/dbtest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for limiters under test
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestLimiter returns a limiter on a stopped clock
+func newTestLimiter(store Store) (*Limiter, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewLimiter(store)
+	l.now = c.now
+	return l, c
+}
+
+// oneAMinute refills one token a minute
+var oneAMinute = Rule{Burst: 3, Per: 3 * time.Minute}
+
+func TestAllowBurstExhaustion(t *testing.T) {
+	l, _ := newTestLimiter(nil)
+	for i := 0; i < oneAMinute.Burst; i++ {
+		if ok, _ := l.Allow(oneAMinute, "ip:1"); !ok {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	ok, wait := l.Allow(oneAMinute, "ip:1")
+	if ok {
+		t.Fatal("request past the burst allowed")
+	}
+	if wait != time.Minute {
+		t.Errorf("wait = %v, want 1m", wait)
+	}
+
+	// Other keys have buckets of their own
+	if ok, _ := l.Allow(oneAMinute, "ip:2"); !ok {
+		t.Error("another key was refused")
+	}
+}
+
+func TestAllowRefill(t *testing.T) {
+	l, c := newTestLimiter(nil)
+	for i := 0; i < oneAMinute.Burst; i++ {
+		l.Allow(oneAMinute, "ip:1")
+	}
+
+	c.advance(30 * time.Second)
+	if ok, wait := l.Allow(oneAMinute, "ip:1"); ok || wait != 30*time.Second {
+		t.Errorf("half a token refilled: Allow = %v, %v; want false, 30s", ok, wait)
+	}
+	c.advance(30 * time.Second)
+	if ok, _ := l.Allow(oneAMinute, "ip:1"); !ok {
+		t.Error("refused after a token refilled")
+	}
+	if ok, _ := l.Allow(oneAMinute, "ip:1"); ok {
+		t.Error("allowed a second request with one token refilled")
+	}
+
+	// A long pause refills up to the burst, no further
+	c.advance(time.Hour)
+	for i := 0; i < oneAMinute.Burst; i++ {
+		if ok, _ := l.Allow(oneAMinute, "ip:1"); !ok {
+			t.Fatalf("request %d refused after a full refill", i+1)
+		}
+	}
+	if ok, _ := l.Allow(oneAMinute, "ip:1"); ok {
+		t.Error("bucket refilled past its burst")
+	}
+}
+
+func TestAllowTakesFromAllKeysOrNone(t *testing.T) {
+	l, _ := newTestLimiter(nil)
+	for i := 0; i < oneAMinute.Burst; i++ {
+		l.Allow(oneAMinute, "user:1")
+	}
+	if ok, _ := l.Allow(oneAMinute, "ip:1", "user:1"); ok {
+		t.Fatal("allowed with the user's bucket empty")
+	}
+	// The refused request took nothing from the IP's bucket
+	for i := 0; i < oneAMinute.Burst; i++ {
+		if ok, _ := l.Allow(oneAMinute, "ip:1"); !ok {
+			t.Fatalf("IP request %d refused", i+1)
+		}
+	}
+}
+
+func TestPersistedBucketReload(t *testing.T) {
+	store := sqliteStore{dbtest.Open(t)}
+
+	before, c := newTestLimiter(store)
+	for i := 0; i < oneAMinute.Burst; i++ {
+		before.Allow(oneAMinute, "ip:1")
+	}
+
+	// A restarted limiter picks the empty bucket up from the table
+	after := NewLimiter(store)
+	after.now = c.now
+	if ok, wait := after.Allow(oneAMinute, "ip:1"); ok || wait != time.Minute {
+		t.Errorf("after reload: Allow = %v, %v; want false, 1m", ok, wait)
+	}
+	c.advance(time.Minute)
+	if ok, _ := after.Allow(oneAMinute, "ip:1"); !ok {
+		t.Error("refused after a token refilled")
+	}
+
+	// Pruned buckets are gone from the table too
+	c.advance(time.Hour)
+	after.Prune(oneAMinute.Per)
+	if _, _, found, err := store.Load("ip:1"); err != nil || found {
+		t.Errorf("Load after Prune = found %v, %v", found, err)
+	}
+}
+
+func TestLimitSetsRetryAfter(t *testing.T) {
+	// The 429 page is loaded relative to the repository root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	saved := limiter
+	t.Cleanup(func() { limiter = saved })
+	limiter, _ = newTestLimiter(nil)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	login, account := Limit("login", ok), Limit("account", ok)
+	post := func(h http.HandlerFunc) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		h(rec, req)
+		return rec
+	}
+
+	for i := 0; i < Rules["login"].Burst; i++ {
+		if rec := post(login); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i+1, rec.Code)
+		}
+	}
+	rec := post(login)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the burst = %d, want 429", rec.Code)
+	}
+	// login refills a token every 30 seconds
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want %q", got, "30")
+	}
+
+	// Reads aren't limited, and other rules keep their own buckets
+	get := httptest.NewRecorder()
+	login(get, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if get.Code != http.StatusOK {
+		t.Errorf("GET = %d, want 200", get.Code)
+	}
+	if rec := post(account); rec.Code != http.StatusOK {
+		t.Errorf("account rule = %d after the login rule ran out, want 200", rec.Code)
+	}
+}
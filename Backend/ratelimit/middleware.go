@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"fmt"
+	db "forum/Backend/DB"
+	"forum/Backend/errors"
+	"forum/Backend/login"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Rules are the limits of each kind of write, by name. Routes pick one in
+// main.go and in the API route table; change them here.
+var Rules = map[string]Rule{
+	"login":    {Burst: 10, Per: 5 * time.Minute},
+	"oauth":    {Burst: 20, Per: 5 * time.Minute},  // a sign-in is a start and a callback
+	"account":  {Burst: 10, Per: 15 * time.Minute}, // settings that need the password or a code
+	"register": {Burst: 5, Per: time.Hour},
+	"post":     {Burst: 5, Per: 10 * time.Minute},
+	"comment":  {Burst: 10, Per: time.Minute},
+	"vote":     {Burst: 60, Per: time.Minute},
+	"report":   {Burst: 10, Per: 10 * time.Minute},
+	"reset":    {Burst: 5, Per: time.Hour},
+	"verify":   {Burst: 3, Per: time.Hour},
+	"avatar":   {Burst: 10, Per: 10 * time.Minute}, // decoding uploads is costly
+}
+
+// cleanupInterval is how often idle buckets are forgotten
+const cleanupInterval = 10 * time.Minute
+
+// limiter is shared by every route; EnablePersistence swaps in a stored one
+var limiter = NewLimiter(nil)
+
+// EnablePersistence keeps buckets in the rate_limits table as well as in memory
+func EnablePersistence(conn *sql.DB) {
+	limiter = NewLimiter(sqliteStore{conn})
+}
+
+// Limit wraps a handler so that its writes (anything but GET and HEAD) are
+// throttled by the named rule, per user and per client IP
+func Limit(rule string, next http.HandlerFunc) http.HandlerFunc {
+	if _, ok := Rules[rule]; !ok {
+		panic("ratelimit: unknown rule " + rule)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+		if ok, wait := Check(r, rule); !ok {
+			SetRetryAfter(w, wait)
+			errors.TooManyRequests(w, r, "You're doing that too often. Try again in "+humanize(wait)+".")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Check takes a token for the request under the named rule, reporting
+// whether it may proceed and otherwise how long to wait
+func Check(r *http.Request, rule string) (bool, time.Duration) {
+	keys := []string{rule + ":ip:" + login.ClientIP(r)}
+	if session, err := login.GetSessionFromRequest(r); err == nil && session.UserID != nil {
+		keys = append(keys, rule+":user:"+strconv.Itoa(*session.UserID))
+	}
+	return limiter.Allow(Rules[rule], keys...)
+}
+
+// SetRetryAfter sets the Retry-After header in whole seconds, rounding up
+func SetRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// StartCleanupJob periodically forgets buckets that have refilled.
+// It blocks, so run it in its own goroutine.
+func StartCleanupJob() {
+	for {
+		time.Sleep(cleanupInterval)
+		var longest time.Duration
+		for _, rule := range Rules {
+			if rule.Per > longest {
+				longest = rule.Per
+			}
+		}
+		limiter.Prune(longest)
+	}
+}
+
+// humanize formats a wait like "42 seconds" or "3 minutes"
+func humanize(d time.Duration) string {
+	n, unit := int(math.Ceil(d.Seconds())), "second"
+	if d >= time.Minute {
+		n, unit = int(math.Ceil(d.Minutes())), "minute"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// sqliteStore persists buckets with the db package
+type sqliteStore struct {
+	conn *sql.DB
+}
+
+func (s sqliteStore) Load(key string) (float64, time.Time, bool, error) {
+	return db.LoadRateBucket(s.conn, key)
+}
+
+func (s sqliteStore) Save(key string, tokens float64, updated time.Time) error {
+	return db.SaveRateBucket(s.conn, key, tokens, updated)
+}
+
+func (s sqliteStore) DeleteBefore(cutoff time.Time) error {
+	return db.DeleteRateBucketsBefore(s.conn, cutoff)
+}
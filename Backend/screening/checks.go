@@ -0,0 +1,100 @@
+package screening
+
+import (
+	db "forum/Backend/DB"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxLinks is the number of links a submission may contain before it is held
+const MaxLinks = 2
+
+// DuplicateWindow is how far back DuplicateFilter looks for repeated content
+const DuplicateWindow = 24 * time.Hour
+
+// WordFilter applies the admin-managed blocked words: mask words are
+// replaced with asterisks, reject words refuse the submission
+type WordFilter struct{}
+
+func (WordFilter) Name() string { return "word filter" }
+
+func (WordFilter) Screen(c *Content) (Verdict, string, error) {
+	words, err := db.GetBlockedWords(db.DB)
+	if err != nil {
+		return Publish, "", err
+	}
+	for _, w := range words {
+		if w.Word == "" {
+			continue
+		}
+		re, err := wordPattern(w.Word)
+		if err != nil {
+			continue
+		}
+		if w.Mode == db.WordReject {
+			if re.MatchString(c.Title) || re.MatchString(c.Body) {
+				return Reject, "it contains a blocked word", nil
+			}
+			continue
+		}
+		c.Title = re.ReplaceAllStringFunc(c.Title, maskWord)
+		c.Body = re.ReplaceAllStringFunc(c.Body, maskWord)
+	}
+	return Publish, "", nil
+}
+
+// maskWord replaces every character of a word with an asterisk
+func maskWord(word string) string {
+	return strings.Repeat("*", len([]rune(word)))
+}
+
+// wordPattern matches word case-insensitively and not as part of a longer word
+func wordPattern(word string) (*regexp.Regexp, error) {
+	pattern := regexp.QuoteMeta(word)
+	if isWordChar(word[0]) {
+		pattern = `\b` + pattern
+	}
+	if isWordChar(word[len(word)-1]) {
+		pattern += `\b`
+	}
+	return regexp.Compile(`(?i)` + pattern)
+}
+
+func isWordChar(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// linkPattern finds URLs and bare www. addresses
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.`)
+
+// LinkLimit holds submissions with more than Max links
+type LinkLimit struct {
+	Max int
+}
+
+func (LinkLimit) Name() string { return "link limit" }
+
+func (l LinkLimit) Screen(c *Content) (Verdict, string, error) {
+	n := len(linkPattern.FindAllStringIndex(c.Title+" "+c.Body, -1))
+	if n > l.Max {
+		return Hold, strconv.Itoa(n) + " links", nil
+	}
+	return Publish, "", nil
+}
+
+// DuplicateFilter holds content the same user already posted within Window
+type DuplicateFilter struct {
+	Window time.Duration
+}
+
+func (DuplicateFilter) Name() string { return "duplicate content" }
+
+func (d DuplicateFilter) Screen(c *Content) (Verdict, string, error) {
+	dup, err := db.HasRecentDuplicate(db.DB, c.UserID, c.PostID, c.Body, time.Now().UTC().Add(-d.Window))
+	if err != nil || !dup {
+		return Publish, "", err
+	}
+	return Hold, "duplicate of a recent submission", nil
+}
@@ -0,0 +1,119 @@
+package screening
+
+import (
+	db "forum/Backend/DB"
+	"forum/Backend/DB/dbtest"
+	"testing"
+	"time"
+)
+
+func TestWordFilter(t *testing.T) {
+	dbtest.Use(t)
+	for word, mode := range map[string]string{"noob": db.WordReject, "darn": db.WordMask, "c++": db.WordMask} {
+		if _, err := db.SetBlockedWord(db.DB, word, mode, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		title   string
+		body    string
+		verdict Verdict
+		want    string // the body after screening
+	}{
+		{"reject in any case", "", "What a NOOB move", Reject, "What a NOOB move"},
+		{"reject in the title", "Noob question", "How do I craft a bed?", Reject, "How do I craft a bed?"},
+		{"reject word inside a longer word", "", "Stop being noobish, says snoob", Publish, "Stop being noobish, says snoob"},
+		{"mask in any case", "", "Darn it, darn it all", Publish, "**** it, **** it all"},
+		{"mask next to punctuation", "", "(darn!)", Publish, "(****!)"},
+		{"mask word inside a longer word", "", "Darned darnit undarn darn_it", Publish, "Darned darnit undarn darn_it"},
+		{"symbol ending word", "", "I write C++ and abc++ daily", Publish, "I write *** and abc++ daily"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Content{Title: tt.title, Body: tt.body}
+			verdict, _, err := WordFilter{}.Screen(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verdict != tt.verdict {
+				t.Errorf("verdict = %v, want %v", verdict, tt.verdict)
+			}
+			if c.Body != tt.want {
+				t.Errorf("body = %q, want %q", c.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		body    string
+		verdict Verdict
+		reason  string
+	}{
+		{"no links", "", "example.com is not a link without www.", Publish, ""},
+		{"schemed links at the limit", "", "See http://a.example and HTTPS://b.example", Publish, ""},
+		{"bare and schemed over the limit", "", "http://a.example www.b.example WWW.c.example", Hold, "3 links"},
+		{"title links count", "Guide at www.a.example", "https://b.example https://c.example", Hold, "3 links"},
+		{"www inside a word", "", "swww.a.example awww.b.example https://c.example", Publish, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, reason, err := LinkLimit{Max: 2}.Screen(&Content{Title: tt.title, Body: tt.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verdict != tt.verdict || reason != tt.reason {
+				t.Errorf("Screen = %v, %q; want %v, %q", verdict, reason, tt.verdict, tt.reason)
+			}
+		})
+	}
+}
+
+func TestDuplicateFilter(t *testing.T) {
+	dbtest.Use(t)
+	alice := dbtest.CreateUser(t, db.DB, "alice")
+	bob := dbtest.CreateUser(t, db.DB, "bob")
+	now := time.Now().UTC()
+
+	recentID, err := db.CreatePost(db.DB, alice, "Trade", "Selling my  diamond SWORD", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePost(db.DB, alice, "Old trade", "Selling my netherite pickaxe", now.Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec(`INSERT INTO comments (post_id, user_id, content, created_at) VALUES (?, ?, ?, ?)`,
+		recentID, alice, "Still available", now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content Content
+		verdict Verdict
+	}{
+		{"same post within the window", Content{UserID: alice, Body: "selling my diamond sword"}, Hold},
+		{"same comment within the window", Content{UserID: alice, Body: "  still AVAILABLE "}, Hold},
+		{"same post outside the window", Content{UserID: alice, Body: "Selling my netherite pickaxe"}, Publish},
+		{"same text by someone else", Content{UserID: bob, Body: "Selling my diamond sword"}, Publish},
+		{"different text", Content{UserID: alice, Body: "Selling my diamond shovel"}, Publish},
+		{"the post being edited", Content{UserID: alice, Body: "Selling my diamond sword", PostID: recentID}, Publish},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.content
+			verdict, _, err := DuplicateFilter{Window: DuplicateWindow}.Screen(&c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verdict != tt.verdict {
+				t.Errorf("verdict = %v, want %v", verdict, tt.verdict)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Package screening runs new posts and comments, and edited posts, through
+// a pipeline of checks before they are published: the admin-managed word
+// filter and heuristics that hold likely spam for review.
+package screening
+
+import "strings"
+
+// Verdict is the outcome of screening
+type Verdict int
+
+const (
+	Publish Verdict = iota
+	Hold            // publish hidden, pending moderator review
+	Reject          // refuse the submission
+)
+
+// Content is a submission being screened. Checks may rewrite Title and
+// Body, e.g. to mask words.
+type Content struct {
+	UserID int
+	Kind   string // "post" or "comment"
+	Title  string // empty for comments
+	Body   string
+	PostID int // the post being edited, 0 for new content
+}
+
+// Result is the outcome of Screen
+type Result struct {
+	Verdict Verdict
+	Title   string
+	Body    string
+	Reasons []string // why the content was held or rejected
+}
+
+// Check is one step of the pipeline. It returns Publish to let the content
+// through, or Hold/Reject with a reason.
+type Check interface {
+	Name() string
+	Screen(c *Content) (Verdict, string, error)
+}
+
+// pipeline runs in order; the word filter comes first so later checks see
+// masked text
+var pipeline = []Check{
+	WordFilter{},
+	LinkLimit{Max: MaxLinks},
+	DuplicateFilter{Window: DuplicateWindow},
+}
+
+// Register appends a check to the pipeline. It is meant to be called at
+// startup, before serving requests.
+func Register(check Check) {
+	pipeline = append(pipeline, check)
+}
+
+// Screen runs content through every check. A rejection stops the pipeline;
+// holds are collected so moderators see every reason.
+func Screen(c Content) (Result, error) {
+	res := Result{Verdict: Publish}
+	for _, check := range pipeline {
+		verdict, reason, err := check.Screen(&c)
+		if err != nil {
+			return res, err
+		}
+		if verdict == Publish {
+			continue
+		}
+		res.Reasons = append(res.Reasons, reason)
+		if verdict > res.Verdict {
+			res.Verdict = verdict
+		}
+		if verdict == Reject {
+			break
+		}
+	}
+	res.Title, res.Body = c.Title, c.Body
+	return res, nil
+}
+
+// Message explains a held or rejected submission to its author
+func (r Result) Message() string {
+	switch r.Verdict {
+	case Reject:
+		return "Your submission was rejected: " + strings.Join(r.Reasons, "; ")
+	case Hold:
+		return "Your submission is awaiting review by a moderator"
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+// Package security protects the HTML site against cross-site request
+// forgery and open redirects.
+package security
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	goerrors "errors"
+	"forum/Backend/errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// CSRFField is the form field carrying the token
+	CSRFField = "csrf_token"
+	// CSRFHeader may carry the token instead, for scripts
+	CSRFHeader = "X-CSRF-Token"
+	// MaxFormBytes caps the body of form submissions, file uploads included
+	MaxFormBytes = 4 << 20
+
+	sessionCookie = "session_token" // set by the login package
+	guestCookie   = "csrf_guest"    // ties tokens to visitors without a session
+)
+
+type basisKey struct{}
+
+// CSRF wraps the site. Every visitor gets something to tie a token to, and
+// requests other than GET, HEAD and OPTIONS are rejected unless they carry
+// the token and come from this site. Their bodies are capped at MaxFormBytes.
+// The JSON API does its own checks.
+func CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		basis := tokenBasis(r)
+		if basis == "" {
+			basis = uuid.NewString()
+			http.SetCookie(w, &http.Cookie{
+				Name:     guestCookie,
+				Value:    basis,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+			r = r.WithContext(context.WithValue(r.Context(), basisKey{}, basis))
+		}
+
+		if !safeMethod(r.Method) {
+			r.Body = http.MaxBytesReader(w, r.Body, MaxFormBytes)
+			err := r.ParseMultipartForm(MaxFormBytes)
+			var tooLarge *http.MaxBytesError
+			if goerrors.As(err, &tooLarge) {
+				errors.BadRequest(w, r, "The form was too large to send.")
+				return
+			}
+
+			sent := r.Header.Get(CSRFHeader)
+			if sent == "" {
+				sent = r.FormValue(CSRFField)
+			}
+			if !SameOrigin(r) || subtle.ConstantTimeCompare([]byte(sent), []byte(tokenFor(basis))) != 1 {
+				errors.Forbidden(w, r, "This form has expired or was sent from another site. Go back, reload the page and try again.")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSRFToken is the token forms on this request's page must send back
+func CSRFToken(r *http.Request) string {
+	return tokenFor(tokenBasis(r))
+}
+
+// Funcs are the template functions for forms: csrfField renders the hidden
+// input and csrfToken the bare token
+func Funcs(r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + CSRFField + `" value="` + CSRFToken(r) + `">`)
+		},
+		"csrfToken": func() string {
+			return CSRFToken(r)
+		},
+	}
+}
+
+// SameOrigin reports whether the browser says the request came from this
+// site. Requests without Origin and Referer, such as from curl, pass.
+func SameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		return true
+	}
+	u, err := url.Parse(source)
+	return err == nil && u.Host == r.Host
+}
+
+// tokenBasis is the secret the token is derived from: the session cookie,
+// or the guest cookie before logging in
+func tokenBasis(r *http.Request) string {
+	if basis, ok := r.Context().Value(basisKey{}).(string); ok {
+		return basis
+	}
+	for _, name := range []string{sessionCookie, guestCookie} {
+		if c, err := r.Cookie(name); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// tokenFor hashes the basis, so pages can show the token without revealing
+// the cookie it comes from
+func tokenFor(basis string) string {
+	sum := sha256.Sum256([]byte("csrf:" + basis))
+	return hex.EncodeToString(sum[:])
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
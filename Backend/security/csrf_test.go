@@ -0,0 +1,140 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// csrfHandler is the CSRF middleware around a handler that answers 200.
+// The 403 page is loaded relative to the repository root, so the test
+// runs from there.
+func csrfHandler(t *testing.T) http.Handler {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+// formPost builds a form submission with the given cookie and token field
+func formPost(cookie *http.Cookie, token string) *http.Request {
+	form := url.Values{}
+	if token != "" {
+		form.Set(CSRFField, token)
+	}
+	r := httptest.NewRequest(http.MethodPost, "http://forum.test/post/comment", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+// tokenForCookie is the token a page shows a browser holding cookie
+func tokenForCookie(cookie *http.Cookie) string {
+	r := httptest.NewRequest(http.MethodGet, "http://forum.test/", nil)
+	r.AddCookie(cookie)
+	return CSRFToken(r)
+}
+
+func TestCSRFRejectsMissingAndWrongTokens(t *testing.T) {
+	h := csrfHandler(t)
+	session := &http.Cookie{Name: sessionCookie, Value: "session-1"}
+	other := &http.Cookie{Name: sessionCookie, Value: "session-2"}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"missing token", ""},
+		{"wrong token", "not-the-token"},
+		{"another session's token", tokenForCookie(other)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, formPost(session, tt.token))
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want 403", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFAcceptsSessionToken(t *testing.T) {
+	h := csrfHandler(t)
+	session := &http.Cookie{Name: sessionCookie, Value: "session-1"}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, formPost(session, tokenForCookie(session)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("form field: status = %d, want 200", rec.Code)
+	}
+
+	r := formPost(session, "")
+	r.Header.Set(CSRFHeader, tokenForCookie(session))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("header: status = %d, want 200", rec.Code)
+	}
+
+	// The right token from another site is still turned away
+	r = formPost(session, tokenForCookie(session))
+	r.Header.Set("Origin", "https://evil.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("cross-origin: status = %d, want 403", rec.Code)
+	}
+}
+
+func TestCSRFAcceptsGuestToken(t *testing.T) {
+	h := csrfHandler(t)
+
+	// A first visit hands out the guest cookie
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://forum.test/login", nil))
+	var guest *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == guestCookie {
+			guest = c
+		}
+	}
+	if guest == nil || guest.Value == "" {
+		t.Fatal("no guest cookie set")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, formPost(guest, tokenForCookie(guest)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestCSRFSafeMethodsBypass(t *testing.T) {
+	h := csrfHandler(t)
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, "http://forum.test/post?id=1", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", method, rec.Code)
+		}
+	}
+
+	// The JSON API checks requests itself
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://forum.test/api/v1/posts", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("API POST: status = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package security
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SafeRedirect returns target if it points into this site, as a path or as
+// a URL on this host, and fallback otherwise. Use it for any redirect taken
+// from the request, such as the Referer.
+func SafeRedirect(r *http.Request, target, fallback string) string {
+	u, err := url.Parse(target)
+	if err != nil || target == "" {
+		return fallback
+	}
+	if u.Scheme != "" || u.Host != "" {
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host != r.Host {
+			return fallback
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+	// "//evil.com" and "/\evil.com" are read as other hosts by browsers. The
+	// escaped path turns the backslash into %5C, so check the decoded one.
+	if strings.HasPrefix(u.Path, "//") || strings.HasPrefix(u.Path, "/\\") {
+		return fallback
+	}
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, "/") {
+		return fallback
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		path += "#" + u.EscapedFragment()
+	}
+	return path
+}
@@ -0,0 +1,34 @@
+package security
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSafeRedirect(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://forum.test/post?id=1", nil)
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"local path with query", "/post?id=3&page=2", "/post?id=3&page=2"},
+		{"local path with fragment", "/post?id=3#comment-7", "/post?id=3#comment-7"},
+		{"URL on this host", "http://forum.test/homePage?sort=new", "/homePage?sort=new"},
+		{"this host without a path", "https://forum.test", "/"},
+		{"empty", "", "/fallback"},
+		{"protocol-relative", "//evil.com", "/fallback"},
+		{"backslash host", `/\evil.com`, "/fallback"},
+		{"other host", "https://evil.com", "/fallback"},
+		{"other host with this path", "https://evil.com/post?id=1", "/fallback"},
+		{"javascript", "javascript:alert(1)", "/fallback"},
+		{"relative path", "post?id=1", "/fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SafeRedirect(r, tt.target, "/fallback"); got != tt.want {
+				t.Errorf("SafeRedirect(%q) = %q, want %q", tt.target, got, tt.want)
+			}
+		})
+	}
+}
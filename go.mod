@@ -3,7 +3,8 @@ module forum
 go 1.23.4
 
 require (
+	github.com/google/uuid v1.6.0
 	github.com/mattn/go-sqlite3 v1.14.30
 	golang.org/x/crypto v0.40.0
-	github.com/google/uuid v1.6.0
+	rsc.io/qr v0.2.0
 )
@@ -3,21 +3,94 @@ package main
 import (
 	"fmt"
 	db "forum/Backend/DB"
+	"forum/Backend/DB/migrations"
 	register "forum/Backend/Register"
+	"forum/Backend/account"
+	"forum/Backend/admin"
+	"forum/Backend/api"
 	"forum/Backend/home"
 	"forum/Backend/login"
+	"forum/Backend/mailer"
+	"forum/Backend/oauth"
+	"forum/Backend/permissions"
 	"forum/Backend/posts"
 	"forum/Backend/profile"
+	"forum/Backend/ratelimit"
+	"forum/Backend/security"
 	"net/http"
+	"os"
 )
 
 func main() {
+	// "forum migrate up|down|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if !db.OpenDB() {
+			os.Exit(1)
+		}
+		err := migrations.RunCommand(db.DB, os.Args[2:])
+		db.DB.Close()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "forum role <username> <role>" appoints moderators and admins and exits
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		db.InitDB()
+		if db.DB == nil {
+			os.Exit(1)
+		}
+		err := permissions.RunCommand(db.DB, os.Args[2:])
+		db.DB.Close()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	db.InitDB()
 	if db.DB == nil {
 		fmt.Println("Failed to connect to the database. Exiting.")
 		return
 	}
 
+	// Hard-delete soft-deleted content once its retention period is over
+	go posts.StartPurgeJob()
+
+	// Rate limits live in memory unless FORUM_RATE_LIMIT_STORE=sqlite
+	if os.Getenv("FORUM_RATE_LIMIT_STORE") == "sqlite" {
+		ratelimit.EnablePersistence(db.DB)
+	}
+	go ratelimit.StartCleanupJob()
+
+	// Forget login attempts once they no longer matter for lockouts or notices
+	go login.StartAttemptCleanupJob()
+
+	// Delete sessions that expired without logging out
+	go login.StartSessionSweeper()
+
+	// Reply depth from FORUM_MAX_COMMENT_DEPTH
+	posts.Configure()
+
+	// Emails go through SMTP when FORUM_SMTP_ADDR is set, to ./mail otherwise
+	mailer.Configure()
+
+	// Build "download my data" archives, email their owners and delete
+	// them once they expire
+	go account.StartExportJob()
+
+	// Login with OpenID Connect providers from FORUM_OAUTH_PROVIDERS, plus a
+	// mock provider for development when FORUM_OAUTH_MOCK_ADDR is set
+	oauth.Configure()
+	if addr := os.Getenv("FORUM_OAUTH_MOCK_ADDR"); addr != "" {
+		if err := oauth.StartMockServer(addr); err != nil {
+			fmt.Println("Mock OpenID provider failed to start:", err)
+		}
+	}
+
 	mux := http.NewServeMux()
 
 	// Static files without referer check
@@ -31,24 +104,75 @@ func main() {
 	// App routes
 	mux.HandleFunc("/", home.WelcomePage)
 	mux.HandleFunc("/homePage", home.AllPosts)
+	mux.HandleFunc("/search", home.SearchPosts)
 	mux.HandleFunc("/category/general", home.GeneralPosts)
 	mux.HandleFunc("/category/minecraft", home.MinecraftPosts)
 	mux.HandleFunc("/category/souls", home.SoulsPosts)
 	mux.HandleFunc("/category/online", home.OnlinePosts)
 	mux.HandleFunc("/category/story", home.StoryPosts)
 	mux.HandleFunc("/post", posts.PostShowHandler)
+	mux.HandleFunc("/post/edit", posts.EditPostHandler)
+	mux.HandleFunc("/post/history", posts.PostHistoryHandler)
+	mux.HandleFunc("/post/delete", posts.DeletePostHandler)
 	mux.HandleFunc("/profile", profile.ProfileHandler)
+	mux.HandleFunc("/profile/edit", profile.EditProfileHandler)
+	mux.HandleFunc("/profile/avatar", ratelimit.Limit("avatar", profile.UploadAvatarHandler))
+	mux.HandleFunc("/profile/avatar/remove", profile.RemoveAvatarHandler)
+	mux.HandleFunc("/avatar", profile.AvatarHandler)
+	mux.HandleFunc("/profile/tokens", profile.CreateTokenHandler)
+	mux.HandleFunc("/profile/tokens/revoke", profile.RevokeTokenHandler)
+	mux.HandleFunc("/profile/sessions", profile.SessionsHandler)
+	mux.HandleFunc("/profile/sessions/revoke", profile.RevokeSessionHandler)
+	mux.HandleFunc("/profile/sessions/revoke-others", profile.RevokeOtherSessionsHandler)
+	mux.HandleFunc("/profile/2fa", profile.TwoFactorHandler)
+	mux.HandleFunc("/profile/2fa/setup", profile.SetupTwoFactorHandler)
+	mux.HandleFunc("/profile/2fa/enable", ratelimit.Limit("account", profile.EnableTwoFactorHandler))
+	mux.HandleFunc("/profile/2fa/recovery", ratelimit.Limit("account", profile.RecoveryCodesHandler))
+	mux.HandleFunc("/profile/2fa/disable", ratelimit.Limit("account", profile.DisableTwoFactorHandler))
+	mux.HandleFunc("/profile/connections", profile.ConnectionsHandler)
+	mux.HandleFunc("/profile/connections/unlink", profile.UnlinkIdentityHandler)
+	mux.HandleFunc("/profile/settings", account.SettingsHandler)
+	mux.HandleFunc("/profile/settings/password", ratelimit.Limit("account", account.ChangePasswordHandler))
+	mux.HandleFunc("/profile/settings/email", ratelimit.Limit("account", account.ChangeEmailHandler))
+	mux.HandleFunc("/profile/settings/username", account.ChangeUsernameHandler)
+	mux.HandleFunc("/profile/data", account.DataHandler)
+	mux.HandleFunc("/profile/data/export", account.RequestExportHandler)
+	mux.HandleFunc("/profile/data/download", account.DownloadExportHandler)
+	mux.HandleFunc("/profile/delete", ratelimit.Limit("account", account.DeleteAccountHandler))
+	mux.HandleFunc("/profile/confirm-code", ratelimit.Limit("account", account.SendCodeHandler))
 	mux.HandleFunc("/about", home.AboutPage)
-	mux.HandleFunc("/register", register.RegisterHandler)
-	mux.HandleFunc("/login", login.LoginHandler)
-	mux.HandleFunc("/createpost", posts.PostHandler)
+	mux.HandleFunc("/register", ratelimit.Limit("register", register.RegisterHandler))
+	mux.HandleFunc("/login", ratelimit.Limit("login", login.LoginHandler))
+	mux.HandleFunc("/login/2fa", ratelimit.Limit("login", login.TwoFactorLoginHandler))
+	mux.HandleFunc("/oauth/start", ratelimit.Limit("oauth", login.OAuthStartHandler))
+	mux.HandleFunc("/oauth/callback", ratelimit.Limit("oauth", login.OAuthCallbackHandler))
+	mux.HandleFunc("/createpost", ratelimit.Limit("post", posts.PostHandler))
 	mux.HandleFunc("/logout", login.LogoutHandler)
-	mux.HandleFunc("/post/like", posts.LikePostHandler)
-	mux.HandleFunc("/post/comment", posts.CommentOnPostHandler)
-	mux.HandleFunc("/comment/like", posts.LikePostHandler)
+	mux.HandleFunc("/forgot-password", ratelimit.Limit("reset", account.ForgotPasswordHandler))
+	mux.HandleFunc("/reset-password", account.ResetPasswordHandler)
+	mux.HandleFunc("/verify-email", register.VerifyEmailHandler)
+	mux.HandleFunc("/verify-email/resend", ratelimit.Limit("verify", register.ResendVerificationHandler))
+	mux.HandleFunc("/post/like", ratelimit.Limit("vote", posts.LikePostHandler))
+	mux.HandleFunc("/post/comment", ratelimit.Limit("comment", posts.CommentOnPostHandler))
+	mux.HandleFunc("/comment/reply", ratelimit.Limit("comment", posts.ReplyToCommentHandler))
+	mux.HandleFunc("/comment/like", ratelimit.Limit("vote", posts.LikePostHandler))
+	mux.HandleFunc("/comment/delete", posts.DeleteCommentHandler)
+
+	mux.HandleFunc("/report", ratelimit.Limit("report", posts.ReportHandler))
+	mux.HandleFunc("/admin/reports", admin.ReportsHandler)
+	mux.HandleFunc("/admin/reports/action", admin.ReportActionHandler)
+	mux.HandleFunc("/admin/unhide", admin.UnhideHandler)
+	mux.HandleFunc("/admin/roles", admin.RolesHandler)
+	mux.HandleFunc("/admin/bans", admin.BansHandler)
+	mux.HandleFunc("/admin/audit", admin.AuditHandler)
+	mux.HandleFunc("/admin/audit/export", admin.AuditExportHandler)
+	mux.HandleFunc("/admin/filters", admin.FiltersHandler)
+
+	// JSON API
+	mux.Handle(api.Prefix+"/", api.Handler())
 
 	fmt.Println("Server started on http://localhost:8888")
-	if err := http.ListenAndServe(":8888", mux); err != nil {
+	if err := http.ListenAndServe(":8888", security.CSRF(login.SlideSessions(mux))); err != nil {
 		fmt.Println("Server failed to start:", err)
 	}
 }